@@ -22,18 +22,29 @@ var (
 			APIGroups: []string{""},
 			// TODO(jesse): remove exec privileges when issue #499 is resolved
 			Resources: []string{"pods", "pods/exec"},
-			Verbs:     []string{"create", "get", "list", "watch", "update", "patch"},
+			Verbs:     []string{"create", "get", "list", "watch", "update", "patch", "delete"},
 		},
 		{
 			APIGroups: []string{""},
 			Resources: []string{"configmaps"},
-			Verbs:     []string{"get", "watch", "list"},
+			Verbs:     []string{"get", "watch", "list", "create", "update"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
 		},
 		{
 			APIGroups: []string{""},
 			Resources: []string{"persistentvolumeclaims"},
 			Verbs:     []string{"create", "delete"},
 		},
+		{
+			APIGroups: []string{""},
+			// secrets are read for the headers of http templates
+			Resources: []string{"secrets"},
+			Verbs:     []string{"get"},
+		},
 		{
 			APIGroups: []string{"jbrette.io"},
 			Resources: []string{"manageds"},
@@ -92,6 +92,9 @@ func printManagedHelper(wf *wfv1.Managed, outFmt string) {
 		}
 		fmt.Printf(fmtStr, "Duration:", humanizeDuration(duration))
 	}
+	if wf.Status.ResourcesDuration != nil {
+		fmt.Printf(fmtStr, "ResourcesDuration:", wf.Status.ResourcesDuration.String())
+	}
 
 	if len(wf.Spec.Arguments.Parameters) > 0 {
 		fmt.Printf(fmtStr, "Parameters:", "")
@@ -117,6 +120,10 @@ func printManagedHelper(wf *wfv1.Managed, outFmt string) {
 					fmt.Printf(fmtStr, "  "+art.Name+":", art.S3.String())
 				} else if art.Artifactory != nil {
 					fmt.Printf(fmtStr, "  "+art.Name+":", art.Artifactory.String())
+				} else if art.GCS != nil {
+					fmt.Printf(fmtStr, "  "+art.Name+":", art.GCS.String())
+				} else if art.Azure != nil {
+					fmt.Printf(fmtStr, "  "+art.Name+":", art.Azure.String())
 				}
 			}
 		}
@@ -132,7 +139,7 @@ func printManagedHelper(wf *wfv1.Managed, outFmt string) {
 		fmt.Println()
 		// apply a dummy FgDefault format to align tabwriter with the rest of the columns
 		if outFmt == "wide" {
-			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tARTIFACTS\tMESSAGE\n", ansiFormat("STEP", FgDefault))
+			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tHOST\tRESOURCES\tARTIFACTS\tMESSAGE\n", ansiFormat("STEP", FgDefault))
 		} else {
 			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tMESSAGE\n", ansiFormat("STEP", FgDefault))
 		}
@@ -187,7 +194,7 @@ type executionNode struct {
 	nodeInfo
 }
 
-// Currently this is the step groups or retry nodes
+// Currently this is the step groups, retry nodes or container set pod nodes
 type nonBoundaryParentNode struct {
 	nodeInfo
 	children []renderNode // Can be boundaryNode or executionNode
@@ -208,7 +215,22 @@ func isNonBoundaryParentNode(node wfv1.NodeType) bool {
 }
 
 func isExecutionNode(node wfv1.NodeType) bool {
-	return (node == wfv1.NodeTypePod) || (node == wfv1.NodeTypeSkipped) || (node == wfv1.NodeTypeSuspend)
+	return (node == wfv1.NodeTypePod) || (node == wfv1.NodeTypeSkipped) || (node == wfv1.NodeTypeSuspend) || (node == wfv1.NodeTypeContainer) || (node == wfv1.NodeTypeHTTP)
+}
+
+// isContainerSetNode returns whether the node is the pod of a container set, which is the parent
+// of the nodes of its containers. The pods of steps and DAG tasks also have children, which are the
+// next step group or the dependent tasks.
+func isContainerSetNode(wf *wfv1.Managed, node wfv1.NodeStatus) bool {
+	if node.Type != wfv1.NodeTypePod {
+		return false
+	}
+	for _, child := range node.Children {
+		if wf.Status.Nodes[child].Type == wfv1.NodeTypeContainer {
+			return true
+		}
+	}
+	return false
 }
 
 func insertSorted(wf *wfv1.Managed, sortedArray []renderNode, item renderNode) []renderNode {
@@ -298,11 +320,15 @@ func convertToRenderTrees(wf *wfv1.Managed) map[string]renderNode {
 			log.Fatal("Missing node type in status node. Cannot get manageds created with Kubext <= 2.0 using the default or wide output option.")
 			return nil
 		}
-		if isNonBoundaryParentNode(status.Type) {
+		if isNonBoundaryParentNode(status.Type) || isContainerSetNode(wf, status) {
 			n := nonBoundaryParentNode{nodeInfo: nodeInfo{id: id}}
 			nonBoundaryParentMap[id] = &n
 
 			for _, child := range status.Children {
+				if status.Type == wfv1.NodeTypePod && wf.Status.Nodes[child].Type != wfv1.NodeTypeContainer {
+					// the successors of a container set pod are rendered in their own boundary
+					continue
+				}
 				nonBoundaryParentChildrenMap[child] = &n
 			}
 		}
@@ -323,7 +349,7 @@ func convertToRenderTrees(wf *wfv1.Managed) map[string]renderNode {
 			for _, val := range parentBoundaryMap[id] {
 				n.boundaryContained = insertSorted(wf, n.boundaryContained, val)
 			}
-		case isNonBoundaryParentNode(status.Type):
+		case isNonBoundaryParentNode(status.Type) || isContainerSetNode(wf, status):
 			nPtr, ok := nonBoundaryParentMap[id]
 			if !ok {
 				log.Fatal("Unable to lookup node " + id)
@@ -414,16 +440,19 @@ func printNode(w *tabwriter.Writer, wf *wfv1.Managed, node wfv1.NodeStatus, dept
 	nodeName := fmt.Sprintf("%s %s", jobStatusIconMap[node.Phase], node.DisplayName)
 	var args []interface{}
 	duration := humanizeDurationShort(node.StartedAt, node.FinishedAt)
+	message := getNodeMessage(node, outFmt)
 	if node.Type == wfv1.NodeTypePod {
-		args = []interface{}{nodePrefix, nodeName, node.ID, duration, node.Message}
+		args = []interface{}{nodePrefix, nodeName, node.ID, duration, message}
+	} else if node.Type == wfv1.NodeTypeContainer {
+		args = []interface{}{nodePrefix, nodeName, "", duration, message}
 	} else {
-		args = []interface{}{nodePrefix, nodeName, "", "", node.Message}
+		args = []interface{}{nodePrefix, nodeName, "", "", message}
 	}
 	if outFmt == "wide" {
 		msg := args[len(args)-1]
-		args[len(args)-1] = getArtifactsString(node)
-		args = append(args, msg)
-		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", args...)
+		args[len(args)-1] = getHostString(node)
+		args = append(args, getResourcesDurationString(node), getArtifactsString(node), msg)
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\t%s\n", args...)
 	} else {
 		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", args...)
 	}
@@ -464,6 +493,46 @@ func (nodeInfo *executionNode) renderNodes(w *tabwriter.Writer, wf *wfv1.Managed
 	}
 }
 
+// getNodeMessage returns the message of a node prefixed with its failure reason. The wide format
+// also includes the exit code and signal of the main container.
+func getNodeMessage(node wfv1.NodeStatus, outFmt string) string {
+	var details []string
+	if node.FailureReason != "" {
+		details = append(details, string(node.FailureReason))
+	}
+	if outFmt == "wide" {
+		if node.ExitCode != nil {
+			details = append(details, fmt.Sprintf("exit code %d", *node.ExitCode))
+		}
+		if node.Signal != nil {
+			details = append(details, fmt.Sprintf("signal %d", *node.Signal))
+		}
+	}
+	if len(details) == 0 {
+		return node.Message
+	}
+	prefix := strings.Join(details, ", ")
+	if node.Message == "" {
+		return prefix
+	}
+	return fmt.Sprintf("%s: %s", prefix, node.Message)
+}
+
+// getHostString returns the Kubernetes node and its IP on which the pod of a node ran
+func getHostString(node wfv1.NodeStatus) string {
+	if node.HostIP == "" {
+		return node.HostNodeName
+	}
+	return fmt.Sprintf("%s (%s)", node.HostNodeName, node.HostIP)
+}
+
+func getResourcesDurationString(node wfv1.NodeStatus) string {
+	if node.ResourcesDuration == nil {
+		return ""
+	}
+	return node.ResourcesDuration.String()
+}
+
 func getArtifactsString(node wfv1.NodeStatus) string {
 	if node.Outputs == nil {
 		return ""
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestNode returns a succeeded node started the given number of seconds after the managed
+func newTestNode(id string, displayName string, nodeType wfv1.NodeType, boundaryID string, started int, children ...string) wfv1.NodeStatus {
+	startedAt := metav1.NewTime(time.Date(2018, 1, 1, 0, 0, started, 0, time.UTC))
+	return wfv1.NodeStatus{
+		ID:          id,
+		Name:        id,
+		DisplayName: displayName,
+		Type:        nodeType,
+		BoundaryID:  boundaryID,
+		Phase:       wfv1.NodeSucceeded,
+		StartedAt:   startedAt,
+		FinishedAt:  metav1.NewTime(startedAt.Add(time.Second)),
+		Children:    children,
+	}
+}
+
+// renderTree renders the tree of the nodes of a managed like kubext get
+func renderTree(wf *wfv1.Managed) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	roots := convertToRenderTrees(wf)
+	roots[wf.ObjectMeta.Name].renderNodes(w, wf, 0, " ", " ", "")
+	_ = w.Flush()
+	return buf.String()
+}
+
+// TestRenderSteps verifies the pods of consecutive steps are rendered as siblings, although each
+// pod is the parent of the next step group
+func TestRenderSteps(t *testing.T) {
+	wf := &wfv1.Managed{
+		ObjectMeta: metav1.ObjectMeta{Name: "two-steps"},
+		Status: wfv1.ManagedStatus{
+			Nodes: map[string]wfv1.NodeStatus{
+				"two-steps":   newTestNode("two-steps", "two-steps", wfv1.NodeTypeSteps, "", 0, "two-steps-0"),
+				"two-steps-0": newTestNode("two-steps-0", "[0]", wfv1.NodeTypeStepGroup, "two-steps", 0, "two-steps-1"),
+				"two-steps-1": newTestNode("two-steps-1", "hello1", wfv1.NodeTypePod, "two-steps", 1, "two-steps-2"),
+				"two-steps-2": newTestNode("two-steps-2", "[1]", wfv1.NodeTypeStepGroup, "two-steps", 2, "two-steps-3"),
+				"two-steps-3": newTestNode("two-steps-3", "hello2", wfv1.NodeTypePod, "two-steps", 3),
+			},
+		},
+	}
+	assert.False(t, isContainerSetNode(wf, wf.Status.Nodes["two-steps-1"]))
+
+	lines := strings.Split(strings.TrimSpace(renderTree(wf)), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Contains(t, lines[0], "two-steps")
+		assert.True(t, strings.HasPrefix(lines[1], " ├---"), lines[1])
+		assert.Contains(t, lines[1], "hello1")
+		assert.Contains(t, lines[1], "two-steps-1")
+		assert.True(t, strings.HasPrefix(lines[2], " └---"), lines[2])
+		assert.Contains(t, lines[2], "hello2")
+		assert.Contains(t, lines[2], "two-steps-3")
+	}
+}
+
+// TestRenderContainerSet verifies the containers of a container set are rendered under their pod,
+// and the next step next to it
+func TestRenderContainerSet(t *testing.T) {
+	wf := &wfv1.Managed{
+		ObjectMeta: metav1.ObjectMeta{Name: "set"},
+		Status: wfv1.ManagedStatus{
+			Nodes: map[string]wfv1.NodeStatus{
+				"set":        newTestNode("set", "set", wfv1.NodeTypeSteps, "", 0, "set-0"),
+				"set-0":      newTestNode("set-0", "[0]", wfv1.NodeTypeStepGroup, "set", 0, "set-1"),
+				"set-1":      newTestNode("set-1", "build", wfv1.NodeTypePod, "set", 1, "set-1-main", "set-2"),
+				"set-1-main": newTestNode("set-1-main", "main", wfv1.NodeTypeContainer, "set", 1),
+				"set-2":      newTestNode("set-2", "[1]", wfv1.NodeTypeStepGroup, "set", 2, "set-3"),
+				"set-3":      newTestNode("set-3", "test", wfv1.NodeTypePod, "set", 3),
+			},
+		},
+	}
+	assert.True(t, isContainerSetNode(wf, wf.Status.Nodes["set-1"]))
+
+	lines := strings.Split(strings.TrimSpace(renderTree(wf)), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.True(t, strings.HasPrefix(lines[1], " ├---"), lines[1])
+		assert.Contains(t, lines[1], "build")
+		assert.True(t, strings.HasPrefix(lines[2], " |   └-"), lines[2])
+		assert.Contains(t, lines[2], "main")
+		assert.True(t, strings.HasPrefix(lines[3], " └---"), lines[3])
+		assert.Contains(t, lines[3], "test")
+	}
+}
+
+// TestRenderInitContainerFailed verifies the failure of a user init container is rendered distinctly
+// from a failure of the main container
+func TestRenderInitContainerFailed(t *testing.T) {
+	node := newTestNode("init-fail", "init-fail", wfv1.NodeTypePod, "", 0)
+	node.Phase = wfv1.NodeFailed
+	node.FailureReason = wfv1.NodeInitContainerFailed
+	node.Message = "init container 'wait-for-db' failed with exit code 2"
+	wf := &wfv1.Managed{
+		ObjectMeta: metav1.ObjectMeta{Name: "init-fail"},
+		Status:     wfv1.ManagedStatus{Nodes: map[string]wfv1.NodeStatus{"init-fail": node}},
+	}
+	out := renderTree(wf)
+	assert.Contains(t, out, "InitContainerFailed: init container 'wait-for-db' failed with exit code 2")
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	cmdutil "github.com/jbrette/kubext/util/cmd"
 	"github.com/jbrette/kubext/managed/common"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type submitFlags struct {
@@ -19,6 +21,7 @@ type submitFlags struct {
 	instanceID     string   // --instanceid
 	entrypoint     string   // --entrypoint
 	parameters     []string // --parameter
+	labels         string   // --labels
 	output         string   // --output
 	wait           bool     // --wait
 	serviceAccount string   // --serviceaccount
@@ -43,6 +46,7 @@ func NewSubmitCommand() *cobra.Command {
 	command.Flags().StringVar(&submitArgs.generateName, "generate-name", "", "override metadata.generateName")
 	command.Flags().StringVar(&submitArgs.entrypoint, "entrypoint", "", "override entrypoint")
 	command.Flags().StringArrayVarP(&submitArgs.parameters, "parameter", "p", []string{}, "pass an input parameter")
+	command.Flags().StringVarP(&submitArgs.labels, "labels", "l", "", "Comma separated labels to apply to the managed. Will override previous values.")
 	command.Flags().StringVarP(&submitArgs.output, "output", "o", "", "Output format. One of: name|json|yaml|wide")
 	command.Flags().BoolVarP(&submitArgs.wait, "wait", "w", false, "wait for the managed to complete")
 	command.Flags().StringVar(&submitArgs.serviceAccount, "serviceaccount", "", "run all pods in the managed using specified serviceaccount")
@@ -100,6 +104,23 @@ func submitManaged(wf *wfv1.Managed, submitArgs *submitFlags) (string, error) {
 	if submitArgs.serviceAccount != "" {
 		wf.Spec.ServiceAccountName = submitArgs.serviceAccount
 	}
+	if submitArgs.labels != "" {
+		passedLabels, err := labels.ConvertSelectorToLabelsMap(submitArgs.labels)
+		if err != nil {
+			return "", fmt.Errorf("expected labels of the form: NAME1=VALUE1,NAME2=VALUE2. Received: %s: %v", submitArgs.labels, err)
+		}
+		wfLabels := wf.GetLabels()
+		if wfLabels == nil {
+			wfLabels = make(map[string]string)
+		}
+		for k, v := range passedLabels {
+			if common.IsReservedKey(k) {
+				return "", fmt.Errorf("label '%s' is reserved", k)
+			}
+			wfLabels[k] = v
+		}
+		wf.SetLabels(wfLabels)
+	}
 	if submitArgs.instanceID != "" {
 		labels := wf.GetLabels()
 		if labels == nil {
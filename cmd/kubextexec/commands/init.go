@@ -23,6 +23,7 @@ var initCmd = &cobra.Command{
 
 func loadArtifacts() error {
 	wfExecutor := initExecutor()
+	defer shutdownTracing()
 	defer wfExecutor.HandleError()
 	defer stats.LogStats()
 
@@ -13,7 +13,7 @@ func init() {
 }
 
 var resourceCmd = &cobra.Command{
-	Use:   "resource (get|create|apply|delete) MANIFEST",
+	Use:   "resource (get|create|apply|server-side-apply|patch|replace|delete) MANIFEST",
 	Short: "update a resource and wait for resource conditions",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
@@ -29,23 +29,24 @@ var resourceCmd = &cobra.Command{
 
 func execResource(action string) error {
 	wfExecutor := initExecutor()
+	defer shutdownTracing()
 	defer wfExecutor.HandleError()
 	err := wfExecutor.StageFiles()
 	if err != nil {
 		wfExecutor.AddError(err)
 		return err
 	}
-	resourceName, err := wfExecutor.ExecResource(action, common.ExecutorResourceManifestPath)
+	objs, err := wfExecutor.ExecResource(action, common.ExecutorResourceManifestPath)
 	if err != nil {
 		wfExecutor.AddError(err)
 		return err
 	}
-	err = wfExecutor.WaitResource(resourceName)
+	err = wfExecutor.WaitResource(objs)
 	if err != nil {
 		wfExecutor.AddError(err)
 		return err
 	}
-	err = wfExecutor.SaveResourceParameters(resourceName)
+	err = wfExecutor.SaveResourceParameters(objs)
 	if err != nil {
 		wfExecutor.AddError(err)
 		return err
@@ -5,12 +5,16 @@ import (
 
 	"github.com/jbrette/kubext"
 	"github.com/jbrette/kubext/util/cmd"
+	"github.com/jbrette/kubext/util/tracing"
 	"github.com/jbrette/kubext/managed/common"
 	"github.com/jbrette/kubext/managed/executor"
 	"github.com/jbrette/kubext/managed/executor/docker"
+	"github.com/jbrette/kubext/managed/executor/k8sapi"
+	"github.com/jbrette/kubext/managed/executor/pns"
 	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -24,16 +28,23 @@ const (
 var (
 	// GlobalArgs hold global CLI flags
 	GlobalArgs globalFlags
+
+	// shutdownTracing flushes the spans of the executor and must be called before exiting
+	shutdownTracing = func() {}
 )
 
 type globalFlags struct {
 	podAnnotationsPath string // --pod-annotations
 	kubeConfig         string // --kubeconfig
+	logFormat          string // --log-format
+	tracing            tracing.Config
 }
 
 func init() {
 	RootCmd.PersistentFlags().StringVar(&GlobalArgs.kubeConfig, "kubeconfig", "", "Kubernetes config (used when running outside of cluster)")
 	RootCmd.PersistentFlags().StringVar(&GlobalArgs.podAnnotationsPath, "pod-annotations", common.PodMetadataAnnotationsPath, "Pod annotations file from k8s downward API")
+	RootCmd.PersistentFlags().StringVar(&GlobalArgs.logFormat, "log-format", "text", "Set the logging format. One of: text|json")
+	tracing.AddFlags(RootCmd.PersistentFlags(), &GlobalArgs.tracing)
 	RootCmd.AddCommand(cmd.NewVersionCmd(CLIName))
 }
 
@@ -55,6 +66,12 @@ func getClientConfig(kubeconfig string) (*rest.Config, error) {
 }
 
 func initExecutor() *executor.ManagedExecutor {
+	cmd.SetLogFormatter(GlobalArgs.logFormat)
+	shutdown, err := tracing.Init(CLIName, GlobalArgs.tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	shutdownTracing = shutdown
 	podAnnotationsPath := common.PodMetadataAnnotationsPath
 
 	// Use the path specified from the flag
@@ -80,11 +97,33 @@ func initExecutor() *executor.ManagedExecutor {
 		log.Fatalf("Unable to determine pod namespace from environment variable %s", common.EnvVarNamespace)
 	}
 
-	wfExecutor := executor.NewExecutor(clientset, podName, namespace, podAnnotationsPath, &docker.DockerExecutor{})
+	var cre executor.ContainerRuntimeExecutor
+	switch os.Getenv(common.EnvVarContainerRuntimeExecutor) {
+	case common.ContainerRuntimeExecutorK8sAPI:
+		cre = k8sapi.NewK8sAPIExecutor(clientset, config, podName, namespace)
+	case common.ContainerRuntimeExecutorPNS:
+		cre = pns.NewPNSExecutor(clientset, config, podName, namespace)
+	default:
+		cre = &docker.DockerExecutor{}
+	}
+	wfExecutor := executor.NewExecutor(clientset, podName, namespace, podAnnotationsPath, cre)
+	wfExecutor.DynamicClientPool = dynamic.NewDynamicClientPool(config)
+	restConfig := rest.CopyConfig(config)
+	restConfig.ContentConfig = dynamic.ContentConfig()
+	wfExecutor.RESTClient, err = rest.UnversionedRESTClientFor(restConfig)
+	if err != nil {
+		panic(err.Error())
+	}
 	err = wfExecutor.LoadTemplate()
 	if err != nil {
 		panic(err.Error())
 	}
+	// Pods are named after their node ID
+	cmd.AddLogFields(log.Fields{
+		"nodeID":   podName,
+		"template": wfExecutor.Template.Name,
+		"podName":  podName,
+	})
 	yamlBytes, _ := yaml.Marshal(&wfExecutor.Template)
 	log.Infof("Executor (version: %s) initialized with template:\n%s", kubext.GetVersion(), string(yamlBytes))
 	return &wfExecutor
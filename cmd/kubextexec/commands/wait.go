@@ -25,6 +25,7 @@ var waitCmd = &cobra.Command{
 
 func waitContainer() error {
 	wfExecutor := initExecutor()
+	defer shutdownTracing()
 	defer wfExecutor.HandleError()
 	defer stats.LogStats()
 	stats.StartStatsTicker(5 * time.Minute)
@@ -40,6 +41,11 @@ func waitContainer() error {
 		wfExecutor.AddError(err)
 		return err
 	}
+	err = wfExecutor.SaveSidecarLogs()
+	if err != nil {
+		wfExecutor.AddError(err)
+		return err
+	}
 	// Saving output parameters
 	err = wfExecutor.SaveParameters()
 	if err != nil {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/pprof"
 	"os"
 	"strconv"
 	"time"
@@ -11,17 +13,25 @@ import (
 	wfclientset "github.com/jbrette/kubext/pkg/client/clientset/versioned"
 	cmdutil "github.com/jbrette/kubext/util/cmd"
 	"github.com/jbrette/kubext/util/stats"
+	"github.com/jbrette/kubext/util/tracing"
 	"github.com/jbrette/kubext/managed/common"
 	"github.com/jbrette/kubext/managed/controller"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	// load the gcp plugin (required to authenticate against GKE clusters).
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	// load the oidc plugin (required to authenticate with OpenID Connect).
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -40,7 +50,25 @@ type rootFlags struct {
 	kubeConfig string // --kubeconfig
 	configMap  string // --configmap
 	logLevel   string // --loglevel
+	logFormat  string // --log-format
 	glogLevel  int    // --gloglevel
+	healthPort int    // --health-port
+	pprof      bool   // --pprof
+
+	managedWorkers      int           // --managed-workers
+	podWorkers          int           // --pod-workers
+	qps                 float32       // --qps
+	burst               int           // --burst
+	managedResyncPeriod time.Duration // --managed-resync-period
+	podResyncPeriod     time.Duration // --pod-resync-period
+	maxOperationTime    time.Duration // --max-operation-time
+
+	leaderElect              bool          // --leader-elect
+	leaderElectLockName      string        // --leader-elect-lock-name
+	leaderElectIdentity      string        // --leader-elect-identity
+	leaderElectLeaseDuration time.Duration // --leader-elect-lease-duration
+	leaderElectRenewDeadline time.Duration // --leader-elect-renew-deadline
+	leaderElectRetryPeriod   time.Duration // --leader-elect-retry-period
 }
 
 var (
@@ -53,7 +81,23 @@ func init() {
 	RootCmd.Flags().StringVar(&rootArgs.kubeConfig, "kubeconfig", "", "Kubernetes config (used when running outside of cluster)")
 	RootCmd.Flags().StringVar(&rootArgs.configMap, "configmap", common.DefaultConfigMapName(common.DefaultControllerDeploymentName), "Name of K8s configmap to retrieve managed controller configuration")
 	RootCmd.Flags().StringVar(&rootArgs.logLevel, "loglevel", "info", "Set the logging level. One of: debug|info|warn|error")
+	RootCmd.Flags().StringVar(&rootArgs.logFormat, "log-format", "text", "Set the logging format. One of: text|json")
 	RootCmd.Flags().IntVar(&rootArgs.glogLevel, "gloglevel", 0, "Set the glog logging level")
+	RootCmd.Flags().IntVar(&rootArgs.healthPort, "health-port", 6060, "Port on which to serve the /healthz and /readyz endpoints")
+	RootCmd.Flags().BoolVar(&rootArgs.pprof, "pprof", false, "Serve the /debug/pprof endpoints on the health port")
+	RootCmd.Flags().IntVar(&rootArgs.managedWorkers, "managed-workers", controller.DefaultManagedWorkers, "Number of managed workers, unless set by managedWorkers in the configmap")
+	RootCmd.Flags().IntVar(&rootArgs.podWorkers, "pod-workers", controller.DefaultPodWorkers, "Number of pod workers, unless set by podWorkers in the configmap")
+	RootCmd.Flags().Float32Var(&rootArgs.qps, "qps", controller.DefaultQPS, "Maximum queries per second to the K8s API, unless set by qps in the configmap")
+	RootCmd.Flags().IntVar(&rootArgs.burst, "burst", controller.DefaultBurst, "Maximum burst of queries to the K8s API, unless set by burst in the configmap")
+	RootCmd.Flags().DurationVar(&rootArgs.managedResyncPeriod, "managed-resync-period", controller.DefaultManagedResyncPeriod, "Resync period of the managed informer, unless set by managedResyncPeriod in the configmap")
+	RootCmd.Flags().DurationVar(&rootArgs.podResyncPeriod, "pod-resync-period", controller.DefaultPodResyncPeriod, "Resync period of the pod informer, unless set by podResyncPeriod in the configmap")
+	RootCmd.Flags().DurationVar(&rootArgs.maxOperationTime, "max-operation-time", controller.DefaultMaxOperationTime, "Maximum time a managed operation runs before being requeued, unless set by maxOperationTime in the configmap")
+	RootCmd.Flags().BoolVar(&rootArgs.leaderElect, "leader-elect", false, "Enable leader election so that only one of several controller replicas processes manageds")
+	RootCmd.Flags().StringVar(&rootArgs.leaderElectLockName, "leader-elect-lock-name", common.DefaultControllerDeploymentName+"-leader", "Name of the config map used as the leader election lock")
+	RootCmd.Flags().StringVar(&rootArgs.leaderElectIdentity, "leader-elect-identity", "", "Identity of this replica in leader election (defaults to the hostname)")
+	RootCmd.Flags().DurationVar(&rootArgs.leaderElectLeaseDuration, "leader-elect-lease-duration", 15*time.Second, "Duration that standby replicas wait before attempting to acquire an unrenewed leadership")
+	RootCmd.Flags().DurationVar(&rootArgs.leaderElectRenewDeadline, "leader-elect-renew-deadline", 10*time.Second, "Duration that the leader retries refreshing leadership before giving it up")
+	RootCmd.Flags().DurationVar(&rootArgs.leaderElectRetryPeriod, "leader-elect-retry-period", 2*time.Second, "Duration replicas wait between attempts to acquire or renew leadership")
 }
 
 // GetClientConfig return rest config, if path not specified, assume in cluster config
@@ -73,6 +117,7 @@ func main() {
 
 func Run(cmd *cobra.Command, args []string) {
 	cmdutil.SetLogLevel(rootArgs.logLevel)
+	cmdutil.SetLogFormatter(rootArgs.logFormat)
 	stats.RegisterStackDumper()
 	stats.StartStatsTicker(5 * time.Minute)
 
@@ -85,23 +130,113 @@ func Run(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-	config.Burst = 30
-	config.QPS = 20.0
+	config.Burst = rootArgs.burst
+	config.QPS = rootArgs.qps
 
 	kubeclientset := kubernetes.NewForConfigOrDie(config)
 	wflientset := wfclientset.NewForConfigOrDie(config)
 
 	// start a controller on instances of our custom resource
 	wfController := controller.NewManagedController(config, kubeclientset, wflientset, rootArgs.configMap)
+	wfController.Defaults = controller.ManagedControllerConfig{
+		ManagedWorkers:      rootArgs.managedWorkers,
+		PodWorkers:          rootArgs.podWorkers,
+		ManagedResyncPeriod: &metav1.Duration{Duration: rootArgs.managedResyncPeriod},
+		PodResyncPeriod:     &metav1.Duration{Duration: rootArgs.podResyncPeriod},
+		MaxOperationTime:    &metav1.Duration{Duration: rootArgs.maxOperationTime},
+	}
 	err = wfController.ResyncConfig()
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
+	go serveHealth(wfController)
+
+	shutdownTracing, err := tracing.Init(CLIName, wfController.Config.Tracing)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	defer shutdownTracing()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go wfController.Run(ctx, 8, 8)
+	if !rootArgs.leaderElect {
+		go wfController.Run(ctx)
 
-	// Wait forever
-	select {}
+		// Wait forever
+		select {}
+	}
+
+	// Every replica keeps warm informers, but only the leader runs workers
+	err = wfController.StartInformers(ctx)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	elector, err := newLeaderElector(kubeclientset, wfController.ConfigMapNS, func(stop <-chan struct{}) {
+		wfController.RunWorkers(stop)
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	elector.Run()
+}
+
+// serveHealth serves the health and readiness endpoints of the controller, as well as the
+// pprof endpoints if requested
+func serveHealth(wfController *controller.ManagedController) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", wfController.Healthz)
+	mux.HandleFunc("/readyz", wfController.Readyz)
+	if rootArgs.pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	addr := fmt.Sprintf(":%d", rootArgs.healthPort)
+	log.Infof("Serving health endpoints on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// newLeaderElector returns a leader elector which uses a config map in the controller's
+// namespace as its lock and invokes run when this replica acquires leadership
+func newLeaderElector(kubeclientset kubernetes.Interface, namespace string, run func(stop <-chan struct{})) (*leaderelection.LeaderElector, error) {
+	identity := rootArgs.leaderElectIdentity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		identity = hostname
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclientset.CoreV1().Events(namespace)})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: CLIName})
+
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock, namespace, rootArgs.leaderElectLockName, kubeclientset.CoreV1(), resourcelock.ResourceLockConfig{
+		Identity:      identity,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: rootArgs.leaderElectLeaseDuration,
+		RenewDeadline: rootArgs.leaderElectRenewDeadline,
+		RetryPeriod:   rootArgs.leaderElectRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				log.Infof("%s acquired leadership", identity)
+				run(stop)
+			},
+			OnStoppedLeading: func() {
+				// Exit so that a restarted replica rejoins the election with a clean state
+				log.Fatalf("%s lost leadership", identity)
+			},
+			OnNewLeader: func(leader string) {
+				log.Infof("Leader is %s", leader)
+			},
+		},
+	})
 }
@@ -70,3 +70,23 @@ func (a *ArtifactoryArtifactDriver) Save(path string, artifact *wfv1.Artifact) e
 	}
 	return nil
 }
+
+// SaveStream uploads the content read from reader to an artifactory URL, with a chunked request
+func (a *ArtifactoryArtifactDriver) SaveStream(reader io.Reader, artifact *wfv1.Artifact) error {
+	req, err := http.NewRequest(http.MethodPut, artifact.Artifactory.URL, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(a.Username, a.Password)
+	res, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.InternalErrorf("saving stream to artifactory failed with reason:%s", res.Status)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"io"
+
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 )
 
@@ -11,4 +13,8 @@ type ArtifactDriver interface {
 
 	// Save uploads the path to artifact destination
 	Save(path string, outputArtifact *wfv1.Artifact) error
+
+	// SaveStream uploads the content read from reader to artifact destination, without staging
+	// it on local disk
+	SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error
 }
@@ -0,0 +1,288 @@
+// Package azure implements an artifact driver for Azure Blob Storage, through the REST API of the
+// blob service. Requests are authorized with the access key of the storage account (Shared Key)
+// or with a shared access signature token. Artifacts larger than a block are uploaded as block
+// blobs one block at a time, so that streams are saved without being staged on local disk.
+package azure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbrette/kubext/errors"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiVersion is the version of the blob service REST API
+const apiVersion = "2019-12-12"
+
+// blockSize is the size of the blocks of block blobs. Artifacts smaller than a block are uploaded
+// in a single request.
+var blockSize = 8 * 1024 * 1024
+
+// AzureArtifactDriver is a driver for Azure Blob Storage
+type AzureArtifactDriver struct {
+	// AccountKey is the base64 encoded access key of the storage account
+	AccountKey string
+	// SASToken is a shared access signature token, used if no account key is set
+	SASToken string
+}
+
+// blobURL returns the URL of the blob of an artifact
+func blobURL(azArt *wfv1.AzureArtifact) (*url.URL, error) {
+	endpoint := azArt.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", azArt.Account)
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "invalid Azure endpoint %s: %v", endpoint, err)
+	}
+	u.Path = fmt.Sprintf("%s/%s/%s", u.Path, azArt.Container, azArt.Blob)
+	return u, nil
+}
+
+// newRequest returns an authorized request to the blob of an artifact, with query parameters and
+// headers
+func (a *AzureArtifactDriver) newRequest(method string, azArt *wfv1.AzureArtifact, query url.Values, header map[string]string, body []byte) (*http.Request, error) {
+	u, err := blobURL(azArt)
+	if err != nil {
+		return nil, err
+	}
+	if a.AccountKey == "" && a.SASToken != "" {
+		sas, err := url.ParseQuery(strings.TrimPrefix(a.SASToken, "?"))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "invalid Azure SAS token: %v", err)
+		}
+		for k, v := range sas {
+			query[k] = v
+		}
+	}
+	u.RawQuery = query.Encode()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", apiVersion)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	if a.AccountKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(a.AccountKey))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "invalid Azure account key: %v", err)
+		}
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(stringToSign(req, azArt.Account)))
+		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", azArt.Account, signature))
+	}
+	return req, nil
+}
+
+// stringToSign returns the string signed with the account key to authorize a request with Shared Key
+func stringToSign(req *http.Request, account string) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	lines := []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, superseded by x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}
+	var msHeaders []string
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name+":"+strings.Join(values, ","))
+		}
+	}
+	sort.Strings(msHeaders)
+	lines = append(lines, msHeaders...)
+
+	resource := "/" + account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		sort.Strings(values)
+		params = append(params, strings.ToLower(name)+":"+strings.Join(values, ","))
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		resource += "\n" + param
+	}
+	return strings.Join(append(lines, resource), "\n")
+}
+
+// do sends a request and checks its response has the expected status
+func do(req *http.Request, status int) (*http.Response, error) {
+	res, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	if res.StatusCode != status {
+		_ = res.Body.Close()
+		return nil, errors.InternalErrorf("%s %s failed with reason:%s", req.Method, req.URL.Path, res.Status)
+	}
+	return res, nil
+}
+
+// Load downloads a blob to a path
+func (a *AzureArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
+	log.Infof("Loading from Azure (container: %s, blob: %s) to %s", inputArtifact.Azure.Container, inputArtifact.Azure.Blob, path)
+	req, err := a.newRequest(http.MethodGet, inputArtifact.Azure, url.Values{}, nil, nil)
+	if err != nil {
+		return err
+	}
+	res, err := do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(f, res.Body)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// Save uploads a file to a blob
+func (a *AzureArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	log.Infof("Saving from %s to Azure (container: %s, blob: %s)", path, outputArtifact.Azure.Container, outputArtifact.Azure.Blob)
+	return a.upload(f, outputArtifact.Azure)
+}
+
+// SaveStream uploads the content read from reader to a blob
+func (a *AzureArtifactDriver) SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error {
+	log.Infof("Saving stream to Azure (container: %s, blob: %s)", outputArtifact.Azure.Container, outputArtifact.Azure.Blob)
+	return a.upload(reader, outputArtifact.Azure)
+}
+
+// upload uploads the content read from reader to a block blob. Content fitting in a block is put
+// in a single request. Larger content is put one block at a time, then committed as a block list.
+func (a *AzureArtifactDriver) upload(reader io.Reader, azArt *wfv1.AzureArtifact) error {
+	buf := make([]byte, blockSize)
+	var blockIDs []string
+	var size int64
+	for {
+		n, err := io.ReadFull(reader, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return errors.InternalWrapError(err)
+		}
+		if last && len(blockIDs) == 0 {
+			err = a.putBlob(azArt, buf[:n])
+			if err != nil {
+				return err
+			}
+			log.Infof("Saved %d bytes to %s", n, azArt.String())
+			return nil
+		}
+		if n > 0 {
+			// block IDs must have the same length within a blob
+			blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", len(blockIDs))))
+			err = a.putBlock(azArt, blockID, buf[:n])
+			if err != nil {
+				return err
+			}
+			blockIDs = append(blockIDs, blockID)
+			size += int64(n)
+		}
+		if last {
+			err = a.putBlockList(azArt, blockIDs)
+			if err != nil {
+				return err
+			}
+			log.Infof("Saved %d bytes in %d blocks to %s", size, len(blockIDs), azArt.String())
+			return nil
+		}
+	}
+}
+
+// putBlob creates a block blob with its content
+func (a *AzureArtifactDriver) putBlob(azArt *wfv1.AzureArtifact, data []byte) error {
+	req, err := a.newRequest(http.MethodPut, azArt, url.Values{}, map[string]string{"x-ms-blob-type": "BlockBlob"}, data)
+	if err != nil {
+		return err
+	}
+	res, err := do(req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// putBlock uploads a block of a block blob, to be committed by putBlockList
+func (a *AzureArtifactDriver) putBlock(azArt *wfv1.AzureArtifact, blockID string, data []byte) error {
+	query := url.Values{"comp": []string{"block"}, "blockid": []string{blockID}}
+	req, err := a.newRequest(http.MethodPut, azArt, query, nil, data)
+	if err != nil {
+		return err
+	}
+	res, err := do(req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// blockList is the body of a Put Block List request
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// putBlockList commits the blocks of a block blob
+func (a *AzureArtifactDriver) putBlockList(azArt *wfv1.AzureArtifact, blockIDs []string) error {
+	body, err := xml.Marshal(blockList{Latest: blockIDs})
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	body = append([]byte(xml.Header), body...)
+	req, err := a.newRequest(http.MethodPut, azArt, url.Values{"comp": []string{"blocklist"}}, nil, body)
+	if err != nil {
+		return err
+	}
+	res, err := do(req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
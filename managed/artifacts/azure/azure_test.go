@@ -0,0 +1,193 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// devAccount and devAccountKey are the well known development account of Azurite
+	devAccount    = "devstoreaccount1"
+	devAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	fakeSASToken  = "sv=2019-12-12&sr=c&sp=rcw&sig=c2lnbmF0dXJl"
+)
+
+// fakeAzurite is a stand-in for the blob service of the Azurite emulator, implementing the blob,
+// block and block list requests of block blobs in a single container
+type fakeAzurite struct {
+	*httptest.Server
+	t *testing.T
+
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+	puts   []string
+}
+
+func newFakeAzurite(t *testing.T) *fakeAzurite {
+	f := &fakeAzurite{
+		t:      t,
+		blobs:  make(map[string][]byte),
+		blocks: make(map[string][]byte),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// authorized checks a request carries a valid Shared Key signature or the SAS token
+func (f *fakeAzurite) authorized(r *http.Request) bool {
+	if r.URL.Query().Get("sig") != "" {
+		return r.URL.Query().Get("sig") == "c2lnbmF0dXJl"
+	}
+	key, _ := base64.StdEncoding.DecodeString(devAccountKey)
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(stringToSign(r, devAccount)))
+	return r.Header.Get("Authorization") == "SharedKey "+devAccount+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (f *fakeAzurite) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/"+devAccount+"/container/")
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := f.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+		return
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(f.t, err)
+		comp := r.URL.Query().Get("comp")
+		f.puts = append(f.puts, comp)
+		switch comp {
+		case "":
+			assert.Equal(f.t, "BlockBlob", r.Header.Get("x-ms-blob-type"))
+			f.blobs[name] = body
+		case "block":
+			f.blocks[r.URL.Query().Get("blockid")] = body
+		case "blocklist":
+			var list blockList
+			assert.NoError(f.t, xml.Unmarshal(body, &list))
+			var data []byte
+			for _, blockID := range list.Latest {
+				block, ok := f.blocks[blockID]
+				assert.True(f.t, ok, blockID)
+				data = append(data, block...)
+			}
+			f.blobs[name] = data
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+func newArtifact(endpoint string, blob string) *wfv1.Artifact {
+	return &wfv1.Artifact{
+		Name: "art",
+		ArtifactLocation: wfv1.ArtifactLocation{
+			Azure: &wfv1.AzureArtifact{
+				AzureContainer: wfv1.AzureContainer{Endpoint: endpoint + "/" + devAccount, Account: devAccount, Container: "container"},
+				Blob:           blob,
+			},
+		},
+	}
+}
+
+// TestStringToSign verifies the canonicalization of requests signed with Shared Key
+func TestStringToSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://myaccount.blob.core.windows.net/container/my%20dir/blob.tgz?comp=block&blockid=MDA%3D", strings.NewReader("hello"))
+	assert.NoError(t, err)
+	req.Header.Set("x-ms-version", "2019-12-12")
+	req.Header.Set("x-ms-date", "Mon, 19 Oct 2026 10:00:00 GMT")
+	expected := "PUT\n\n\n5\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 19 Oct 2026 10:00:00 GMT\nx-ms-version:2019-12-12\n" +
+		"/myaccount/container/my%20dir/blob.tgz\nblockid:MDA=\ncomp:block"
+	assert.Equal(t, expected, stringToSign(req, "myaccount"))
+}
+
+// TestSaveAndLoad verifies artifacts are uploaded in a single request or in blocks, and downloaded
+func TestSaveAndLoad(t *testing.T) {
+	blockSize = 1024
+	server := newFakeAzurite(t)
+	defer server.Close()
+	driver := &AzureArtifactDriver{AccountKey: devAccountKey}
+	dir, err := ioutil.TempDir("", "azure")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tests := []struct {
+		name string
+		size int
+		puts []string
+	}{
+		{"empty", 0, []string{""}},
+		{"single block", 1000, []string{""}},
+		{"several blocks", 2*blockSize + 10, []string{"block", "block", "block", "blocklist"}},
+		{"multiple of the block size", 2 * blockSize, []string{"block", "block", "blocklist"}},
+	}
+	for _, test := range tests {
+		data := make([]byte, test.size)
+		_, err = rand.Read(data)
+		assert.NoError(t, err)
+		art := newArtifact(server.URL, "my wf/"+test.name+".tgz")
+		server.puts = nil
+		err = driver.SaveStream(bytes.NewReader(data), art)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.puts, server.puts, test.name)
+
+		path := filepath.Join(dir, test.name)
+		err = driver.Load(art, path)
+		assert.NoError(t, err, test.name)
+		loaded, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(data, loaded), test.name)
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	err = ioutil.WriteFile(path, []byte("hello"), 0644)
+	assert.NoError(t, err)
+	err = driver.Save(path, newArtifact(server.URL, "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), server.blobs["file.txt"])
+
+	err = driver.Load(newArtifact(server.URL, "missing.tgz"), filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	driver = &AzureArtifactDriver{AccountKey: base64.StdEncoding.EncodeToString([]byte("wrong"))}
+	err = driver.Save(path, newArtifact(server.URL, "file.txt"))
+	assert.Error(t, err)
+}
+
+// TestSASToken verifies requests are authorized with a SAS token
+func TestSASToken(t *testing.T) {
+	server := newFakeAzurite(t)
+	defer server.Close()
+	driver := &AzureArtifactDriver{SASToken: "?" + fakeSASToken}
+	err := driver.SaveStream(strings.NewReader("hello"), newArtifact(server.URL, "hello.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), server.blobs["hello.txt"])
+}
@@ -0,0 +1,224 @@
+// Package gcs implements an artifact driver for Google Cloud Storage, through the JSON API of the
+// storage service. Objects are uploaded in chunks with resumable uploads, so that streams of
+// unknown size are saved without being staged on local disk.
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jbrette/kubext/errors"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// DefaultEndpoint is the endpoint of the Google Cloud Storage JSON API
+	DefaultEndpoint = "https://storage.googleapis.com"
+
+	// EnvVarEmulatorHost is the environment variable overriding the endpoint with the one of an
+	// emulator, such as fake-gcs-server, like the Google Cloud client libraries do
+	EnvVarEmulatorHost = "STORAGE_EMULATOR_HOST"
+
+	storageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	// statusResumeIncomplete is the status of the response to a chunk of a resumable upload
+	// which is not the last one
+	statusResumeIncomplete = 308
+)
+
+// chunkSize is the size of the chunks of resumable uploads. It must be a multiple of 256 KiB.
+var chunkSize = 16 * 1024 * 1024
+
+// GCSArtifactDriver is a driver for Google Cloud Storage
+type GCSArtifactDriver struct {
+	// ServiceAccountKey is the JSON key of the service account. Application default credentials
+	// are used if empty.
+	ServiceAccountKey string
+	// Endpoint of the storage API. Defaults to the emulator host if set, or Google Cloud Storage.
+	Endpoint string
+}
+
+// newClient returns an HTTP client authorized to access the storage API. Emulators are accessed
+// without authorization unless a service account key is set.
+func (g *GCSArtifactDriver) newClient(endpoint string) (*http.Client, error) {
+	ctx := context.Background()
+	if g.ServiceAccountKey != "" {
+		log.Debugf("using service account key credentials")
+		conf, err := google.JWTConfigFromJSON([]byte(g.ServiceAccountKey), storageScope)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "invalid GCS service account key: %v", err)
+		}
+		return conf.Client(ctx), nil
+	}
+	if endpoint != DefaultEndpoint {
+		log.Debugf("using no credentials for %s", endpoint)
+		return &http.Client{}, nil
+	}
+	log.Debugf("using application default credentials")
+	client, err := google.DefaultClient(ctx, storageScope)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	return client, nil
+}
+
+// endpoint returns the endpoint of the storage API
+func (g *GCSArtifactDriver) endpoint() string {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv(EnvVarEmulatorHost)
+	}
+	if endpoint == "" {
+		return DefaultEndpoint
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// Load downloads an object from a bucket to a path
+func (g *GCSArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
+	endpoint := g.endpoint()
+	client, err := g.newClient(endpoint)
+	if err != nil {
+		return err
+	}
+	objURL := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", endpoint, url.PathEscape(inputArtifact.GCS.Bucket), url.PathEscape(inputArtifact.GCS.Key))
+	log.Infof("Loading from GCS (bucket: %s, key: %s) to %s", inputArtifact.GCS.Bucket, inputArtifact.GCS.Key, path)
+	res, err := client.Get(objURL)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return errors.InternalErrorf("loading %s from GCS failed with reason:%s", inputArtifact.GCS.String(), res.Status)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(f, res.Body)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// Save uploads a file to a bucket
+func (g *GCSArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	log.Infof("Saving from %s to GCS (bucket: %s, key: %s)", path, outputArtifact.GCS.Bucket, outputArtifact.GCS.Key)
+	return g.upload(f, outputArtifact.GCS)
+}
+
+// SaveStream uploads the content read from reader to a bucket
+func (g *GCSArtifactDriver) SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error {
+	log.Infof("Saving stream to GCS (bucket: %s, key: %s)", outputArtifact.GCS.Bucket, outputArtifact.GCS.Key)
+	return g.upload(reader, outputArtifact.GCS)
+}
+
+// upload uploads the content read from reader to an object with a resumable upload, one chunk at
+// a time. The size of the object is sent with the last chunk, once the reader is exhausted.
+func (g *GCSArtifactDriver) upload(reader io.Reader, gcsArt *wfv1.GCSArtifact) error {
+	endpoint := g.endpoint()
+	client, err := g.newClient(endpoint)
+	if err != nil {
+		return err
+	}
+	sessionURL, err := startUpload(client, endpoint, gcsArt)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(reader, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return errors.InternalWrapError(err)
+		}
+		err = uploadChunk(client, sessionURL, offset, buf[:n], last)
+		if err != nil {
+			return errors.InternalErrorf("saving %s to GCS failed: %v", gcsArt.String(), err)
+		}
+		offset += int64(n)
+		if last {
+			log.Infof("Saved %d bytes to %s", offset, gcsArt.String())
+			return nil
+		}
+	}
+}
+
+// startUpload starts a resumable upload of an object and returns the URL of its session
+func startUpload(client *http.Client, endpoint string, gcsArt *wfv1.GCSArtifact) (string, error) {
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s", endpoint, url.PathEscape(gcsArt.Bucket), url.QueryEscape(gcsArt.Key))
+	req, err := http.NewRequest(http.MethodPost, uploadURL, nil)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.InternalErrorf("starting upload of %s to GCS failed with reason:%s", gcsArt.String(), res.Status)
+	}
+	sessionURL := res.Header.Get("Location")
+	if sessionURL == "" {
+		return "", errors.InternalErrorf("starting upload of %s to GCS failed: no session URL", gcsArt.String())
+	}
+	return sessionURL, nil
+}
+
+// uploadChunk uploads a chunk of a resumable upload, starting at offset. The upload must be
+// complete after the last chunk, and must have persisted the whole chunk otherwise.
+func uploadChunk(client *http.Client, sessionURL string, offset int64, chunk []byte, last bool) error {
+	end := offset + int64(len(chunk))
+	contentRange := "bytes */"
+	if len(chunk) > 0 {
+		contentRange = fmt.Sprintf("bytes %d-%d/", offset, end-1)
+	}
+	if last {
+		contentRange += strconv.FormatInt(end, 10)
+	} else {
+		contentRange += "*"
+	}
+	req, err := http.NewRequest(http.MethodPut, sessionURL, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Range", contentRange)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	switch {
+	case last && (res.StatusCode == http.StatusOK || res.StatusCode == http.StatusCreated):
+		return nil
+	case !last && res.StatusCode == statusResumeIncomplete:
+		persisted := res.Header.Get("Range")
+		if persisted != fmt.Sprintf("bytes=0-%d", end-1) {
+			return fmt.Errorf("chunk %s was not persisted (range: %s)", contentRange, persisted)
+		}
+		return nil
+	default:
+		return fmt.Errorf("chunk %s failed with reason:%s", contentRange, res.Status)
+	}
+}
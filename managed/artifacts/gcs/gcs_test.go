@@ -0,0 +1,213 @@
+package gcs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+const fakeToken = "fake-access-token"
+
+// fakeGCSServer is a stand-in for fake-gcs-server, implementing the media downloads and the
+// resumable uploads of the JSON API, and the token endpoint of service accounts
+type fakeGCSServer struct {
+	*httptest.Server
+	t *testing.T
+	// authorize requires requests to carry the access token of the token endpoint
+	authorize bool
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	sessions map[string]*bytes.Buffer
+	chunks   int
+}
+
+func newFakeGCSServer(t *testing.T, authorize bool) *fakeGCSServer {
+	f := &fakeGCSServer{
+		t:         t,
+		authorize: authorize,
+		objects:   make(map[string][]byte),
+		sessions:  make(map[string]*bytes.Buffer),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeGCSServer) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "%s", "token_type": "Bearer", "expires_in": 3600}`, fakeToken)
+		return
+	}
+	if f.authorize && r.Header.Get("Authorization") != "Bearer "+fakeToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/storage/v1/b/bucket/o/"):
+		name, err := url.PathUnescape(strings.TrimPrefix(path, "/storage/v1/b/bucket/o/"))
+		assert.NoError(f.t, err)
+		assert.Equal(f.t, "media", r.URL.Query().Get("alt"))
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPost && path == "/upload/storage/v1/b/bucket/o":
+		assert.Equal(f.t, "resumable", r.URL.Query().Get("uploadType"))
+		name := r.URL.Query().Get("name")
+		f.sessions[name] = &bytes.Buffer{}
+		w.Header().Set("Location", f.URL+"/upload/session/"+url.PathEscape(name))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/session/"):
+		f.chunks++
+		name := strings.TrimPrefix(r.URL.Path, "/upload/session/")
+		buf := f.sessions[name]
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(f.t, err)
+		var first, last int
+		var total string
+		contentRange := r.Header.Get("Content-Range")
+		if strings.HasPrefix(contentRange, "bytes */") {
+			total = strings.TrimPrefix(contentRange, "bytes */")
+			assert.Empty(f.t, body)
+		} else {
+			_, err = fmt.Sscanf(contentRange, "bytes %d-%d/%s", &first, &last, &total)
+			assert.NoError(f.t, err)
+			assert.Equal(f.t, buf.Len(), first)
+			assert.Equal(f.t, last-first+1, len(body))
+			buf.Write(body)
+		}
+		if total == "*" {
+			assert.Equal(f.t, 0, buf.Len()%(256*1024))
+			w.Header().Set("Range", "bytes=0-"+strconv.Itoa(buf.Len()-1))
+			w.WriteHeader(statusResumeIncomplete)
+			return
+		}
+		assert.Equal(f.t, strconv.Itoa(buf.Len()), total)
+		f.objects[name] = buf.Bytes()
+		delete(f.sessions, name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newServiceAccountKey returns the JSON key of a service account, whose tokens are issued by the
+// fake server
+func newServiceAccountKey(t *testing.T, tokenURI string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "kubext@project.iam.gserviceaccount.com",
+		"private_key_id": "1",
+		"private_key":    string(keyPEM),
+		"token_uri":      tokenURI,
+	})
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func newArtifact(key string) *wfv1.Artifact {
+	return &wfv1.Artifact{
+		Name: "art",
+		ArtifactLocation: wfv1.ArtifactLocation{
+			GCS: &wfv1.GCSArtifact{GCSBucket: wfv1.GCSBucket{Bucket: "bucket"}, Key: key},
+		},
+	}
+}
+
+// TestSaveAndLoad verifies artifacts are uploaded in chunks and downloaded with the token of the
+// service account
+func TestSaveAndLoad(t *testing.T) {
+	chunkSize = 256 * 1024
+	server := newFakeGCSServer(t, true)
+	defer server.Close()
+	driver := &GCSArtifactDriver{
+		ServiceAccountKey: newServiceAccountKey(t, server.URL+"/token"),
+		Endpoint:          server.URL,
+	}
+	dir, err := ioutil.TempDir("", "gcs")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"empty", 0, 1},
+		{"single chunk", 1000, 1},
+		{"several chunks", 2*chunkSize + 1000, 3},
+		{"multiple of the chunk size", 2 * chunkSize, 3},
+	}
+	for _, test := range tests {
+		data := make([]byte, test.size)
+		_, err = rand.Read(data)
+		assert.NoError(t, err)
+		art := newArtifact("my wf/" + test.name + ".tgz")
+		server.chunks = 0
+		err = driver.SaveStream(bytes.NewReader(data), art)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.chunks, server.chunks, test.name)
+
+		path := filepath.Join(dir, test.name)
+		err = driver.Load(art, path)
+		assert.NoError(t, err, test.name)
+		loaded, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(data, loaded), test.name)
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	err = ioutil.WriteFile(path, []byte("hello"), 0644)
+	assert.NoError(t, err)
+	err = driver.Save(path, newArtifact("file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), server.objects["file.txt"])
+
+	err = driver.Load(newArtifact("missing.tgz"), filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+// TestEmulatorHost verifies the emulator host is used without credentials
+func TestEmulatorHost(t *testing.T) {
+	server := newFakeGCSServer(t, false)
+	defer server.Close()
+	_ = os.Setenv(EnvVarEmulatorHost, strings.TrimPrefix(server.URL, "http://"))
+	defer func() { _ = os.Unsetenv(EnvVarEmulatorHost) }()
+	driver := &GCSArtifactDriver{}
+	assert.Equal(t, server.URL, driver.endpoint())
+
+	err := driver.SaveStream(strings.NewReader("hello"), newArtifact("hello.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), server.objects["hello.txt"])
+}
+
+// TestInvalidServiceAccountKey verifies an invalid service account key is reported
+func TestInvalidServiceAccountKey(t *testing.T) {
+	driver := &GCSArtifactDriver{ServiceAccountKey: "{}", Endpoint: "http://localhost"}
+	err := driver.SaveStream(strings.NewReader("hello"), newArtifact("hello.txt"))
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"io"
+
 	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/jbrette/kubext/managed/common"
@@ -18,3 +20,7 @@ func (h *HTTPArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) err
 func (h *HTTPArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
 	return errors.Errorf(errors.CodeBadRequest, "HTTP output artifacts unsupported")
 }
+
+func (h *HTTPArtifactDriver) SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error {
+	return errors.Errorf(errors.CodeBadRequest, "HTTP output artifacts unsupported")
+}
@@ -3,6 +3,7 @@ package raw
 import (
 	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"io"
 	"os"
 )
 
@@ -27,3 +28,8 @@ func (a *RawArtifactDriver) Load(artifact *wfv1.Artifact, path string) error {
 func (g *RawArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
 	return errors.Errorf(errors.CodeBadRequest, "Raw output artifacts unsupported")
 }
+
+// SaveStream is unsupported for raw output artifacts
+func (g *RawArtifactDriver) SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error {
+	return errors.Errorf(errors.CodeBadRequest, "Raw output artifacts unsupported")
+}
@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"io"
 	"strings"
 
 	"github.com/jbrette/kubext/errors"
@@ -81,3 +82,20 @@ func (s3Driver *S3ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifac
 	}
 	return nil
 }
+
+// SaveStream uploads the content read from reader to S3 with a multipart upload, since its size is
+// not known beforehand
+func (s3Driver *S3ArtifactDriver) SaveStream(reader io.Reader, outputArtifact *wfv1.Artifact) error {
+	minioClient, err := s3Driver.newMinioClient()
+	if err != nil {
+		return err
+	}
+	log.Infof("Streaming to s3 (endpoint: %s, bucket: %s, key: %s)",
+		outputArtifact.S3.Endpoint, outputArtifact.S3.Bucket, outputArtifact.S3.Key)
+
+	_, err = minioClient.PutObject(outputArtifact.S3.Bucket, outputArtifact.S3.Key, reader, "application/gzip")
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
@@ -38,6 +38,15 @@ const (
 	// DockerSockVolumeName is the volume name for the /var/run/docker.sock host path volume
 	DockerSockVolumeName = "docker-sock"
 
+	// ContainerRuntimeExecutorDocker is the container runtime executor using the docker daemon of
+	// the node, through host path volumes
+	ContainerRuntimeExecutorDocker = "docker"
+	// ContainerRuntimeExecutorK8sAPI is the container runtime executor using the Kubernetes API
+	ContainerRuntimeExecutorK8sAPI = "k8sapi"
+	// ContainerRuntimeExecutorPNS is the container runtime executor acting on the processes of the
+	// containers, through the process namespace shared by the containers of the pod
+	ContainerRuntimeExecutorPNS = "pns"
+
 	// AnnotationKeyNodeName is the pod metadata annotation key containing the managed node name
 	AnnotationKeyNodeName = managed.FullName + "/node-name"
 	// AnnotationKeyNodeMessage is the pod metadata annotation key the executor will use to
@@ -51,6 +60,16 @@ const (
 	// set by the controller and obeyed by the executor. For example, the controller will use this annotation to
 	// signal the executors of daemoned containers that it should terminate.
 	AnnotationKeyExecutionControl = managed.FullName + "/execution"
+	// AnnotationKeyTraceContext is the pod metadata annotation key containing the W3C trace context
+	// of the controller span which created the pod, as JSON. The executor parents its spans on it.
+	AnnotationKeyTraceContext = managed.FullName + "/trace-context"
+	// AnnotationKeyResourceRefs is the pod metadata annotation key containing the references, as
+	// JSON, to the resources created by a resource template
+	AnnotationKeyResourceRefs = managed.FullName + "/resource-refs"
+	// AnnotationKeyManagedUID is the annotation key containing the UID of the managed on the
+	// resources created by its resource templates. The controller only deletes the resources on
+	// completion of the managed if they carry it, or an owner reference to the managed.
+	AnnotationKeyManagedUID = managed.FullName + "/managed-uid"
 
 	// LabelKeyControllerInstanceID is the label the controller will carry forward to manageds/pod labels
 	// for the purposes of managed segregation
@@ -78,6 +97,15 @@ const (
 	ExecutorScriptSourcePath = "/kubext/staging/script"
 	// ExecutorResourceManifestPath is the path which init will write the a manifest file to for resource templates
 	ExecutorResourceManifestPath = "/tmp/manifest.yaml"
+	// ExecutorSignalsDir is the path of the emptydir which the wait container uses to signal the main container
+	ExecutorSignalsDir = "/kubext/signals"
+	// ExecutorSidecarsReadyPath is the file which the wait container creates once all sidecars with a readiness
+	// probe are ready. The main container waits for it before running its command.
+	ExecutorSidecarsReadyPath = ExecutorSignalsDir + "/sidecars-ready"
+	// ExecutorRootFSSecuredPath is the file which the wait container of the pns executor creates once it holds
+	// the root filesystem of the main containers, to read their outputs after they exited. The main containers
+	// with a command wait for it before running their command.
+	ExecutorRootFSSecuredPath = ExecutorSignalsDir + "/rootfs-secured"
 
 	// Various environment variables containing pod information exposed to the executor container(s)
 
@@ -87,6 +115,8 @@ const (
 	EnvVarPodName = "ARGO_POD_NAME"
 	// EnvVarNamespace contains the namespace of the pod (currently unused)
 	EnvVarNamespace = "ARGO_NAMESPACE"
+	// EnvVarContainerRuntimeExecutor contains the container runtime executor used by the executor
+	EnvVarContainerRuntimeExecutor = "ARGO_CONTAINER_RUNTIME_EXECUTOR"
 
 	// These are global variables that are added to the scope during template execution and can be referenced using {{}} syntax
 
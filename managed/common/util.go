@@ -1,12 +1,16 @@
 package common
 
 import (
+	"archive/tar"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,12 +21,15 @@ import (
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/jbrette/kubext/pkg/client/clientset/versioned/typed/managed/v1alpha1"
 	"github.com/jbrette/kubext/util/retry"
+	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 	"github.com/valyala/fasttemplate"
 	apiv1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	apivalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -34,12 +41,9 @@ import (
 // user specified volumeMounts in the template, and returns the deepest volumeMount
 // (if any).
 func FindOverlappingVolume(tmpl *wfv1.Template, path string) *apiv1.VolumeMount {
-	if tmpl.Container == nil {
-		return nil
-	}
 	var volMnt *apiv1.VolumeMount
 	deepestLen := 0
-	for _, mnt := range tmpl.Container.VolumeMounts {
+	for _, mnt := range GetTemplateVolumeMounts(tmpl) {
 		if !strings.HasPrefix(path, mnt.MountPath) {
 			continue
 		}
@@ -51,6 +55,31 @@ func FindOverlappingVolume(tmpl *wfv1.Template, path string) *apiv1.VolumeMount
 	return volMnt
 }
 
+// GetTemplateVolumeMounts returns the user specified volumeMounts shared by the containers which
+// run the user workload of a template: the container or script volumeMounts, or the volumeMounts
+// of a container set
+func GetTemplateVolumeMounts(tmpl *wfv1.Template) []apiv1.VolumeMount {
+	if tmpl.Container != nil {
+		return tmpl.Container.VolumeMounts
+	}
+	if tmpl.Script != nil {
+		return tmpl.Script.VolumeMounts
+	}
+	if tmpl.ContainerSet != nil {
+		return tmpl.ContainerSet.VolumeMounts
+	}
+	return nil
+}
+
+// GetMainContainerNames returns the names of the containers which run the user workload of a
+// template: the containers of a container set, or the main container
+func GetMainContainerNames(tmpl *wfv1.Template) []string {
+	if tmpl.ContainerSet != nil {
+		return tmpl.ContainerSet.GetContainerNames()
+	}
+	return []string{MainContainerName}
+}
+
 // KillPodContainer is a convenience function to issue a kill signal to a container in a pod
 // It gives a 15 second grace period before issuing SIGKILL
 // NOTE: this only works with containers that have sh
@@ -261,6 +290,77 @@ func RunCommand(name string, arg ...string) error {
 	return nil
 }
 
+// PipeStream returns a stream of the content written by write as it is read. write runs in the
+// background and fails once the stream is closed. Its error is returned by Read.
+func PipeStream(write func(w io.Writer) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(write(pw))
+	}()
+	return pr
+}
+
+// WriteTarball writes a tarball of a file or directory
+func WriteTarball(w io.Writer, sourcePath string) error {
+	tw := tar.NewWriter(w)
+	err := tarPath(tw, sourcePath)
+	if err != nil {
+		return err
+	}
+	err = tw.Close()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// tarPath writes a file or directory to a tarball, with paths relative to its parent directory.
+// Symbolic links are archived as such, without being followed.
+func tarPath(tw *tar.Writer, sourcePath string) error {
+	baseDir := filepath.Dir(sourcePath)
+	return filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return errors.InternalWrapError(err)
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		name, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(tw, f)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		return nil
+	})
+}
+
 const patchRetries = 5
 
 // AddPodAnnotation adds an annotation to pod
@@ -320,14 +420,118 @@ func IsValidManagedFieldName(name string) []string {
 	return errs
 }
 
+// IsReservedKey returns whether a label or annotation key is prefixed with the jbrette.io domain,
+// or one of its subdomains, which the controller and executor reserve for their own metadata
+func IsReservedKey(key string) bool {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	return parts[0] == managed.Group || strings.HasSuffix(parts[0], "."+managed.Group)
+}
+
+// HasSidecarReadinessGate returns whether the main container of a template waits for sidecars to
+// be ready, which is the case when a sidecar has a readiness probe. Resource templates do not
+// have a wait container to signal the main container and are therefore never gated.
+func HasSidecarReadinessGate(tmpl *wfv1.Template) bool {
+	if tmpl.Resource != nil {
+		return false
+	}
+	for _, sidecar := range tmpl.Sidecars {
+		if sidecar.ReadinessProbe != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRootFSGate returns whether a main container of a template waits for the wait container of the
+// pns executor to hold its root filesystem, which is the case of the main containers with a command.
+// The others cannot be gated, and their outputs are lost if they exit before their process is found.
+func HasRootFSGate(tmpl *wfv1.Template) bool {
+	switch {
+	case tmpl.Container != nil:
+		return len(tmpl.Container.Command) > 0
+	case tmpl.Script != nil:
+		return len(tmpl.Script.Command) > 0
+	case tmpl.ContainerSet != nil:
+		for _, ctr := range tmpl.ContainerSet.Containers {
+			if len(ctr.Command) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasContainerSetDependencies returns whether a container of the container set of a template
+// depends on another one
+func HasContainerSetDependencies(tmpl *wfv1.Template) bool {
+	if tmpl.ContainerSet == nil {
+		return false
+	}
+	for _, ctr := range tmpl.ContainerSet.Containers {
+		if len(ctr.Dependencies) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetContainerExitCodePath returns the file in which the wait container writes the exit code of a
+// container of a container set once it completes. Containers depending on it wait for this file.
+func GetContainerExitCodePath(containerName string) string {
+	return path.Join(ExecutorSignalsDir, containerName+".exitcode")
+}
+
 // IsPodTemplate returns whether the template corresponds to a pod
 func IsPodTemplate(tmpl *wfv1.Template) bool {
-	if tmpl.Container != nil || tmpl.Script != nil || tmpl.Resource != nil {
+	if tmpl.Container != nil || tmpl.Script != nil || tmpl.Resource != nil || tmpl.ContainerSet != nil {
 		return true
 	}
 	return false
 }
 
+// ApplyPodSpecPatch applies a strategic merge patch, in JSON or YAML, to a pod spec and returns
+// the patched pod spec
+func ApplyPodSpecPatch(podSpec apiv1.PodSpec, podSpecPatch string) (*apiv1.PodSpec, error) {
+	patchBytes, err := yaml.YAMLToJSON([]byte(podSpecPatch))
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "invalid podSpecPatch: %v", err)
+	}
+	podSpecBytes, err := json.Marshal(podSpec)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	patchedBytes, err := strategicpatch.StrategicMergePatch(podSpecBytes, patchBytes, apiv1.PodSpec{})
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "invalid podSpecPatch: %v", err)
+	}
+	var patched apiv1.PodSpec
+	err = json.Unmarshal(patchedBytes, &patched)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "invalid podSpecPatch: %v", err)
+	}
+	return &patched, nil
+}
+
+// GJSONLabels is an implementation of labels.Labels interface
+// which allows us to take advantage of k8s labels library
+// for the purposes of evaluating fail and success conditions
+type GJSONLabels struct {
+	JSON []byte
+}
+
+// Has returns whether the provided label exists.
+func (g GJSONLabels) Has(label string) bool {
+	return gjson.GetBytes(g.JSON, label).Exists()
+}
+
+// Get returns the value for the provided label.
+func (g GJSONLabels) Get(label string) string {
+	return gjson.GetBytes(g.JSON, label).String()
+}
+
 // GetTaskAncestry returns a list of taskNames which are ancestors of this task
 func GetTaskAncestry(taskName string, tasks []wfv1.DAGTask) []string {
 	taskByName := make(map[string]wfv1.DAGTask)
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"io"
+	"io/ioutil"
 	"testing"
 
+	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,3 +36,21 @@ func TestResubmitManagedWithOnExit(t *testing.T) {
 	_, ok := newWF.Status.Nodes[newWFOneExitID]
 	assert.False(t, ok)
 }
+
+// TestPipeStream verifies the content written is streamed as it is read, and write errors are returned by Read
+func TestPipeStream(t *testing.T) {
+	stream := PipeStream(func(w io.Writer) error {
+		_, err := w.Write([]byte("hello"))
+		return err
+	})
+	data, err := ioutil.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	stream = PipeStream(func(w io.Writer) error {
+		return errors.InternalError("copy failed")
+	})
+	_, err = ioutil.ReadAll(stream)
+	assert.EqualError(t, err, "copy failed")
+	assert.NoError(t, stream.Close())
+}
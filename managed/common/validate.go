@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/valyala/fasttemplate"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // wfValidationCtx is the context for validating a managed spec
@@ -63,6 +69,14 @@ func ValidateManaged(wf *wfv1.Managed, lint ...bool) error {
 	for _, param := range ctx.wf.Spec.Arguments.Parameters {
 		ctx.globalParams["managed.parameters."+param.Name] = placeholderValue
 	}
+	err = validatePodSpecPatch("spec.podSpecPatch", wf.Spec.PodSpecPatch)
+	if err != nil {
+		return err
+	}
+	err = validatePodMetadata("spec.podMetadata", wf.Spec.PodMetadata)
+	if err != nil {
+		return err
+	}
 	if ctx.wf.Spec.Entrypoint == "" {
 		return errors.New(errors.CodeBadRequest, "spec.entrypoint is required")
 	}
@@ -136,17 +150,17 @@ func (ctx *wfValidationCtx) validateTemplate(tmpl *wfv1.Template, args wfv1.Argu
 // validateTemplateType validates that only one template type is defined
 func validateTemplateType(tmpl *wfv1.Template) error {
 	numTypes := 0
-	for _, tmplType := range []interface{}{tmpl.Container, tmpl.Steps, tmpl.Script, tmpl.Resource, tmpl.DAG, tmpl.Suspend} {
+	for _, tmplType := range []interface{}{tmpl.Container, tmpl.Steps, tmpl.Script, tmpl.Resource, tmpl.DAG, tmpl.Suspend, tmpl.ContainerSet, tmpl.HTTP} {
 		if !reflect.ValueOf(tmplType).IsNil() {
 			numTypes++
 		}
 	}
 	switch numTypes {
 	case 0:
-		return errors.New(errors.CodeBadRequest, "template type unspecified. choose one of: container, steps, script, resource, dag, suspend")
+		return errors.New(errors.CodeBadRequest, "template type unspecified. choose one of: container, steps, script, resource, dag, suspend, containerSet, http")
 	case 1:
 	default:
-		return errors.New(errors.CodeBadRequest, "multiple template types specified. choose one of: container, steps, script, resource, dag, suspend")
+		return errors.New(errors.CodeBadRequest, "multiple template types specified. choose one of: container, steps, script, resource, dag, suspend, containerSet, http")
 	}
 	return nil
 }
@@ -164,7 +178,7 @@ func validateInputs(tmpl *wfv1.Template) (map[string]interface{}, error) {
 	for _, param := range tmpl.Inputs.Parameters {
 		scope[fmt.Sprintf("inputs.parameters.%s", param.Name)] = true
 	}
-	isLeaf := tmpl.Container != nil || tmpl.Script != nil
+	isLeaf := tmpl.Container != nil || tmpl.Script != nil || tmpl.ContainerSet != nil
 	for _, art := range tmpl.Inputs.Artifacts {
 		artRef := fmt.Sprintf("inputs.artifacts.%s", art.Name)
 		scope[artRef] = true
@@ -174,7 +188,7 @@ func validateInputs(tmpl *wfv1.Template) (map[string]interface{}, error) {
 			}
 		} else {
 			if art.Path != "" {
-				return nil, errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.path only valid in container/script templates", tmpl.Name, artRef)
+				return nil, errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.path only valid in container/script/containerSet templates", tmpl.Name, artRef)
 			}
 		}
 		if art.From != "" {
@@ -185,6 +199,10 @@ func validateInputs(tmpl *wfv1.Template) (map[string]interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
+		err = validateArchiveStrategy(errPrefix, art.Archive)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return scope, nil
 }
@@ -195,10 +213,57 @@ func validateArtifactLocation(errPrefix string, art wfv1.Artifact) error {
 			return errors.Errorf(errors.CodeBadRequest, "%s.git.repo is required", errPrefix)
 		}
 	}
+	if art.GCS != nil {
+		if art.GCS.Bucket == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.gcs.bucket is required", errPrefix)
+		}
+		if art.GCS.Key == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.gcs.key is required", errPrefix)
+		}
+	}
+	if art.Azure != nil {
+		if art.Azure.Account == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azure.account is required", errPrefix)
+		}
+		if art.Azure.Container == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azure.container is required", errPrefix)
+		}
+		if art.Azure.Blob == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azure.blob is required", errPrefix)
+		}
+		if art.Azure.AccountKeySecret != nil && art.Azure.SASTokenSecret != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azure must specify at most one of accountKeySecret or sasTokenSecret", errPrefix)
+		}
+	}
 	// TODO: validate other artifact locations
 	return nil
 }
 
+// validateArchiveStrategy ensures at most one archive is set, with a valid compression level
+func validateArchiveStrategy(errPrefix string, archive *wfv1.ArchiveStrategy) error {
+	if archive == nil {
+		return nil
+	}
+	numArchives := 0
+	if archive.Tar != nil {
+		numArchives++
+		level := archive.Tar.CompressionLevel
+		if level != nil && (*level < 0 || *level > 9) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.archive.tar.compressionLevel must be between 0 and 9", errPrefix)
+		}
+	}
+	if archive.None != nil {
+		numArchives++
+	}
+	if archive.Zstd != nil {
+		numArchives++
+	}
+	if numArchives > 1 {
+		return errors.Errorf(errors.CodeBadRequest, "%s.archive must specify at most one of tar, none or zstd", errPrefix)
+	}
+	return nil
+}
+
 // resolveAllVariables is a helper to ensure all {{variables}} are resolveable from current scope
 func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 	var unresolvedErr error
@@ -224,9 +289,15 @@ func validateNonLeaf(tmpl *wfv1.Template) error {
 	if tmpl.ActiveDeadlineSeconds != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.activeDeadlineSeconds is only valid for leaf templates", tmpl.Name)
 	}
+	if tmpl.PodPendingTimeout != nil {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.podPendingTimeout is only valid for leaf templates", tmpl.Name)
+	}
 	if tmpl.RetryStrategy != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy is only valid for container templates", tmpl.Name)
 	}
+	if len(tmpl.InitContainers) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.initContainers is only valid for leaf templates", tmpl.Name)
+	}
 	return nil
 }
 
@@ -239,14 +310,18 @@ func validateLeaf(scope map[string]interface{}, tmpl *wfv1.Template) error {
 	if err != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s: %s", tmpl.Name, err.Error())
 	}
-	if tmpl.Container != nil {
+	if tmpl.Container != nil || tmpl.ContainerSet != nil {
 		// Ensure there are no collisions with volume mountPaths and artifact load paths
+		volMountsField := "container.volumeMounts"
+		if tmpl.ContainerSet != nil {
+			volMountsField = "containerSet.volumeMounts"
+		}
 		mountPaths := make(map[string]string)
-		for i, volMount := range tmpl.Container.VolumeMounts {
+		for i, volMount := range GetTemplateVolumeMounts(tmpl) {
 			if prev, ok := mountPaths[volMount.MountPath]; ok {
-				return errors.Errorf(errors.CodeBadRequest, "templates.%s.container.volumeMounts[%d].mountPath '%s' already mounted in %s", tmpl.Name, i, volMount.MountPath, prev)
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s[%d].mountPath '%s' already mounted in %s", tmpl.Name, volMountsField, i, volMount.MountPath, prev)
 			}
-			mountPaths[volMount.MountPath] = fmt.Sprintf("container.volumeMounts.%s", volMount.Name)
+			mountPaths[volMount.MountPath] = fmt.Sprintf("%s.%s", volMountsField, volMount.Name)
 		}
 		for i, art := range tmpl.Inputs.Artifacts {
 			if prev, ok := mountPaths[art.Path]; ok {
@@ -255,17 +330,258 @@ func validateLeaf(scope map[string]interface{}, tmpl *wfv1.Template) error {
 			mountPaths[art.Path] = fmt.Sprintf("inputs.artifacts.%s", art.Name)
 		}
 	}
+	for _, sidecar := range tmpl.Sidecars {
+		if sidecar.ReadinessTimeoutSeconds != nil && *sidecar.ReadinessTimeoutSeconds <= 0 {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.sidecars.%s.readinessTimeoutSeconds must be a positive integer > 0", tmpl.Name, sidecar.Name)
+		}
+	}
+	if tmpl.Container != nil && len(tmpl.Container.Command) == 0 && HasSidecarReadinessGate(tmpl) {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.container.command is required when a sidecar has a readinessProbe", tmpl.Name)
+	}
+	if tmpl.ContainerSet != nil {
+		err = validateContainerSet(tmpl)
+		if err != nil {
+			return err
+		}
+	}
+	if tmpl.Resource != nil {
+		err = validateResource(tmpl)
+		if err != nil {
+			return err
+		}
+	}
+	if tmpl.HTTP != nil {
+		err = validateHTTP(tmpl)
+		if err != nil {
+			return err
+		}
+	}
+	for i, initCtr := range tmpl.InitContainers {
+		switch initCtr.Name {
+		case "":
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.initContainers[%d].name is required", tmpl.Name, i)
+		case MainContainerName, InitContainerName, WaitContainerName:
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.initContainers[%d].name '%s' is reserved", tmpl.Name, i, initCtr.Name)
+		}
+	}
 	if tmpl.ActiveDeadlineSeconds != nil {
 		if *tmpl.ActiveDeadlineSeconds <= 0 {
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.activeDeadlineSeconds must be a positive integer > 0", tmpl.Name)
 		}
 	}
+	if tmpl.PodPendingTimeout != nil {
+		if *tmpl.PodPendingTimeout <= 0 {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.podPendingTimeout must be a positive integer > 0", tmpl.Name)
+		}
+	}
+	if tmpl.RetryStrategy != nil {
+		for i, reason := range tmpl.RetryStrategy.RetryOn {
+			switch reason {
+			case wfv1.NodeEvicted, wfv1.NodeOOMKilled, wfv1.NodePreempted, wfv1.NodeDeadlineExceeded, wfv1.NodeDeleted, wfv1.NodeLost, wfv1.NodeInitContainerFailed:
+			default:
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy.retryOn[%d] unknown failure reason '%s'", tmpl.Name, i, reason)
+			}
+		}
+	}
+	err = validatePodSpecPatch(fmt.Sprintf("templates.%s.podSpecPatch", tmpl.Name), tmpl.PodSpecPatch)
+	if err != nil {
+		return err
+	}
 	if tmpl.Parallelism != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.parallelism is only valid for steps and dag templates", tmpl.Name)
 	}
 	return nil
 }
 
+// validateContainerSet verifies the containers of a container set have unique names, one of
+// which is main, and that their dependencies are defined and acyclic
+func validateContainerSet(tmpl *wfv1.Template) error {
+	containers := tmpl.ContainerSet.Containers
+	if len(containers) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.containerSet.containers must have at least one container", tmpl.Name)
+	}
+	dependencies := make(map[string][]string)
+	names := make([]string, 0, len(containers))
+	for i, ctr := range containers {
+		switch ctr.Name {
+		case "":
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.containerSet.containers[%d].name is required", tmpl.Name, i)
+		case InitContainerName, WaitContainerName:
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.containerSet.containers[%d].name '%s' is reserved", tmpl.Name, i, ctr.Name)
+		}
+		if _, ok := dependencies[ctr.Name]; ok {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.containerSet.containers[%d].name '%s' is not unique", tmpl.Name, i, ctr.Name)
+		}
+		dependencies[ctr.Name] = ctr.Dependencies
+		names = append(names, ctr.Name)
+	}
+	if tmpl.ContainerSet.GetContainer(MainContainerName) == nil {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.containerSet.containers must have a container named '%s'", tmpl.Name, MainContainerName)
+	}
+	for _, ctr := range containers {
+		for j, depName := range ctr.Dependencies {
+			if _, ok := dependencies[depName]; !ok {
+				return errors.Errorf(errors.CodeBadRequest,
+					"templates.%s.containerSet.containers.%s.dependencies[%d] dependency '%s' not defined",
+					tmpl.Name, ctr.Name, j, depName)
+			}
+		}
+		// the command of a container is wrapped to wait for its dependencies and the sidecars
+		if len(ctr.Command) == 0 && (len(ctr.Dependencies) > 0 || HasSidecarReadinessGate(tmpl)) {
+			return errors.Errorf(errors.CodeBadRequest,
+				"templates.%s.containerSet.containers.%s.command is required when the container has dependencies or a sidecar has a readinessProbe",
+				tmpl.Name, ctr.Name)
+		}
+	}
+	return verifyNoDependencyCycles(fmt.Sprintf("templates.%s.containerSet.containers", tmpl.Name), names, dependencies)
+}
+
+// validateHTTP verifies the request of an http template. The url and the success condition are
+// only verified when they do not reference variables.
+func validateHTTP(tmpl *wfv1.Template) error {
+	httpTmpl := tmpl.HTTP
+	if tmpl.RetryStrategy != nil {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy is only valid for container templates", tmpl.Name)
+	}
+	switch httpTmpl.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.method '%s' is invalid", tmpl.Name, httpTmpl.Method)
+	}
+	if httpTmpl.URL == "" {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.url is required", tmpl.Name)
+	}
+	if !strings.Contains(httpTmpl.URL, "{{") {
+		u, err := url.Parse(httpTmpl.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.url '%s' must be an http or https url", tmpl.Name, httpTmpl.URL)
+		}
+	}
+	for i, header := range httpTmpl.Headers {
+		if header.Name == "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.headers[%d].name is required", tmpl.Name, i)
+		}
+		if header.ValueFrom != nil {
+			if header.Value != "" {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.headers.%s cannot specify both value and valueFrom", tmpl.Name, header.Name)
+			}
+			if header.ValueFrom.SecretKeyRef == nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.headers.%s.valueFrom.secretKeyRef is required", tmpl.Name, header.Name)
+			}
+		}
+	}
+	if httpTmpl.SuccessCondition != "" && !strings.Contains(httpTmpl.SuccessCondition, "{{") {
+		_, err := labels.Parse(httpTmpl.SuccessCondition)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.successCondition '%s' failed to parse: %v", tmpl.Name, httpTmpl.SuccessCondition, err)
+		}
+	}
+	if httpTmpl.TimeoutSeconds != nil && *httpTmpl.TimeoutSeconds <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.http.timeoutSeconds must be a positive integer > 0", tmpl.Name)
+	}
+	return nil
+}
+
+// validateResource verifies the action of a resource template and that its options apply to
+// the action
+func validateResource(tmpl *wfv1.Template) error {
+	resTmpl := tmpl.Resource
+	switch resTmpl.Action {
+	case "get", "create", "apply", "server-side-apply", "patch", "replace", "delete":
+	case "":
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.action is required", tmpl.Name)
+	default:
+		if !strings.Contains(resTmpl.Action, "{{") {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.action '%s' is invalid. Must be one of: get, create, apply, server-side-apply, patch, replace, delete", tmpl.Name, resTmpl.Action)
+		}
+		// the options are verified against the action once it is substituted at runtime
+		return nil
+	}
+	if resTmpl.Action != "patch" {
+		if resTmpl.MergeStrategy != "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.mergeStrategy is only valid for the patch action", tmpl.Name)
+		}
+		if resTmpl.Patch != "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.patch is only valid for the patch action", tmpl.Name)
+		}
+	}
+	switch resTmpl.MergeStrategy {
+	case "", "strategic", "merge":
+	case "json":
+		if resTmpl.Patch == "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.patch is required by the json merge strategy", tmpl.Name)
+		}
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.mergeStrategy '%s' is invalid. Must be one of: strategic, merge, json", tmpl.Name, resTmpl.MergeStrategy)
+	}
+	if resTmpl.FieldManager != "" && resTmpl.Action != "server-side-apply" {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.fieldManager is only valid for the server-side-apply action", tmpl.Name)
+	}
+	if resTmpl.SetOwnerReference {
+		switch resTmpl.Action {
+		case "get", "patch", "delete":
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.setOwnerReference is only valid for the create, apply, server-side-apply and replace actions", tmpl.Name)
+		}
+	}
+	if resTmpl.Action != "delete" {
+		if resTmpl.PropagationPolicy != "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.propagationPolicy is only valid for the delete action", tmpl.Name)
+		}
+		if resTmpl.WaitForDeletion {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.waitForDeletion is only valid for the delete action", tmpl.Name)
+		}
+	}
+	switch resTmpl.ConditionAggregation {
+	case "", "all", "any":
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.conditionAggregation '%s' is invalid. Must be one of: all, any", tmpl.Name, resTmpl.ConditionAggregation)
+	}
+	if resTmpl.Timeout != "" && !strings.Contains(resTmpl.Timeout, "{{") {
+		timeout, err := time.ParseDuration(resTmpl.Timeout)
+		if err != nil || timeout <= 0 {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.timeout '%s' must be a positive duration, e.g. 5m", tmpl.Name, resTmpl.Timeout)
+		}
+	}
+	switch resTmpl.PropagationPolicy {
+	case "", string(metav1.DeletePropagationOrphan), string(metav1.DeletePropagationBackground), string(metav1.DeletePropagationForeground):
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.propagationPolicy '%s' is invalid. Must be one of: Orphan, Background, Foreground", tmpl.Name, resTmpl.PropagationPolicy)
+	}
+	return nil
+}
+
+// validatePodSpecPatch verifies a pod spec patch can be applied. Patches which reference
+// variables can only be fully verified once the variables are substituted at runtime.
+func validatePodSpecPatch(prefix string, podSpecPatch string) error {
+	if podSpecPatch == "" || strings.Contains(podSpecPatch, "{{") {
+		return nil
+	}
+	_, err := ApplyPodSpecPatch(apiv1.PodSpec{}, podSpecPatch)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%s: %s", prefix, err.Error())
+	}
+	return nil
+}
+
+// validatePodMetadata verifies the pod metadata of a managed does not set the labels and
+// annotations reserved by the controller and executor
+func validatePodMetadata(prefix string, metadata *wfv1.Metadata) error {
+	if metadata == nil {
+		return nil
+	}
+	for key := range metadata.Labels {
+		if IsReservedKey(key) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.labels.%s: label is reserved", prefix, key)
+		}
+	}
+	for key := range metadata.Annotations {
+		if IsReservedKey(key) {
+			return errors.Errorf(errors.CodeBadRequest, "%s.annotations.%s: annotation is reserved", prefix, key)
+		}
+	}
+	return nil
+}
+
 func validateArguments(prefix string, arguments wfv1.Arguments) error {
 	err := validateArgumentsFieldNames(prefix, arguments)
 	if err != nil {
@@ -385,7 +701,7 @@ func (ctx *wfValidationCtx) addOutputsToScope(templateName string, prefix string
 	if tmpl.Daemon != nil && *tmpl.Daemon {
 		scope[fmt.Sprintf("%s.ip", prefix)] = true
 	}
-	if tmpl.Script != nil {
+	if tmpl.Script != nil || tmpl.Resource != nil || tmpl.HTTP != nil {
 		scope[fmt.Sprintf("%s.outputs.result", prefix)] = true
 	}
 	for _, param := range tmpl.Outputs.Parameters {
@@ -422,7 +738,10 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.outputs %s", tmpl.Name, err.Error())
 	}
 
-	isLeaf := tmpl.Container != nil || tmpl.Script != nil
+	if tmpl.HTTP != nil && (len(tmpl.Outputs.Parameters) > 0 || len(tmpl.Outputs.Artifacts) > 0) {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.outputs of http templates are limited to the result", tmpl.Name)
+	}
+	isLeaf := tmpl.Container != nil || tmpl.Script != nil || tmpl.ContainerSet != nil
 	for _, art := range tmpl.Outputs.Artifacts {
 		artRef := fmt.Sprintf("outputs.artifacts.%s", art.Name)
 		if isLeaf {
@@ -431,7 +750,7 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 			}
 		} else {
 			if art.Path != "" {
-				return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.path only valid in container/script templates", tmpl.Name, artRef)
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.path only valid in container/script/containerSet templates", tmpl.Name, artRef)
 			}
 		}
 		if art.GlobalName != "" && !isParameter(art.GlobalName) {
@@ -440,6 +759,14 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 				return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s.globalName: %s", tmpl.Name, artRef, errs[0])
 			}
 		}
+		err = validateArtifactLocation(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), art)
+		if err != nil {
+			return err
+		}
+		err = validateArchiveStrategy(fmt.Sprintf("templates.%s.%s", tmpl.Name, artRef), art.Archive)
+		if err != nil {
+			return err
+		}
 	}
 	for _, param := range tmpl.Outputs.Parameters {
 		paramRef := fmt.Sprintf("templates.%s.outputs.parameters.%s", tmpl.Name, param.Name)
@@ -449,7 +776,7 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 		}
 		tmplType := tmpl.GetType()
 		switch tmplType {
-		case wfv1.TemplateTypeContainer, wfv1.TemplateTypeScript:
+		case wfv1.TemplateTypeContainer, wfv1.TemplateTypeScript, wfv1.TemplateTypeContainerSet:
 			if param.ValueFrom.Path == "" {
 				return errors.Errorf(errors.CodeBadRequest, "%s.path must be specified for %s templates", paramRef, tmplType)
 			}
@@ -493,6 +820,35 @@ func validateOutputParameter(paramRef string, param *wfv1.Parameter) error {
 	return nil
 }
 
+// ValidateOutputsOnVolumes verifies that the output parameters and artifacts of a template are
+// saved on a volume of its containers: a user specified volumeMount or an input artifact. This is
+// required by the executors which cannot read the filesystem of a terminated container, and read
+// the outputs from the volumes mounted to the wait container instead.
+func ValidateOutputsOnVolumes(tmpl *wfv1.Template, executor string) error {
+	onVolume := func(path string) bool {
+		if FindOverlappingVolume(tmpl, path) != nil {
+			return true
+		}
+		for _, art := range tmpl.Inputs.Artifacts {
+			if art.Path != "" && strings.HasPrefix(path, art.Path) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, param := range tmpl.Outputs.Parameters {
+		if param.ValueFrom != nil && param.ValueFrom.Path != "" && !onVolume(param.ValueFrom.Path) {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.outputs.parameters.%s: the %s executor requires outputs to be saved on a volume (e.g. an emptyDir) but %s is not", tmpl.Name, param.Name, executor, param.ValueFrom.Path)
+		}
+	}
+	for _, art := range tmpl.Outputs.Artifacts {
+		if art.Path != "" && !onVolume(art.Path) {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.outputs.artifacts.%s: the %s executor requires outputs to be saved on a volume (e.g. an emptyDir) but %s is not", tmpl.Name, art.Name, executor, art.Path)
+		}
+	}
+	return nil
+}
+
 // validateManagedFieldNames accepts a slice of structs and
 // verifies that the Name field of the structs are:
 // * unique
@@ -641,19 +997,29 @@ func validateDAGTargets(tmpl *wfv1.Template, nameToTask map[string]wfv1.DAGTask)
 
 // verifyNoCycles verifies there are no cycles in the DAG graph
 func verifyNoCycles(tmpl *wfv1.Template, nameToTask map[string]wfv1.DAGTask) error {
+	names := make([]string, 0, len(tmpl.DAG.Tasks))
+	dependencies := make(map[string][]string)
+	for _, task := range tmpl.DAG.Tasks {
+		names = append(names, task.Name)
+		dependencies[task.Name] = nameToTask[task.Name].Dependencies
+	}
+	return verifyNoDependencyCycles(fmt.Sprintf("templates.%s.tasks", tmpl.Name), names, dependencies)
+}
+
+// verifyNoDependencyCycles verifies there are no cycles in a dependency graph
+func verifyNoDependencyCycles(errPrefix string, names []string, dependencies map[string][]string) error {
 	visited := make(map[string]bool)
 	var noCyclesHelper func(taskName string, cycle []string) error
 	noCyclesHelper = func(taskName string, cycle []string) error {
 		if _, ok := visited[taskName]; ok {
 			return nil
 		}
-		task := nameToTask[taskName]
-		for _, depName := range task.Dependencies {
+		for _, depName := range dependencies[taskName] {
 			for _, name := range cycle {
 				if name == depName {
 					return errors.Errorf(errors.CodeBadRequest,
-						"templates.%s.tasks dependency cycle detected: %s->%s",
-						tmpl.Name, strings.Join(cycle, "->"), name)
+						"%s dependency cycle detected: %s->%s",
+						errPrefix, strings.Join(cycle, "->"), name)
 				}
 			}
 			cycle = append(cycle, depName)
@@ -667,8 +1033,8 @@ func verifyNoCycles(tmpl *wfv1.Template, nameToTask map[string]wfv1.DAGTask) err
 		return nil
 	}
 
-	for _, task := range tmpl.DAG.Tasks {
-		err := noCyclesHelper(task.Name, []string{})
+	for _, name := range names {
+		err := noCyclesHelper(name, []string{})
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"strings"
 	"testing"
 
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
@@ -511,6 +512,173 @@ func TestInvalidOutputArtName(t *testing.T) {
 	}
 }
 
+var outputArtifactArchives = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: output-artifact-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      artifacts:
+      - name: raw
+        path: /tmp/hello_world.txt
+        archive:
+          none: {}
+      - name: fast
+        path: /tmp
+        archive:
+          tar:
+            compressionLevel: 1
+      - name: zstd
+        path: /tmp
+        archive:
+          zstd: {}
+`
+
+var invalidOutputArtifactArchive = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: output-artifact-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      artifacts:
+      - name: out
+        path: /tmp/hello_world.txt
+        archive:
+          none: {}
+          zstd: {}
+`
+
+var invalidOutputArtifactCompressionLevel = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: output-artifact-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      artifacts:
+      - name: out
+        path: /tmp/hello_world.txt
+        archive:
+          tar:
+            compressionLevel: 10
+`
+
+func TestOutputArtifactArchive(t *testing.T) {
+	err := validate(outputArtifactArchives)
+	assert.Nil(t, err)
+	err = validate(invalidOutputArtifactArchive)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "at most one of tar, none or zstd")
+	}
+	err = validate(invalidOutputArtifactCompressionLevel)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "compressionLevel must be between 0 and 9")
+	}
+}
+
+var invalidGCSArtifact = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: gcs-artifact-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      artifacts:
+      - name: out
+        path: /tmp/hello_world.txt
+        gcs:
+          bucket: my-bucket
+          serviceAccountKeySecret:
+            name: my-gcs-credentials
+            key: serviceAccountKey
+`
+
+func TestGCSArtifactLocation(t *testing.T) {
+	err := validate(invalidGCSArtifact)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "gcs.key is required")
+	}
+	err = validate(strings.Replace(invalidGCSArtifact, "bucket: my-bucket", "key: hello_world.txt", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "gcs.bucket is required")
+	}
+	err = validate(strings.Replace(invalidGCSArtifact, "bucket: my-bucket", "bucket: my-bucket\n          key: hello_world.txt", 1))
+	assert.Nil(t, err)
+}
+
+var invalidAzureArtifact = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: azure-artifact-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      artifacts:
+      - name: out
+        path: /tmp/hello_world.txt
+        azure:
+          account: myaccount
+          container: my-container
+          accountKeySecret:
+            name: my-azure-credentials
+            key: accountKey
+`
+
+func TestAzureArtifactLocation(t *testing.T) {
+	err := validate(invalidAzureArtifact)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "azure.blob is required")
+	}
+	err = validate(strings.Replace(invalidAzureArtifact, "container: my-container", "blob: hello_world.txt", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "azure.container is required")
+	}
+	validAzureArtifact := strings.Replace(invalidAzureArtifact, "container: my-container", "container: my-container\n          blob: hello_world.txt", 1)
+	err = validate(validAzureArtifact)
+	assert.Nil(t, err)
+	err = validate(validAzureArtifact + "          sasTokenSecret:\n            name: my-azure-credentials\n            key: sasToken\n")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "at most one of accountKeySecret or sasTokenSecret")
+	}
+}
+
 var invalidOutputParamNames = `
 apiVersion: jbrette.io/v1alpha1
 kind: Managed
@@ -785,6 +953,54 @@ func TestValidActiveDeadlineSeconds(t *testing.T) {
 	}
 }
 
+var podPendingTimeout = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: pod-pending-timeout-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    podPendingTimeout: 0
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+func TestValidPodPendingTimeout(t *testing.T) {
+	err := validate(podPendingTimeout)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "podPendingTimeout must be a positive integer > 0")
+	}
+}
+
+var invalidRetryOn = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: invalid-retry-on-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    retryStrategy:
+      limit: 2
+      retryOn: [Evicted, Crashed]
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+func TestValidRetryOn(t *testing.T) {
+	err := validate(invalidRetryOn)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "retryOn[1] unknown failure reason 'Crashed'")
+	}
+}
+
 var leafWithParallelism = `
 apiVersion: jbrette.io/v1alpha1
 kind: Managed
@@ -1073,3 +1289,514 @@ func TestSpecArgumentNoValue(t *testing.T) {
 	err = ValidateManaged(wf)
 	assert.NotNil(t, err)
 }
+
+var invalidPodSpecPatch = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: invalid-pod-spec-patch-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    podSpecPatch: '{"priorityClassName": ["high"]}'
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+var podSpecPatchWithParam = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: pod-spec-patch-
+spec:
+  entrypoint: pass
+  podSpecPatch: |
+    hostAliases:
+    - ip: 127.0.0.1
+      hostnames: [foo.local]
+  templates:
+  - name: pass
+    inputs:
+      parameters:
+      - name: priority
+        value: high
+    podSpecPatch: '{"priorityClassName": "{{inputs.parameters.priority}}"}'
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+func TestValidPodSpecPatch(t *testing.T) {
+	err := validate(invalidPodSpecPatch)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.pass.podSpecPatch: invalid podSpecPatch")
+	}
+	err = validate(podSpecPatchWithParam)
+	assert.Nil(t, err)
+}
+
+var reservedPodMetadata = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: reserved-pod-metadata-
+spec:
+  entrypoint: pass
+  podMetadata:
+    labels:
+      team: finance
+      manageds.jbrette.io/completed: "true"
+  templates:
+  - name: pass
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+// TestReservedPodMetadata verifies the pod metadata of a managed cannot set reserved keys
+func TestReservedPodMetadata(t *testing.T) {
+	err := validate(reservedPodMetadata)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.podMetadata.labels.manageds.jbrette.io/completed: label is reserved")
+	}
+	assert.True(t, IsReservedKey("jbrette.io/owner"))
+	assert.False(t, IsReservedKey("notjbrette.io/owner"))
+	assert.False(t, IsReservedKey("team"))
+}
+
+var reservedInitContainerName = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: reserved-init-container-name-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    initContainers:
+    - name: init
+      image: alpine:latest
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+func TestInitContainerName(t *testing.T) {
+	err := validate(reservedInitContainerName)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "initContainers[0].name 'init' is reserved")
+	}
+}
+
+var sidecarReadinessWithoutCommand = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: sidecar-readiness-without-command-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    sidecars:
+    - name: db
+      image: postgres:latest
+      readinessProbe:
+        tcpSocket:
+          port: 5432
+    container:
+      image: alpine:latest
+`
+
+func TestSidecarReadinessCommand(t *testing.T) {
+	err := validate(sidecarReadinessWithoutCommand)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.pass.container.command is required")
+	}
+}
+
+var sidecarNonPositiveReadinessTimeout = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: sidecar-non-positive-readiness-timeout-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    sidecars:
+    - name: db
+      image: postgres:latest
+      readinessTimeoutSeconds: 0
+      readinessProbe:
+        tcpSocket:
+          port: 5432
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["exit 0"]
+`
+
+func TestSidecarReadinessTimeout(t *testing.T) {
+	err := validate(sidecarNonPositiveReadinessTimeout)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.pass.sidecars.db.readinessTimeoutSeconds must be a positive integer > 0")
+	}
+}
+
+var containerSetWithoutMain = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: container-set-without-main-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    containerSet:
+      containers:
+      - name: a
+        image: alpine:latest
+`
+
+var containerSetCycle = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: container-set-cycle-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    containerSet:
+      containers:
+      - name: a
+        image: alpine:latest
+        command: [sh, -c, "exit 0"]
+        dependencies: [main]
+      - name: main
+        image: alpine:latest
+        command: [sh, -c, "exit 0"]
+        dependencies: [a]
+`
+
+var containerSetUndefinedDependency = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: container-set-undefined-dependency-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    containerSet:
+      containers:
+      - name: main
+        image: alpine:latest
+        command: [sh, -c, "exit 0"]
+        dependencies: [b]
+`
+
+var containerSetWithOutputs = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: container-set-with-outputs-
+spec:
+  entrypoint: pass
+  templates:
+  - name: pass
+    containerSet:
+      containers:
+      - name: server
+        image: nginx:latest
+      - name: main
+        image: alpine:latest
+        command: [sh, -c, "wget -O /tmp/index.html localhost"]
+    outputs:
+      artifacts:
+      - name: index
+        path: /tmp/index.html
+`
+
+func TestContainerSet(t *testing.T) {
+	err := validate(containerSetWithoutMain)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must have a container named 'main'")
+	}
+	err = validate(containerSetCycle)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.pass.containerSet.containers dependency cycle detected")
+	}
+	err = validate(containerSetUndefinedDependency)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "dependency 'b' not defined")
+	}
+	err = validate(containerSetWithOutputs)
+	assert.Nil(t, err)
+}
+
+var httpResultInSteps = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: http-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    steps:
+    - - name: get
+        template: get
+    - - name: print
+        template: print
+        arguments:
+          parameters:
+          - name: message
+            value: "{{steps.get.outputs.result}}"
+  - name: get
+    http:
+      url: https://example.com/status
+      successCondition: body.status == ok
+      headers:
+      - name: Authorization
+        valueFrom:
+          secretKeyRef:
+            name: token
+            key: header
+  - name: print
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: alpine:latest
+      command: [echo, "{{inputs.parameters.message}}"]
+`
+
+var httpInvalidURL = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: http-
+spec:
+  entrypoint: get
+  templates:
+  - name: get
+    http:
+      url: ftp://example.com/status
+`
+
+var httpInvalidCondition = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: http-
+spec:
+  entrypoint: get
+  templates:
+  - name: get
+    http:
+      url: https://example.com/status
+      successCondition: "statusCode in 200"
+`
+
+var httpOutputParameter = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: http-
+spec:
+  entrypoint: get
+  templates:
+  - name: get
+    http:
+      url: https://example.com/status
+    outputs:
+      parameters:
+      - name: status
+        valueFrom:
+          path: /tmp/status
+`
+
+var httpRetryStrategy = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: http-
+spec:
+  entrypoint: get
+  templates:
+  - name: get
+    retryStrategy:
+      limit: 3
+    http:
+      url: https://example.com/status
+`
+
+func TestHTTP(t *testing.T) {
+	err := validate(httpResultInSteps)
+	assert.Nil(t, err)
+	err = validate(httpInvalidURL)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must be an http or https url")
+	}
+	err = validate(httpInvalidCondition)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "successCondition")
+	}
+	err = validate(httpOutputParameter)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "limited to the result")
+	}
+	err = validate(httpRetryStrategy)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.get.retryStrategy is only valid for container templates")
+	}
+}
+
+var resourceJSONPatch = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: scale
+  templates:
+  - name: scale
+    resource:
+      action: patch
+      mergeStrategy: json
+      patch: |
+        - op: replace
+          path: /spec/replicas
+          value: 3
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+var resourceDeleteForeground = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: delete
+  templates:
+  - name: delete
+    resource:
+      action: delete
+      propagationPolicy: Foreground
+      waitForDeletion: true
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+var resourceInvalidAction = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: update
+  templates:
+  - name: update
+    resource:
+      action: update
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+var resourceJSONWithoutPatch = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: scale
+  templates:
+  - name: scale
+    resource:
+      action: patch
+      mergeStrategy: json
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+var resourceMisplacedOption = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: create
+  templates:
+  - name: create
+    resource:
+      action: create
+      waitForDeletion: true
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+var resourceInvalidTimeout = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: create
+  templates:
+  - name: create
+    resource:
+      action: create
+      successCondition: status.readyReplicas == 1
+      timeout: "300"
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+func TestResourceAction(t *testing.T) {
+	err := validate(resourceJSONPatch)
+	assert.Nil(t, err)
+	err = validate(resourceDeleteForeground)
+	assert.Nil(t, err)
+	err = validate(resourceInvalidAction)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "action 'update' is invalid")
+	}
+	err = validate(resourceJSONWithoutPatch)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "patch is required by the json merge strategy")
+	}
+	err = validate(resourceMisplacedOption)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "waitForDeletion is only valid for the delete action")
+	}
+	err = validate(resourceInvalidTimeout)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "timeout '300' must be a positive duration")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jbrette/kubext"
@@ -12,6 +14,7 @@ import (
 	"github.com/jbrette/kubext/pkg/apis/managed"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	wfclientset "github.com/jbrette/kubext/pkg/client/clientset/versioned"
+	"github.com/jbrette/kubext/util/tracing"
 	unstructutil "github.com/jbrette/kubext/util/unstructured"
 	"github.com/jbrette/kubext/managed/common"
 	"github.com/ghodss/yaml"
@@ -23,7 +26,6 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
@@ -40,11 +42,16 @@ type ManagedController struct {
 	ConfigMapNS string
 	// Config is the managed controller's configuration
 	Config ManagedControllerConfig
+	// Defaults holds the settings, typically from command line flags, which are used when the
+	// corresponding field is not set in the config map
+	Defaults ManagedControllerConfig
 
 	// restConfig is used by controller to send a SIGUSR1 to the wait sidecar using remotecommand.NewSPDYExecutor().
 	restConfig    *rest.Config
 	kubeclientset kubernetes.Interface
 	wfclientset   wfclientset.Interface
+	// dynamicClientPool is used to delete the resources created by resource templates
+	dynamicClientPool dynamic.ClientPool
 
 	// datastructures to support the processing of manageds and managed pods
 	wfInformer    cache.SharedIndexInformer
@@ -52,6 +59,17 @@ type ManagedController struct {
 	wfQueue       workqueue.RateLimitingInterface
 	podQueue      workqueue.RateLimitingInterface
 	completedPods chan string
+	// configUpdated is signaled whenever the config is reloaded so that worker pools are resized
+	configUpdated chan struct{}
+	// httpRequests holds the requests of http template nodes in flight, keyed by node ID. The
+	// requests of a managed are removed once it is deleted or completed.
+	httpRequests sync.Map
+
+	// state reported by the health and readiness endpoints, accessed atomically
+	configLoaded   int32
+	cachesSynced   int32
+	workersRunning int32
+	lastProgress   int64
 }
 
 // ManagedControllerConfig contain the configuration settings for the managed controller
@@ -62,6 +80,22 @@ type ManagedControllerConfig struct {
 	// ExecutorResources specifies the resource requirements that will be used for the executor sidecar
 	ExecutorResources *apiv1.ResourceRequirements `json:"executorResources,omitempty"`
 
+	// ExecutorLogFormat is the log format of the executor. One of: text|json (default text)
+	ExecutorLogFormat string `json:"executorLogFormat,omitempty"`
+
+	// ContainerRuntimeExecutor is the way the executor acts on the containers of the pods. One of:
+	// docker|k8sapi|pns (default docker). The docker executor mounts the docker socket of the node,
+	// while the k8sapi executor requires the service account of the pods to be allowed to watch
+	// pods, get their logs and exec into them, and the outputs to be saved on volumes. The pns
+	// executor shares the process namespace of the pods and requires the service account to be
+	// allowed to watch pods and get their logs. Its main containers with a command wait for the
+	// wait container before running it, which requires their images to provide sh.
+	ContainerRuntimeExecutor string `json:"containerRuntimeExecutor,omitempty"`
+
+	// Tracing configures the export of OpenTelemetry traces by the controller and the executors.
+	// Changes to the controller's own tracing require a restart of the controller.
+	Tracing tracing.Config `json:"tracing,omitempty"`
+
 	// ArtifactRepository contains the default location of an artifact repository for container artifacts
 	ArtifactRepository ArtifactRepository `json:"artifactRepository,omitempty"`
 
@@ -78,11 +112,67 @@ type ManagedControllerConfig struct {
 	InstanceID string `json:"instanceID,omitempty"`
 
 	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// PropagatedLabels is a list of label keys which are copied from a managed to all its pods
+	// and persistent volume claims, e.g. to attribute the cost of cluster usage to teams. They
+	// take precedence over the labels specified in the pod metadata of the managed or template.
+	// The labels prefixed with jbrette.io, or one of its subdomains, are reserved and rejected.
+	PropagatedLabels []string `json:"propagatedLabels,omitempty"`
+
+	// ManagedWorkers is the number of workers processing manageds. Changes are applied live.
+	ManagedWorkers int `json:"managedWorkers,omitempty"`
+
+	// PodWorkers is the number of workers processing pod updates. Changes are applied live.
+	PodWorkers int `json:"podWorkers,omitempty"`
+
+	// The Kubernetes client and informer settings below are read once when the controller starts.
+	// Changes require a restart of the controller.
+
+	// QPS is the maximum queries per second from the controller to the Kubernetes API.
+	QPS float32 `json:"qps,omitempty"`
+
+	// Burst is the maximum burst of queries from the controller to the Kubernetes API.
+	Burst int `json:"burst,omitempty"`
+
+	// ManagedResyncPeriod is the resync period of the managed informer.
+	ManagedResyncPeriod *metav1.Duration `json:"managedResyncPeriod,omitempty"`
+
+	// PodResyncPeriod is the resync period of the pod informer.
+	PodResyncPeriod *metav1.Duration `json:"podResyncPeriod,omitempty"`
+
+	// MaxOperationTime is the maximum time a managed operation is allowed to run for before
+	// being requeued. Changes are applied live.
+	MaxOperationTime *metav1.Duration `json:"maxOperationTime,omitempty"`
+
+	// HTTPDeniedNetworks is a list of CIDRs the controller refuses to send the requests of http
+	// templates to, since their responses are stored in the status of the manageds. Defaults to the
+	// internal networks: the loopback and link-local networks, which include the metadata endpoints
+	// of cloud providers, and the private networks of RFC 1918, RFC 6598 and IPv6 unique local
+	// addresses. These usually include the pod and service networks of the cluster, so in-cluster
+	// services can only be reached by overriding the list, and cluster networks outside of these
+	// ranges must be added to it. An empty list allows all networks. Requests are sent directly,
+	// ignoring the HTTP(S)_PROXY environment variables. Changes are applied live.
+	HTTPDeniedNetworks []string `json:"httpDeniedNetworks,omitempty"`
 }
 
 const (
-	managedResyncPeriod = 20 * time.Minute
-	podResyncPeriod      = 30 * time.Minute
+	// DefaultManagedWorkers is the default number of managed workers
+	DefaultManagedWorkers = 8
+	// DefaultPodWorkers is the default number of pod workers
+	DefaultPodWorkers = 8
+	// DefaultQPS is the default maximum queries per second to the Kubernetes API
+	DefaultQPS float32 = 20.0
+	// DefaultBurst is the default maximum burst of queries to the Kubernetes API
+	DefaultBurst = 30
+	// DefaultManagedResyncPeriod is the default resync period of the managed informer
+	DefaultManagedResyncPeriod = 20 * time.Minute
+	// DefaultPodResyncPeriod is the default resync period of the pod informer
+	DefaultPodResyncPeriod = 30 * time.Minute
+	// DefaultMaxOperationTime is the default maximum time a managed operation is allowed to run
+	DefaultMaxOperationTime = 10 * time.Second
+
+	// indexManaged is the name of the pod informer index keyed by the namespace/name of the managed
+	indexManaged = "managed"
 )
 
 // ArtifactRepository represents a artifact repository in which a controller will store its artifacts
@@ -90,6 +180,8 @@ type ArtifactRepository struct {
 	S3 *S3ArtifactRepository `json:"s3,omitempty"`
 	// Future artifact repository support here
 	Artifactory *ArtifactoryArtifactRepository `json:"artifactory,omitempty"`
+	GCS         *GCSArtifactRepository         `json:"gcs,omitempty"`
+	Azure       *AzureArtifactRepository       `json:"azure,omitempty"`
 }
 
 // S3ArtifactRepository defines the controller configuration for an S3 artifact repository
@@ -107,54 +199,104 @@ type ArtifactoryArtifactRepository struct {
 	RepoURL string `json:"repoURL,omitempty"`
 }
 
+// GCSArtifactRepository defines the controller configuration for a GCS artifact repository
+type GCSArtifactRepository struct {
+	wfv1.GCSBucket `json:",inline"`
+
+	// KeyPrefix is prefix used as part of the bucket key in which the controller will store artifacts.
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+}
+
+// AzureArtifactRepository defines the controller configuration for an Azure Blob Storage artifact repository
+type AzureArtifactRepository struct {
+	wfv1.AzureContainer `json:",inline"`
+
+	// BlobPrefix is prefix used as part of the blob name in which the controller will store artifacts.
+	BlobPrefix string `json:"blobPrefix,omitempty"`
+}
+
 // NewManagedController instantiates a new ManagedController
 func NewManagedController(restConfig *rest.Config, kubeclientset kubernetes.Interface, wfclientset wfclientset.Interface, configMap string) *ManagedController {
 	wfc := ManagedController{
-		restConfig:    restConfig,
-		kubeclientset: kubeclientset,
-		wfclientset:   wfclientset,
-		ConfigMap:     configMap,
-		wfQueue:       workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		podQueue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		completedPods: make(chan string, 512),
+		restConfig:        restConfig,
+		kubeclientset:     kubeclientset,
+		wfclientset:       wfclientset,
+		dynamicClientPool: dynamic.NewDynamicClientPool(restConfig),
+		ConfigMap:         configMap,
+		wfQueue:           workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		podQueue:          workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		completedPods:     make(chan string, 512),
+		configUpdated:     make(chan struct{}, 1),
 	}
 	return &wfc
 }
 
 // Run starts an Managed resource controller
-func (wfc *ManagedController) Run(ctx context.Context, wfWorkers, podWorkers int) {
+func (wfc *ManagedController) Run(ctx context.Context) {
 	defer wfc.wfQueue.ShutDown()
 	defer wfc.podQueue.ShutDown()
 
+	err := wfc.StartInformers(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	wfc.RunWorkers(ctx.Done())
+}
+
+// StartInformers starts the config map watch and the managed and pod informers, and waits
+// for their caches to sync. Informers keep feeding the work queues without any workers
+// draining them, which allows a standby replica to keep warm caches and take over quickly
+// once it becomes the leader.
+func (wfc *ManagedController) StartInformers(ctx context.Context) error {
 	log.Infof("Managed Controller (version: %s) starting", kubext.GetVersion())
 	log.Info("Watch Managed controller config map updates")
 	_, err := wfc.watchControllerConfigMap(ctx)
 	if err != nil {
-		log.Errorf("Failed to register watch for controller config map: %v", err)
-		return
+		return errors.InternalWrapError(err, "Failed to register watch for controller config map")
 	}
 
 	wfc.wfInformer = wfc.newManagedInformer()
 	wfc.podInformer = wfc.newPodInformer()
 	go wfc.wfInformer.Run(ctx.Done())
 	go wfc.podInformer.Run(ctx.Done())
-	go wfc.podLabeler(ctx.Done())
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
 	for _, informer := range []cache.SharedIndexInformer{wfc.wfInformer, wfc.podInformer} {
 		if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
-			log.Error("Timed out waiting for caches to sync")
-			return
+			return errors.InternalError("Timed out waiting for caches to sync")
 		}
 	}
+	atomic.StoreInt32(&wfc.cachesSynced, 1)
+	return nil
+}
 
-	for i := 0; i < wfWorkers; i++ {
-		go wait.Until(wfc.runWorker, time.Second, ctx.Done())
-	}
-	for i := 0; i < podWorkers; i++ {
-		go wait.Until(wfc.podWorker, time.Second, ctx.Done())
+// RunWorkers starts the pod labeler and the managed and pod workers, and blocks until stopCh
+// is closed. The worker pools are resized whenever the controller config is updated.
+// StartInformers must have returned successfully before RunWorkers is called.
+func (wfc *ManagedController) RunWorkers(stopCh <-chan struct{}) {
+	wfc.markProgress()
+	atomic.StoreInt32(&wfc.workersRunning, 1)
+	defer atomic.StoreInt32(&wfc.workersRunning, 0)
+	go wfc.podLabeler(stopCh)
+
+	wfPool := newWorkerPool(wfc.processNextItem)
+	podPool := newWorkerPool(wfc.processNextPodItem)
+	for {
+		wfWorkers, podWorkers := wfc.managedWorkers(), wfc.podWorkers()
+		if wfPool.size() != wfWorkers || podPool.size() != podWorkers {
+			log.Infof("Running %d managed workers and %d pod workers", wfWorkers, podWorkers)
+			wfPool.resize(wfWorkers)
+			podPool.resize(podWorkers)
+		}
+		select {
+		case <-stopCh:
+			wfPool.resize(0)
+			podPool.resize(0)
+			return
+		case <-wfc.configUpdated:
+		}
 	}
-	<-ctx.Done()
 }
 
 // podLabeler will label all pods on the controllers completedPod channel as completed
@@ -181,11 +323,6 @@ func (wfc *ManagedController) podLabeler(stopCh <-chan struct{}) {
 	}
 }
 
-func (wfc *ManagedController) runWorker() {
-	for wfc.processNextItem() {
-	}
-}
-
 // processNextItem is the worker logic for handling managed updates
 func (wfc *ManagedController) processNextItem() bool {
 	key, quit := wfc.wfQueue.Get()
@@ -193,6 +330,7 @@ func (wfc *ManagedController) processNextItem() bool {
 		return false
 	}
 	defer wfc.wfQueue.Done(key)
+	defer wfc.markProgress()
 
 	obj, exists, err := wfc.wfInformer.GetIndexer().GetByKey(key.(string))
 	if err != nil {
@@ -202,6 +340,7 @@ func (wfc *ManagedController) processNextItem() bool {
 	if !exists {
 		// This happens after a managed was labeled with completed=true
 		// or was deleted, but the work queue still had an entry for it.
+		wfc.deleteHTTPRequests(key.(string))
 		return true
 	}
 	// The managed informer receives unstructured objects to deal with the possibility of invalid
@@ -224,6 +363,7 @@ func (wfc *ManagedController) processNextItem() bool {
 	if wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
 		// can get here if we already added the completed=true label,
 		// but we are still draining the controller's managed workqueue
+		wfc.deleteHTTPRequests(key.(string))
 		return true
 	}
 	woc := newManagedOperationCtx(&wf, wfc)
@@ -235,11 +375,6 @@ func (wfc *ManagedController) processNextItem() bool {
 	return true
 }
 
-func (wfc *ManagedController) podWorker() {
-	for wfc.processNextPodItem() {
-	}
-}
-
 // processNextPodItem is the worker logic for handling pod updates.
 // For pods updates, this simply means to "wake up" the managed by
 // adding the corresponding managed key into the managed workqueue.
@@ -249,6 +384,7 @@ func (wfc *ManagedController) processNextPodItem() bool {
 		return false
 	}
 	defer wfc.podQueue.Done(key)
+	defer wfc.markProgress()
 
 	obj, exists, err := wfc.podInformer.GetIndexer().GetByKey(key.(string))
 	if err != nil {
@@ -295,7 +431,12 @@ func (wfc *ManagedController) ResyncConfig() error {
 		return errors.InternalWrapError(err)
 	}
 	wfc.ConfigMapNS = cm.Namespace
-	return wfc.updateConfig(cm)
+	err = wfc.updateConfig(cm)
+	if err != nil {
+		return err
+	}
+	wfc.applyRateLimits()
+	return nil
 }
 
 func (wfc *ManagedController) updateConfig(cm *apiv1.ConfigMap) error {
@@ -312,10 +453,98 @@ func (wfc *ManagedController) updateConfig(cm *apiv1.ConfigMap) error {
 	if config.ExecutorImage == "" {
 		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' does not have executorImage", wfc.ConfigMap)
 	}
+	switch config.ExecutorLogFormat {
+	case "", "text", "json":
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' has invalid executorLogFormat '%s'. Must be one of: text|json", wfc.ConfigMap, config.ExecutorLogFormat)
+	}
+	switch config.ContainerRuntimeExecutor {
+	case "", common.ContainerRuntimeExecutorDocker, common.ContainerRuntimeExecutorK8sAPI, common.ContainerRuntimeExecutorPNS:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' has invalid containerRuntimeExecutor '%s'. Must be one of: docker|k8sapi|pns", wfc.ConfigMap, config.ContainerRuntimeExecutor)
+	}
+	err = config.Tracing.Validate()
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' has invalid tracing: %v", wfc.ConfigMap, err)
+	}
+	for _, key := range config.PropagatedLabels {
+		if common.IsReservedKey(key) {
+			return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' has invalid propagatedLabels: label '%s' is reserved", wfc.ConfigMap, key)
+		}
+	}
+	_, err = parseNetworks(config.HTTPDeniedNetworks)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' has invalid httpDeniedNetworks: %v", wfc.ConfigMap, err)
+	}
 	wfc.Config = config
+	atomic.StoreInt32(&wfc.configLoaded, 1)
+	select {
+	case wfc.configUpdated <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
+// applyRateLimits recreates the clientsets of the controller when the config overrides the
+// client-side rate limits of the rest config. It must be called before informers are started.
+func (wfc *ManagedController) applyRateLimits() {
+	if wfc.restConfig == nil || (wfc.Config.QPS == 0 && wfc.Config.Burst == 0) {
+		return
+	}
+	restConfig := rest.CopyConfig(wfc.restConfig)
+	if wfc.Config.QPS != 0 {
+		restConfig.QPS = wfc.Config.QPS
+	}
+	if wfc.Config.Burst != 0 {
+		restConfig.Burst = wfc.Config.Burst
+	}
+	log.Infof("Using client rate limits qps=%v burst=%d", restConfig.QPS, restConfig.Burst)
+	wfc.restConfig = restConfig
+	wfc.kubeclientset = kubernetes.NewForConfigOrDie(restConfig)
+	wfc.wfclientset = wfclientset.NewForConfigOrDie(restConfig)
+	wfc.dynamicClientPool = dynamic.NewDynamicClientPool(restConfig)
+}
+
+func (wfc *ManagedController) managedWorkers() int {
+	return firstPositive(wfc.Config.ManagedWorkers, wfc.Defaults.ManagedWorkers, DefaultManagedWorkers)
+}
+
+func (wfc *ManagedController) podWorkers() int {
+	return firstPositive(wfc.Config.PodWorkers, wfc.Defaults.PodWorkers, DefaultPodWorkers)
+}
+
+func (wfc *ManagedController) managedResyncPeriod() time.Duration {
+	return firstDuration(wfc.Config.ManagedResyncPeriod, wfc.Defaults.ManagedResyncPeriod, DefaultManagedResyncPeriod)
+}
+
+func (wfc *ManagedController) podResyncPeriod() time.Duration {
+	return firstDuration(wfc.Config.PodResyncPeriod, wfc.Defaults.PodResyncPeriod, DefaultPodResyncPeriod)
+}
+
+func (wfc *ManagedController) maxOperationTime() time.Duration {
+	return firstDuration(wfc.Config.MaxOperationTime, wfc.Defaults.MaxOperationTime, DefaultMaxOperationTime)
+}
+
+// firstPositive returns the first of the values which is greater than zero
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// firstDuration returns the first of the durations which is set and greater than zero, or the fallback
+func firstDuration(config, defaults *metav1.Duration, fallback time.Duration) time.Duration {
+	for _, d := range []*metav1.Duration{config, defaults} {
+		if d != nil && d.Duration > 0 {
+			return d.Duration
+		}
+	}
+	return fallback
+}
+
 // instanceIDRequirement returns the label requirement to filter against a controller instance (or not)
 func (wfc *ManagedController) instanceIDRequirement() labels.Requirement {
 	var instanceIDReq *labels.Requirement
@@ -368,7 +597,7 @@ func (wfc *ManagedController) newManagedInformer() cache.SharedIndexInformer {
 		resource,
 		dclient,
 		wfc.Config.Namespace,
-		managedResyncPeriod,
+		wfc.managedResyncPeriod(),
 		cache.Indexers{},
 		wfc.tweakManagedlist,
 	)
@@ -461,7 +690,6 @@ func (wfc *ManagedController) newManagedPodWatch() *cache.ListWatch {
 	c := wfc.kubeclientset.CoreV1().RESTClient()
 	resource := "pods"
 	namespace := wfc.Config.Namespace
-	fieldSelector := fields.ParseSelectorOrDie("status.phase!=Pending")
 	// completed=false
 	incompleteReq, _ := labels.NewRequirement(common.LabelKeyCompleted, selection.Equals, []string{"false"})
 	labelSelector := labels.NewSelector().
@@ -469,7 +697,6 @@ func (wfc *ManagedController) newManagedPodWatch() *cache.ListWatch {
 		Add(wfc.instanceIDRequirement())
 
 	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
-		options.FieldSelector = fieldSelector.String()
 		options.LabelSelector = labelSelector.String()
 		req := c.Get().
 			Namespace(namespace).
@@ -479,7 +706,6 @@ func (wfc *ManagedController) newManagedPodWatch() *cache.ListWatch {
 	}
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
 		options.Watch = true
-		options.FieldSelector = fieldSelector.String()
 		options.LabelSelector = labelSelector.String()
 		req := c.Get().
 			Namespace(namespace).
@@ -490,9 +716,24 @@ func (wfc *ManagedController) newManagedPodWatch() *cache.ListWatch {
 	return &cache.ListWatch{ListFunc: listFunc, WatchFunc: watchFunc}
 }
 
+// managedIndexFunc indexes pods by the namespace/name of the managed they belong to
+func managedIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		return nil, nil
+	}
+	managedName, ok := pod.Labels[common.LabelKeyManaged]
+	if !ok {
+		return nil, nil
+	}
+	return []string{pod.ObjectMeta.Namespace + "/" + managedName}, nil
+}
+
 func (wfc *ManagedController) newPodInformer() cache.SharedIndexInformer {
 	source := wfc.newManagedPodWatch()
-	informer := cache.NewSharedIndexInformer(source, &apiv1.Pod{}, podResyncPeriod, cache.Indexers{})
+	informer := cache.NewSharedIndexInformer(source, &apiv1.Pod{}, wfc.podResyncPeriod(), cache.Indexers{
+		indexManaged: managedIndexFunc,
+	})
 	informer.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
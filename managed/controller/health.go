@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// workerStallTimeout is how long the work queues may hold items without any worker finishing
+// an item before the controller is reported as unhealthy
+const workerStallTimeout = 5 * time.Minute
+
+// markProgress records that a worker finished processing an item
+func (wfc *ManagedController) markProgress() {
+	atomic.StoreInt64(&wfc.lastProgress, time.Now().UnixNano())
+}
+
+// checkHealth returns an error if the workers are running but no longer making progress.
+// A standby replica which does not run workers is always considered healthy.
+func (wfc *ManagedController) checkHealth() error {
+	if atomic.LoadInt32(&wfc.workersRunning) == 0 {
+		return nil
+	}
+	queued := wfc.wfQueue.Len() + wfc.podQueue.Len()
+	if queued == 0 {
+		return nil
+	}
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&wfc.lastProgress)))
+	if since > workerStallTimeout {
+		return fmt.Errorf("no item processed in %v while %d items are queued", since.Round(time.Second), queued)
+	}
+	return nil
+}
+
+// checkReadiness returns an error if the controller configuration has not been loaded or the
+// informer caches have not synced yet
+func (wfc *ManagedController) checkReadiness() error {
+	if atomic.LoadInt32(&wfc.configLoaded) == 0 {
+		return fmt.Errorf("controller configuration from %s not loaded", wfc.ConfigMap)
+	}
+	if atomic.LoadInt32(&wfc.cachesSynced) == 0 {
+		return fmt.Errorf("informer caches not synced")
+	}
+	return nil
+}
+
+// Healthz is the liveness endpoint handler of the controller
+func (wfc *ManagedController) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeProbeResult(w, wfc.checkHealth())
+}
+
+// Readyz is the readiness endpoint handler of the controller
+func (wfc *ManagedController) Readyz(w http.ResponseWriter, r *http.Request) {
+	writeProbeResult(w, wfc.checkReadiness())
+}
+
+func writeProbeResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
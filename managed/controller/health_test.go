@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// TestHealthz verifies liveness is only reported as failing when workers stall on a non-empty queue
+func TestHealthz(t *testing.T) {
+	controller := newController()
+	controller.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	controller.podQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+	// standby replica with queued items is healthy
+	controller.wfQueue.Add("default/hello-world")
+	rr := httptest.NewRecorder()
+	controller.Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	controller.workersRunning = 1
+	controller.lastProgress = time.Now().UnixNano()
+	rr = httptest.NewRecorder()
+	controller.Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	controller.lastProgress = time.Now().Add(-2 * workerStallTimeout).UnixNano()
+	rr = httptest.NewRecorder()
+	controller.Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+// TestReadyz verifies readiness requires both the config and the informer caches
+func TestReadyz(t *testing.T) {
+	controller := newController()
+	rr := httptest.NewRecorder()
+	controller.Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	controller.configLoaded = 1
+	rr = httptest.NewRecorder()
+	controller.Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "caches")
+
+	controller.cachesSynced = 1
+	rr = httptest.NewRecorder()
+	controller.Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// defaultHTTPTimeout is the timeout of a request of an http template which does not specify one
+	defaultHTTPTimeout = 30 * time.Second
+	// maxHTTPResultSize is the maximum size of a response body captured as the result of an http
+	// template, which is stored in the managed status
+	maxHTTPResultSize = 256 * 1024
+)
+
+// defaultHTTPDeniedNetworks are the networks to which the requests of http templates are refused
+// unless the controller config overrides them: the internal networks, i.e. the unspecified,
+// loopback and link-local networks, which include the metadata endpoints of cloud providers, and
+// the private networks (RFC 1918, RFC 6598 shared address space and IPv6 unique local addresses),
+// which usually include the pod and service networks of the cluster
+var defaultHTTPDeniedNetworks = []string{
+	"0.0.0.0/8", "127.0.0.0/8", "169.254.0.0/16", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10",
+	"::/128", "::1/128", "fe80::/10", "fc00::/7",
+}
+
+// httpRequest is a request of an http template node in flight. The result is set before done is closed.
+type httpRequest struct {
+	// key is the key of the managed which sent the request
+	key    string
+	cancel context.CancelFunc
+	done   chan struct{}
+	result httpResult
+}
+
+// httpResult is the outcome of the request of an http template node
+type httpResult struct {
+	phase   wfv1.NodePhase
+	message string
+	body    *string
+}
+
+// executeHTTP sends the request of an http template from the controller, without a pod. The
+// request is sent in the background and the managed is requeued once the response is received,
+// at which point the node is completed with the response body as its result. A request which
+// was in flight when the controller restarted is sent again.
+func (woc *wfOperationCtx) executeHTTP(nodeName string, tmpl *wfv1.Template, boundaryID string) *wfv1.NodeStatus {
+	node := woc.getNodeByName(nodeName)
+	if node == nil {
+		node = woc.initializeNode(nodeName, wfv1.NodeTypeHTTP, tmpl.Name, boundaryID, wfv1.NodeRunning)
+	}
+	value, ok := woc.controller.httpRequests.Load(node.ID)
+	if !ok {
+		req, successSelector, err := woc.newHTTPRequest(tmpl.HTTP)
+		if err != nil {
+			return woc.markNodeError(nodeName, err)
+		}
+		deniedNetworks, err := parseNetworks(woc.controller.Config.HTTPDeniedNetworks)
+		if err != nil {
+			return woc.markNodeError(nodeName, err)
+		}
+		woc.sendHTTPRequest(node.ID, req, successSelector, deniedNetworks, tmpl.HTTP)
+		return node
+	}
+	request := value.(*httpRequest)
+	select {
+	case <-request.done:
+	default:
+		return node
+	}
+	woc.controller.httpRequests.Delete(node.ID)
+	if request.result.body != nil {
+		node.Outputs = &wfv1.Outputs{Result: request.result.body}
+		woc.wf.Status.Nodes[node.ID] = *node
+		woc.updated = true
+	}
+	return woc.markNodePhase(nodeName, request.result.phase, request.result.message)
+}
+
+// newHTTPRequest builds the request of an http template, resolving the headers from secrets,
+// and parses its success condition
+func (woc *wfOperationCtx) newHTTPRequest(tmpl *wfv1.HTTPTemplate) (*http.Request, labels.Selector, error) {
+	method := tmpl.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(tmpl.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, nil, errors.Errorf(errors.CodeBadRequest, "invalid http url '%s'", tmpl.URL)
+	}
+	req, err := http.NewRequest(method, tmpl.URL, bytes.NewBufferString(tmpl.Body))
+	if err != nil {
+		return nil, nil, errors.Errorf(errors.CodeBadRequest, "failed to create http request: %v", err)
+	}
+	for _, header := range tmpl.Headers {
+		value := header.Value
+		if header.ValueFrom != nil && header.ValueFrom.SecretKeyRef != nil {
+			value, err = woc.getSecretValue(header.ValueFrom.SecretKeyRef)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		req.Header.Add(header.Name, value)
+	}
+	var successSelector labels.Selector
+	if tmpl.SuccessCondition != "" {
+		successSelector, err = labels.Parse(tmpl.SuccessCondition)
+		if err != nil {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "success condition '%s' failed to parse: %v", tmpl.SuccessCondition, err)
+		}
+	}
+	return req, successSelector, nil
+}
+
+// getSecretValue returns the value of a key of a secret in the namespace of the managed
+func (woc *wfOperationCtx) getSecretValue(selector *apiv1.SecretKeySelector) (string, error) {
+	secret, err := woc.controller.kubeclientset.CoreV1().Secrets(woc.wf.ObjectMeta.Namespace).Get(selector.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return "", errors.Errorf(errors.CodeNotFound, "secret '%s' not found", selector.Name)
+		}
+		return "", errors.InternalWrapError(err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", errors.Errorf(errors.CodeNotFound, "key '%s' not found in secret '%s'", selector.Key, selector.Name)
+	}
+	return string(value), nil
+}
+
+// sendHTTPRequest sends the request in the background and requeues the managed once the
+// response is received
+func (woc *wfOperationCtx) sendHTTPRequest(nodeID string, req *http.Request, successSelector labels.Selector, deniedNetworks []*net.IPNet, tmpl *wfv1.HTTPTemplate) {
+	key, err := cache.MetaNamespaceKeyFunc(woc.wf)
+	if err != nil {
+		woc.log.Errorf("Failed to get key of managed %s: %v", woc.wf.ObjectMeta.Name, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	req = req.WithContext(ctx)
+	request := &httpRequest{key: key, cancel: cancel, done: make(chan struct{})}
+	woc.controller.httpRequests.Store(nodeID, request)
+	wfc := woc.controller
+	woc.log.Infof("Sending http request %s %s for node %s", req.Method, req.URL, nodeID)
+	go func() {
+		request.result = doHTTPRequest(req, successSelector, deniedNetworks, tmpl)
+		cancel()
+		close(request.done)
+		wfc.wfQueue.Add(key)
+	}()
+}
+
+// deleteHTTPRequests cancels and forgets the requests of a managed which was deleted or completed
+// before their responses were processed
+func (wfc *ManagedController) deleteHTTPRequests(key string) {
+	wfc.httpRequests.Range(func(nodeID, value interface{}) bool {
+		request := value.(*httpRequest)
+		if request.key == key {
+			request.cancel()
+			wfc.httpRequests.Delete(nodeID)
+		}
+		return true
+	})
+}
+
+// parseNetworks parses the CIDRs of the networks denied to http templates, which default to
+// defaultHTTPDeniedNetworks when nil
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	if cidrs == nil {
+		cidrs = defaultHTTPDeniedNetworks
+	}
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "invalid network '%s': %v", cidr, err)
+		}
+		networks[i] = network
+	}
+	return networks, nil
+}
+
+// newHTTPTransport returns a transport which refuses to connect to the addresses of the denied
+// networks. Host names are resolved before connecting so that the address which is checked is the
+// one connected to. Requests are never sent through the proxy of the environment, whose address
+// would be checked instead of the one of the target.
+func newHTTPTransport(insecureSkipVerify bool, deniedNetworks []*net.IPNet) *http.Transport {
+	dialer := &net.Dialer{Timeout: defaultHTTPTimeout}
+	return &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, ipAddr := range addrs {
+				for _, denied := range deniedNetworks {
+					if denied.Contains(ipAddr.IP) {
+						return nil, errors.Errorf(errors.CodeForbidden, "%s resolves to %s in denied network %s", host, ipAddr.IP, denied)
+					}
+				}
+			}
+			var conn net.Conn
+			for _, ipAddr := range addrs {
+				conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ipAddr.IP.String(), port))
+				if err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}
+}
+
+// doHTTPRequest sends the request and evaluates the response. Failures are classified with the
+// error codes of the errors package, which prefix the message of the result.
+func doHTTPRequest(req *http.Request, successSelector labels.Selector, deniedNetworks []*net.IPNet, tmpl *wfv1.HTTPTemplate) httpResult {
+	client := &http.Client{
+		Transport: newHTTPTransport(tmpl.InsecureSkipVerify, deniedNetworks),
+		Timeout:   defaultHTTPTimeout,
+	}
+	if tmpl.TimeoutSeconds != nil {
+		client.Timeout = time.Duration(*tmpl.TimeoutSeconds) * time.Second
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return httpFailure(nil, httpClientErrorCode(err), "%s %s failed: %v", req.Method, req.URL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPResultSize+1))
+	if err != nil {
+		return httpFailure(nil, httpClientErrorCode(err), "%s %s failed to read response: %v", req.Method, req.URL, err)
+	}
+	if len(data) > maxHTTPResultSize {
+		return httpFailure(nil, errors.CodeBadRequest, "%s %s response exceeds %d bytes", req.Method, req.URL, maxHTTPResultSize)
+	}
+	body := string(data)
+	if successSelector == nil {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return httpFailure(&body, httpStatusErrorCode(resp.StatusCode), "%s %s returned %s", req.Method, req.URL, resp.Status)
+		}
+	} else if !successSelector.Matches(httpResponseLabels(resp.StatusCode, data)) {
+		code := errors.CodeBadRequest
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			code = httpStatusErrorCode(resp.StatusCode)
+		}
+		return httpFailure(&body, code, "%s %s returned %s: success condition '%s' not met", req.Method, req.URL, resp.Status, tmpl.SuccessCondition)
+	}
+	return httpResult{phase: wfv1.NodeSucceeded, body: &body}
+}
+
+// httpResponseLabels returns the labels against which the success condition is evaluated: a
+// JSON document with the status code and the body, parsed when it is JSON
+func httpResponseLabels(statusCode int, data []byte) labels.Labels {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		body = string(data)
+	}
+	doc, _ := json.Marshal(map[string]interface{}{
+		"statusCode": statusCode,
+		"body":       body,
+	})
+	return common.GJSONLabels{JSON: doc}
+}
+
+func httpFailure(body *string, code string, format string, args ...interface{}) httpResult {
+	return httpResult{
+		phase:   wfv1.NodeFailed,
+		message: fmt.Sprintf("%s: %s", code, fmt.Sprintf(format, args...)),
+		body:    body,
+	}
+}
+
+// httpStatusErrorCode classifies an unsuccessful status code
+func httpStatusErrorCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return errors.CodeUnauthorized
+	case http.StatusForbidden:
+		return errors.CodeForbidden
+	case http.StatusNotFound:
+		return errors.CodeNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return errors.CodeTimeout
+	}
+	if statusCode >= 500 {
+		return errors.CodeInternal
+	}
+	return errors.CodeBadRequest
+}
+
+// httpClientErrorCode classifies an error sending a request or reading its response
+func httpClientErrorCode(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if kubextErr, ok := err.(errors.KubextError); ok {
+		return kubextErr.Code()
+	}
+	if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		return errors.CodeTimeout
+	}
+	return errors.CodeInternal
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var httpWf = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  name: http-template
+spec:
+  entrypoint: get
+  templates:
+  - name: get
+    http:
+      url: %s
+      successCondition: body.status == ok
+      headers:
+      - name: Authorization
+        valueFrom:
+          secretKeyRef:
+            name: token
+            key: header
+`
+
+// operateHTTP operates on the managed until its http request completes
+func operateHTTP(t *testing.T, controller *ManagedController, wf *wfv1.Managed) *wfv1.NodeStatus {
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName(wf.ObjectMeta.Name)
+	if assert.NotNil(t, node) && !node.Completed() {
+		assert.Equal(t, wfv1.NodeTypeHTTP, node.Type)
+		key, _ := controller.wfQueue.Get()
+		assert.Equal(t, wf.ObjectMeta.Name, key)
+		controller.wfQueue.Done(key)
+		woc = newManagedOperationCtx(woc.wf, controller)
+		woc.operate()
+		node = woc.getNodeByName(wf.ObjectMeta.Name)
+	}
+	return node
+}
+
+// TestHTTPTemplate verifies the controller sends the request of an http template and captures the response
+func TestHTTPTemplate(t *testing.T) {
+	status := "ok"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"status": "%s"}`, status)
+	}))
+	defer server.Close()
+	controller := newController()
+	// the test server listens on the loopback network, which is denied by default
+	controller.Config.HTTPDeniedNetworks = []string{}
+	controller.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer controller.wfQueue.ShutDown()
+
+	// the secret holding the header is missing
+	wf := unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/"))
+	node := operateHTTP(t, controller, wf)
+	assert.Equal(t, wfv1.NodeError, node.Phase)
+	assert.Equal(t, "secret 'token' not found", node.Message)
+
+	_, err := controller.kubeclientset.CoreV1().Secrets("").Create(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token"},
+		Data:       map[string][]byte{"header": []byte("Bearer secret")},
+	})
+	assert.Nil(t, err)
+	node = operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/")))
+	assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+	if assert.NotNil(t, node.Outputs) && assert.NotNil(t, node.Outputs.Result) {
+		assert.Equal(t, `{"status": "ok"}`, *node.Outputs.Result)
+	}
+
+	status = "degraded"
+	node = operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/")))
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Contains(t, node.Message, "ERR_BAD_REQUEST: GET "+server.URL)
+	assert.Contains(t, node.Message, "success condition 'body.status == ok' not met")
+
+	node = operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/missing")))
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Contains(t, node.Message, "ERR_NOT_FOUND")
+}
+
+// TestHTTPDeniedNetworks verifies the requests to the denied networks are refused, which include the
+// loopback and private networks by default, and that requests are not sent through a proxy
+func TestHTTPDeniedNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer server.Close()
+	controller := newController()
+	controller.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer controller.wfQueue.ShutDown()
+	_, err := controller.kubeclientset.CoreV1().Secrets("").Create(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token"},
+		Data:       map[string][]byte{"header": []byte("Bearer secret")},
+	})
+	assert.Nil(t, err)
+
+	node := operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/")))
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Contains(t, node.Message, "ERR_FORBIDDEN")
+	assert.Contains(t, node.Message, "in denied network 127.0.0.0/8")
+	assert.Nil(t, node.Outputs)
+
+	node = operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, "http://10.1.2.3/")))
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Contains(t, node.Message, "in denied network 10.0.0.0/8")
+
+	controller.Config.HTTPDeniedNetworks = []string{"10.0.0.0/8"}
+	node = operateHTTP(t, controller, unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/")))
+	assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+
+	assert.Nil(t, newHTTPTransport(false, nil).Proxy)
+}
+
+// TestDeleteHTTPRequests verifies the requests in flight of a deleted managed are canceled and forgotten
+func TestDeleteHTTPRequests(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+	controller := newController()
+	controller.Config.HTTPDeniedNetworks = []string{}
+	controller.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer controller.wfQueue.ShutDown()
+	_, err := controller.kubeclientset.CoreV1().Secrets("").Create(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token"},
+		Data:       map[string][]byte{"header": []byte("Bearer secret")},
+	})
+	assert.Nil(t, err)
+
+	wf := unmarshalWF(fmt.Sprintf(httpWf, server.URL+"/"))
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName(wf.ObjectMeta.Name)
+	assert.NotNil(t, node)
+	value, ok := controller.httpRequests.Load(node.ID)
+	if !assert.True(t, ok) {
+		return
+	}
+	request := value.(*httpRequest)
+
+	controller.deleteHTTPRequests(wf.ObjectMeta.Name)
+	_, ok = controller.httpRequests.Load(node.ID)
+	assert.False(t, ok)
+	<-request.done
+	assert.Equal(t, wfv1.NodeFailed, request.result.phase)
+	assert.Contains(t, request.result.message, "context canceled")
+}
+
+// TestHTTPStatusErrorCode verifies the classification of unsuccessful status codes
+func TestHTTPStatusErrorCode(t *testing.T) {
+	assert.Equal(t, "ERR_UNAUTHORIZED", httpStatusErrorCode(http.StatusUnauthorized))
+	assert.Equal(t, "ERR_FORBIDDEN", httpStatusErrorCode(http.StatusForbidden))
+	assert.Equal(t, "ERR_NOT_FOUND", httpStatusErrorCode(http.StatusNotFound))
+	assert.Equal(t, "ERR_TIMEOUT", httpStatusErrorCode(http.StatusGatewayTimeout))
+	assert.Equal(t, "ERR_BAD_REQUEST", httpStatusErrorCode(http.StatusConflict))
+	assert.Equal(t, "ERR_INTERNAL", httpStatusErrorCode(http.StatusServiceUnavailable))
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"strings"
 
 	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/jbrette/kubext/util/tracing"
 	"github.com/jbrette/kubext/managed/common"
 	log "github.com/sirupsen/logrus"
 	"github.com/valyala/fasttemplate"
@@ -105,12 +107,19 @@ func envFromField(envVarName, fieldPath string) apiv1.EnvVar {
 
 func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Container, tmpl *wfv1.Template) (*apiv1.Pod, error) {
 	nodeID := woc.wf.NodeID(nodeName)
-	woc.log.Debugf("Creating Pod: %s (%s)", nodeName, nodeID)
+	logger := woc.nodeLogger(nodeID, tmpl.Name, true)
+	logger.Debugf("Creating Pod: %s (%s)", nodeName, nodeID)
 	tmpl = tmpl.DeepCopy()
 	wfSpec, err := substituteGlobals(&woc.wf.Spec, woc.globalParams)
 	if err != nil {
 		return nil, err
 	}
+	if woc.controller.Config.ContainerRuntimeExecutor == common.ContainerRuntimeExecutorK8sAPI {
+		err = common.ValidateOutputsOnVolumes(tmpl, woc.controller.Config.ContainerRuntimeExecutor)
+		if err != nil {
+			return nil, err
+		}
+	}
 	mainCtr.Name = common.MainContainerName
 	pod := apiv1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -133,8 +142,6 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 			},
 			Volumes: []apiv1.Volume{
 				volumePodMetadata,
-				volumeDockerLib,
-				volumeDockerSock,
 			},
 			ActiveDeadlineSeconds: tmpl.ActiveDeadlineSeconds,
 			// TODO: consider allowing service account and image pull secrets to reference global vars
@@ -145,6 +152,14 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 	if woc.controller.Config.InstanceID != "" {
 		pod.ObjectMeta.Labels[common.LabelKeyControllerInstanceID] = woc.controller.Config.InstanceID
 	}
+	if woc.usesDockerExecutor() {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, volumeDockerLib, volumeDockerSock)
+	}
+	if woc.controller.Config.ContainerRuntimeExecutor == common.ContainerRuntimeExecutorPNS {
+		// the wait container finds the processes of the main containers through /proc
+		shareProcessNamespace := true
+		pod.Spec.ShareProcessNamespace = &shareProcessNamespace
+	}
 
 	if tmpl.GetType() != wfv1.TemplateTypeResource {
 		// we do not need the wait container for resource templates because
@@ -157,6 +172,10 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 		pod.Spec.Containers = append(pod.Spec.Containers, *waitCtr)
 	}
 
+	if tmpl.ContainerSet != nil {
+		addContainerSet(&pod, tmpl)
+	}
+
 	// Add init container only if it needs input artifacts. This is also true for
 	// script templates (which needs to populate the script)
 	if len(tmpl.Inputs.Artifacts) > 0 || tmpl.GetType() == wfv1.TemplateTypeScript {
@@ -165,7 +184,10 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 	}
 
 	addSchedulingConstraints(&pod, wfSpec, tmpl)
-	addMetadata(&pod, tmpl)
+	addMetadata(&pod, wfSpec, tmpl)
+	for k, v := range woc.propagatedLabels() {
+		pod.ObjectMeta.Labels[k] = v
+	}
 
 	err = addVolumeReferences(&pod, wfSpec, tmpl, woc.wf.Status.PersistentVolumeClaims)
 	if err != nil {
@@ -186,12 +208,43 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 		addExecutorStagingVolume(&pod)
 	}
 
-	// addSidecars should be called after all volumes have been manipulated
-	// in the main container (in case sidecar requires volume mount mirroring)
+	// addInitContainers and addSidecars should be called after all volumes have been manipulated
+	// in the main container (in case they require volume mount mirroring)
+	if woc.controller.Config.ContainerRuntimeExecutor == common.ContainerRuntimeExecutorK8sAPI {
+		// the wait container reads the outputs from the volumes of the main container
+		addWaitVolumeMounts(&pod)
+	}
+	err = addInitContainers(&pod, tmpl)
+	if err != nil {
+		return nil, err
+	}
 	err = addSidecars(&pod, tmpl)
 	if err != nil {
 		return nil, err
 	}
+	// the wait container of the pns executor must hold the root filesystem of the main containers
+	// before they run, so that their outputs can be read after they exited
+	waitRootFS := woc.controller.Config.ContainerRuntimeExecutor == common.ContainerRuntimeExecutorPNS
+	err = addContainerGates(&pod, tmpl, waitRootFS)
+	if err != nil {
+		return nil, err
+	}
+
+	// pod spec patches are applied last, so they can override anything set above
+	for _, podSpecPatch := range []string{wfSpec.PodSpecPatch, tmpl.PodSpecPatch} {
+		if podSpecPatch == "" {
+			continue
+		}
+		err = verifyResolvedVariables(podSpecPatch)
+		if err != nil {
+			return nil, err
+		}
+		podSpec, err := common.ApplyPodSpecPatch(pod.Spec, podSpecPatch)
+		if err != nil {
+			return nil, err
+		}
+		pod.Spec = *podSpec
+	}
 
 	// Set the container template JSON in pod annotations, which executor
 	// will examine for things like artifact location/path. Also ensures
@@ -207,18 +260,26 @@ func (woc *wfOperationCtx) createManagedPod(nodeName string, mainCtr apiv1.Conta
 	}
 	pod.ObjectMeta.Annotations[common.AnnotationKeyTemplate] = string(tmplBytes)
 
+	if traceContext := tracing.Inject(woc.ctx); traceContext != nil {
+		traceBytes, err := json.Marshal(traceContext)
+		if err != nil {
+			return nil, err
+		}
+		pod.ObjectMeta.Annotations[common.AnnotationKeyTraceContext] = string(traceBytes)
+	}
+
 	created, err := woc.controller.kubeclientset.CoreV1().Pods(woc.wf.ObjectMeta.Namespace).Create(&pod)
 	if err != nil {
 		if apierr.IsAlreadyExists(err) {
 			// managed pod names are deterministic. We can get here if the
 			// controller fails to persist the managed after creating the pod.
-			woc.log.Infof("Skipped pod %s (%s) creation: already exists", nodeName, nodeID)
+			logger.Infof("Skipped pod %s (%s) creation: already exists", nodeName, nodeID)
 			return created, nil
 		}
-		woc.log.Infof("Failed to create pod %s (%s): %v", nodeName, nodeID, err)
+		logger.Infof("Failed to create pod %s (%s): %v", nodeName, nodeID, err)
 		return nil, errors.InternalWrapError(err)
 	}
-	woc.log.Infof("Created pod: %s (%s)", nodeName, created.Name)
+	logger.Infof("Created pod: %s (%s)", nodeName, created.Name)
 	woc.activePods++
 	return created, nil
 }
@@ -245,7 +306,7 @@ func substituteGlobals(wfSpec *wfv1.ManagedSpec, globalParams map[string]string)
 func (woc *wfOperationCtx) newInitContainer(tmpl *wfv1.Template) apiv1.Container {
 	ctr := woc.newExecContainer(common.InitContainerName, false)
 	ctr.Command = []string{"kubextexec"}
-	ctr.Args = []string{"init"}
+	ctr.Args = woc.executorArgs("init")
 	ctr.VolumeMounts = []apiv1.VolumeMount{
 		volumeMountPodMetadata,
 	}
@@ -255,15 +316,32 @@ func (woc *wfOperationCtx) newInitContainer(tmpl *wfv1.Template) apiv1.Container
 func (woc *wfOperationCtx) newWaitContainer(tmpl *wfv1.Template) (*apiv1.Container, error) {
 	ctr := woc.newExecContainer(common.WaitContainerName, false)
 	ctr.Command = []string{"kubextexec"}
-	ctr.Args = []string{"wait"}
+	ctr.Args = woc.executorArgs("wait")
 	ctr.VolumeMounts = []apiv1.VolumeMount{
 		volumeMountPodMetadata,
-		volumeMountDockerLib,
-		volumeMountDockerSock,
+	}
+	if woc.usesDockerExecutor() {
+		ctr.VolumeMounts = append(ctr.VolumeMounts, volumeMountDockerLib, volumeMountDockerSock)
+	}
+	if woc.controller.Config.ContainerRuntimeExecutor == common.ContainerRuntimeExecutorPNS {
+		// required to access the root filesystem of the main containers running as another user
+		ctr.SecurityContext.Capabilities = &apiv1.Capabilities{
+			Add: []apiv1.Capability{"SYS_PTRACE"},
+		}
 	}
 	return ctr, nil
 }
 
+// usesDockerExecutor returns whether the executor uses the docker daemon of the node, which
+// requires host path volumes
+func (woc *wfOperationCtx) usesDockerExecutor() bool {
+	switch woc.controller.Config.ContainerRuntimeExecutor {
+	case "", common.ContainerRuntimeExecutorDocker:
+		return true
+	}
+	return false
+}
+
 func (woc *wfOperationCtx) newExecContainer(name string, privileged bool) *apiv1.Container {
 	exec := apiv1.Container{
 		Name:  name,
@@ -273,14 +351,44 @@ func (woc *wfOperationCtx) newExecContainer(name string, privileged bool) *apiv1
 			Privileged: &privileged,
 		},
 	}
+	if woc.controller.Config.ContainerRuntimeExecutor != "" {
+		exec.Env = append(append([]apiv1.EnvVar{}, execEnvVars...), apiv1.EnvVar{
+			Name:  common.EnvVarContainerRuntimeExecutor,
+			Value: woc.controller.Config.ContainerRuntimeExecutor,
+		})
+	}
 	if woc.controller.Config.ExecutorResources != nil {
 		exec.Resources = *woc.controller.Config.ExecutorResources
 	}
 	return &exec
 }
 
-// addMetadata applies metadata specified in the template
-func addMetadata(pod *apiv1.Pod, tmpl *wfv1.Template) {
+// executorArgs returns the arguments of an executor command, including the executor log format
+// and tracing configuration
+func (woc *wfOperationCtx) executorArgs(args ...string) []string {
+	if woc.controller.Config.ExecutorLogFormat != "" {
+		args = append(args, "--log-format", woc.controller.Config.ExecutorLogFormat)
+	}
+	return append(args, woc.controller.Config.Tracing.Args()...)
+}
+
+// addMetadata applies the pod metadata specified in the managed, then the metadata specified
+// in the template, which takes precedence. The keys of the managed pod metadata reserved by the
+// controller are skipped.
+func addMetadata(pod *apiv1.Pod, wfSpec *wfv1.ManagedSpec, tmpl *wfv1.Template) {
+	if wfSpec.PodMetadata != nil {
+		for k, v := range wfSpec.PodMetadata.Annotations {
+			if !common.IsReservedKey(k) {
+				pod.ObjectMeta.Annotations[k] = v
+			}
+		}
+		for k, v := range wfSpec.PodMetadata.Labels {
+			if !common.IsReservedKey(k) {
+				pod.ObjectMeta.Labels[k] = v
+			}
+		}
+	}
+
 	for k, v := range tmpl.Metadata.Annotations {
 		pod.ObjectMeta.Annotations[k] = v
 	}
@@ -290,6 +398,21 @@ func addMetadata(pod *apiv1.Pod, tmpl *wfv1.Template) {
 	}
 }
 
+// propagatedLabels returns the labels of the managed which are configured to be copied to
+// its pods and persistent volume claims, except the labels reserved by the controller
+func (woc *wfOperationCtx) propagatedLabels() map[string]string {
+	labels := make(map[string]string)
+	for _, key := range woc.controller.Config.PropagatedLabels {
+		if common.IsReservedKey(key) {
+			continue
+		}
+		if value, ok := woc.wf.ObjectMeta.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
 // addSchedulingConstraints applies any node selectors or affinity rules to the pod, either set in the managed or the template
 func addSchedulingConstraints(pod *apiv1.Pod, wfSpec *wfv1.ManagedSpec, tmpl *wfv1.Template) {
 	// Set nodeSelector (if specified)
@@ -316,7 +439,7 @@ func addSchedulingConstraints(pod *apiv1.Pod, wfSpec *wfv1.ManagedSpec, tmpl *wf
 // These are either specified in the managed.spec.volumes or the managed.spec.volumeClaimTemplate section
 func addVolumeReferences(pod *apiv1.Pod, wfSpec *wfv1.ManagedSpec, tmpl *wfv1.Template, pvcs []apiv1.Volume) error {
 	switch tmpl.GetType() {
-	case wfv1.TemplateTypeContainer, wfv1.TemplateTypeScript:
+	case wfv1.TemplateTypeContainer, wfv1.TemplateTypeScript, wfv1.TemplateTypeContainerSet:
 	default:
 		return nil
 	}
@@ -370,6 +493,18 @@ func addVolumeReferences(pod *apiv1.Pod, wfSpec *wfv1.ManagedSpec, tmpl *wfv1.Te
 			return err
 		}
 	}
+	if tmpl.ContainerSet != nil {
+		err := addVolumeRef(tmpl.ContainerSet.VolumeMounts)
+		if err != nil {
+			return err
+		}
+		for _, ctr := range tmpl.ContainerSet.Containers {
+			err := addVolumeRef(ctr.VolumeMounts)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	for _, sidecar := range tmpl.Sidecars {
 		err := addVolumeRef(sidecar.VolumeMounts)
 		if err != nil {
@@ -417,26 +552,25 @@ func (woc *wfOperationCtx) addInputArtifactsVolumes(pod *apiv1.Pod, tmpl *wfv1.T
 			// We also add the user supplied mount paths to the init container,
 			// in case the executor needs to load artifacts to this volume
 			// instead of the artifacts volume
-			if tmpl.Container != nil {
-				for _, mnt := range tmpl.Container.VolumeMounts {
-					mnt.MountPath = path.Join(common.InitContainerMainFilesystemDir, mnt.MountPath)
-					initCtr.VolumeMounts = append(initCtr.VolumeMounts, mnt)
-				}
+			for _, mnt := range common.GetTemplateVolumeMounts(tmpl) {
+				mnt.MountPath = path.Join(common.InitContainerMainFilesystemDir, mnt.MountPath)
+				initCtr.VolumeMounts = append(initCtr.VolumeMounts, mnt)
 			}
 			pod.Spec.InitContainers[i] = initCtr
 			break
 		}
 	}
 
-	mainCtrIndex := 0
-	var mainCtr *apiv1.Container
-	for i, ctr := range pod.Spec.Containers {
-		if ctr.Name == common.MainContainerName {
-			mainCtrIndex = i
-			mainCtr = &pod.Spec.Containers[i]
+	// the artifacts are mounted in the main container, or in all the containers of a container set
+	var mainCtrs []*apiv1.Container
+	for _, name := range common.GetMainContainerNames(tmpl) {
+		for i, ctr := range pod.Spec.Containers {
+			if ctr.Name == name {
+				mainCtrs = append(mainCtrs, &pod.Spec.Containers[i])
+			}
 		}
 	}
-	if mainCtr == nil {
+	if len(mainCtrs) == 0 {
 		panic("Could not find main container in pod spec")
 	}
 	// TODO: the order in which we construct the volume mounts may matter,
@@ -459,12 +593,10 @@ func (woc *wfOperationCtx) addInputArtifactsVolumes(pod *apiv1.Pod, tmpl *wfv1.T
 			MountPath: art.Path,
 			SubPath:   art.Name,
 		}
-		if mainCtr.VolumeMounts == nil {
-			mainCtr.VolumeMounts = make([]apiv1.VolumeMount, 0)
+		for _, mainCtr := range mainCtrs {
+			mainCtr.VolumeMounts = append(mainCtr.VolumeMounts, volMount)
 		}
-		mainCtr.VolumeMounts = append(mainCtr.VolumeMounts, volMount)
 	}
-	pod.Spec.Containers[mainCtrIndex] = *mainCtr
 	return nil
 }
 
@@ -502,6 +634,28 @@ func (woc *wfOperationCtx) addArchiveLocation(pod *apiv1.Pod, tmpl *wfv1.Templat
 			ArtifactoryAuth: woc.controller.Config.ArtifactRepository.Artifactory.ArtifactoryAuth,
 			URL:             artURL,
 		}
+	} else if woc.controller.Config.ArtifactRepository.GCS != nil {
+		log.Debugf("Setting GCS artifact repository information")
+		keyPrefix := ""
+		if woc.controller.Config.ArtifactRepository.GCS.KeyPrefix != "" {
+			keyPrefix = woc.controller.Config.ArtifactRepository.GCS.KeyPrefix + "/"
+		}
+		artLocationKey := fmt.Sprintf("%s%s/%s", keyPrefix, woc.wf.ObjectMeta.Name, pod.ObjectMeta.Name)
+		tmpl.ArchiveLocation.GCS = &wfv1.GCSArtifact{
+			GCSBucket: woc.controller.Config.ArtifactRepository.GCS.GCSBucket,
+			Key:       artLocationKey,
+		}
+	} else if woc.controller.Config.ArtifactRepository.Azure != nil {
+		log.Debugf("Setting Azure artifact repository information")
+		blobPrefix := ""
+		if woc.controller.Config.ArtifactRepository.Azure.BlobPrefix != "" {
+			blobPrefix = woc.controller.Config.ArtifactRepository.Azure.BlobPrefix + "/"
+		}
+		artLocationBlob := fmt.Sprintf("%s%s/%s", blobPrefix, woc.wf.ObjectMeta.Name, pod.ObjectMeta.Name)
+		tmpl.ArchiveLocation.Azure = &wfv1.AzureArtifact{
+			AzureContainer: woc.controller.Config.ArtifactRepository.Azure.AzureContainer,
+			Blob:           artLocationBlob,
+		}
 	} else {
 		for _, art := range tmpl.Outputs.Artifacts {
 			if !art.HasLocation() {
@@ -564,31 +718,163 @@ func addSidecars(pod *apiv1.Pod, tmpl *wfv1.Template) error {
 	if len(tmpl.Sidecars) == 0 {
 		return nil
 	}
-	var mainCtr *apiv1.Container
+	mainCtr := getMainContainer(pod)
+	for _, sidecar := range tmpl.Sidecars {
+		if sidecar.MirrorVolumeMounts != nil && *sidecar.MirrorVolumeMounts {
+			mirrorVolumeMounts(mainCtr, &sidecar.Container)
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, sidecar.Container)
+	}
+	return nil
+}
+
+// addInitContainers adds the user specified init containers of a template to the pod. They are
+// added after the executor init container so that they run after the input artifacts are loaded.
+func addInitContainers(pod *apiv1.Pod, tmpl *wfv1.Template) error {
+	if len(tmpl.InitContainers) == 0 {
+		return nil
+	}
+	mainCtr := getMainContainer(pod)
+	for _, initCtr := range tmpl.InitContainers {
+		if initCtr.MirrorVolumeMounts != nil && *initCtr.MirrorVolumeMounts {
+			mirrorVolumeMounts(mainCtr, &initCtr.Container)
+		}
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, initCtr.Container)
+	}
+	return nil
+}
+
+// addContainerSet replaces the main container of the pod with the containers of the container set
+// of the template, in their order. The main container of the set is the one already in the pod.
+// The volumeMounts of the set are added to all its containers.
+func addContainerSet(pod *apiv1.Pod, tmpl *wfv1.Template) {
+	var containers []apiv1.Container
+	for _, ctrNode := range tmpl.ContainerSet.Containers {
+		ctr := ctrNode.Container
+		if ctr.Name == common.MainContainerName {
+			ctr = *getMainContainer(pod)
+		}
+		ctr.VolumeMounts = append(ctr.VolumeMounts, tmpl.ContainerSet.VolumeMounts...)
+		containers = append(containers, ctr)
+	}
 	for _, ctr := range pod.Spec.Containers {
 		if ctr.Name != common.MainContainerName {
-			continue
+			containers = append(containers, ctr)
 		}
-		mainCtr = &ctr
-		break
 	}
-	if mainCtr == nil {
-		panic("Unable to locate main container")
+	pod.Spec.Containers = containers
+}
+
+// containerGateScript returns the script which waits for the wait container to signal that the
+// sidecars are ready, that it holds the root filesystem of the container and that the dependencies
+// of the container completed, fails if a dependency did not succeed, then runs the original command
+// of the container, which is passed as arguments
+func containerGateScript(waitSidecars bool, waitRootFS bool, dependencies []string) string {
+	var script []string
+	if waitSidecars {
+		script = append(script, fmt.Sprintf("until [ -f %s ]; do sleep 1; done", common.ExecutorSidecarsReadyPath))
+	}
+	if waitRootFS {
+		script = append(script, fmt.Sprintf("until [ -f %s ]; do sleep 1; done", common.ExecutorRootFSSecuredPath))
+	}
+	for _, depName := range dependencies {
+		exitCodePath := common.GetContainerExitCodePath(depName)
+		script = append(script,
+			fmt.Sprintf("until [ -f %s ]; do sleep 1; done", exitCodePath),
+			fmt.Sprintf(`[ "$(cat %s)" = 0 ] || { echo "dependency '%s' did not succeed" >&2; exit 1; }`, exitCodePath, depName))
+	}
+	script = append(script, `exec "$@"`)
+	return strings.Join(script, "; ")
+}
+
+// addContainerGates delays the command of the main containers until the wait container signals
+// that all sidecars with a readiness probe are ready, that it holds the root filesystem of the
+// containers if waitRootFS is set and, for the containers of a container set, that their
+// dependencies completed. Commands are wrapped in a shell loop, so the images of the gated
+// containers must provide sh. Containers without a command are not gated on their root filesystem.
+func addContainerGates(pod *apiv1.Pod, tmpl *wfv1.Template, waitRootFS bool) error {
+	waitSidecars := common.HasSidecarReadinessGate(tmpl)
+	waitRootFS = waitRootFS && common.HasRootFSGate(tmpl)
+	if !waitSidecars && !waitRootFS && !common.HasContainerSetDependencies(tmpl) {
+		return nil
 	}
-	for _, sidecar := range tmpl.Sidecars {
-		if sidecar.MirrorVolumeMounts != nil && *sidecar.MirrorVolumeMounts {
-			for _, volMnt := range mainCtr.VolumeMounts {
-				if sidecar.VolumeMounts == nil {
-					sidecar.VolumeMounts = make([]apiv1.VolumeMount, 0)
-				}
-				sidecar.VolumeMounts = append(sidecar.VolumeMounts, volMnt)
+	dependencies := make(map[string][]string)
+	if tmpl.ContainerSet != nil {
+		for _, ctr := range tmpl.ContainerSet.Containers {
+			dependencies[ctr.Name] = ctr.Dependencies
+		}
+	}
+	mainCtrs := make(map[string]bool)
+	for _, name := range common.GetMainContainerNames(tmpl) {
+		mainCtrs[name] = true
+	}
+	volName := "kubext-signals"
+	pod.Spec.Volumes = append(pod.Spec.Volumes, apiv1.Volume{
+		Name: volName,
+		VolumeSource: apiv1.VolumeSource{
+			EmptyDir: &apiv1.EmptyDirVolumeSource{},
+		},
+	})
+	volMount := apiv1.VolumeMount{
+		Name:      volName,
+		MountPath: common.ExecutorSignalsDir,
+	}
+	for i, ctr := range pod.Spec.Containers {
+		if mainCtrs[ctr.Name] {
+			gateRootFS := waitRootFS && len(ctr.Command) > 0
+			if !waitSidecars && !gateRootFS && len(dependencies[ctr.Name]) == 0 {
+				continue
+			}
+			if len(ctr.Command) == 0 {
+				return errors.Errorf(errors.CodeBadRequest, "the command of container '%s' is required to wait for sidecar readiness or dependencies", ctr.Name)
 			}
+			ctr.Command = append([]string{"sh", "-c", containerGateScript(waitSidecars, gateRootFS, dependencies[ctr.Name]), "sh"}, ctr.Command...)
+		} else if ctr.Name != common.WaitContainerName {
+			continue
 		}
-		pod.Spec.Containers = append(pod.Spec.Containers, sidecar.Container)
+		ctr.VolumeMounts = append(ctr.VolumeMounts, volMount)
+		pod.Spec.Containers[i] = ctr
 	}
 	return nil
 }
 
+// getMainContainer returns the main container of the pod
+func getMainContainer(pod *apiv1.Pod) *apiv1.Container {
+	for i, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.MainContainerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	panic("Unable to locate main container")
+}
+
+// addWaitVolumeMounts mounts the volumes of the main container to the wait container, at the same
+// mountPaths. Volumes already mounted at a mountPath of the wait container are skipped.
+func addWaitVolumeMounts(pod *apiv1.Pod) {
+	for i, ctr := range pod.Spec.Containers {
+		if ctr.Name != common.WaitContainerName {
+			continue
+		}
+		mountPaths := make(map[string]bool)
+		for _, volMnt := range ctr.VolumeMounts {
+			mountPaths[volMnt.MountPath] = true
+		}
+		for _, volMnt := range getMainContainer(pod).VolumeMounts {
+			if !mountPaths[volMnt.MountPath] {
+				ctr.VolumeMounts = append(ctr.VolumeMounts, volMnt)
+			}
+		}
+		pod.Spec.Containers[i] = ctr
+	}
+}
+
+// mirrorVolumeMounts mounts the volumes of the main container to a container, at the same mountPaths
+func mirrorVolumeMounts(mainCtr *apiv1.Container, ctr *apiv1.Container) {
+	for _, volMnt := range mainCtr.VolumeMounts {
+		ctr.VolumeMounts = append(ctr.VolumeMounts, volMnt)
+	}
+}
+
 // verifyResolvedVariables is a helper to ensure all {{variables}} have been resolved
 func verifyResolvedVariables(tmplStr string) error {
 	var unresolvedErr error
@@ -1,9 +1,13 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
+	"github.com/jbrette/kubext/managed/common"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/jbrette/kubext/util/tracing"
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
@@ -161,3 +165,351 @@ func TestMetadata(t *testing.T) {
 		assert.Equal(t, pod.ObjectMeta.Labels[k], v)
 	}
 }
+
+// TestPodMetadata verifies the managed pod metadata and propagated labels are applied to pods
+func TestPodMetadata(t *testing.T) {
+	wf := unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Labels = map[string]string{"team": "finance", "env": "dev"}
+	wf.Spec.PodMetadata = &wfv1.Metadata{
+		Annotations: map[string]string{"managed-annotation": "managed", "shared-annotation": "managed"},
+		Labels:      map[string]string{"managed-label": "managed", "team": "other"},
+	}
+	wf.Spec.Templates[0].Metadata = wfv1.Metadata{
+		Annotations: map[string]string{"shared-annotation": "template"},
+	}
+	woc := newWoc(*wf)
+	woc.controller.Config.PropagatedLabels = []string{"team", "cost-center"}
+	woc.executeContainer(woc.wf.Spec.Entrypoint, &woc.wf.Spec.Templates[0], "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "managed", pod.ObjectMeta.Annotations["managed-annotation"])
+	assert.Equal(t, "template", pod.ObjectMeta.Annotations["shared-annotation"])
+	assert.Equal(t, "managed", pod.ObjectMeta.Labels["managed-label"])
+	assert.Equal(t, "finance", pod.ObjectMeta.Labels["team"])
+	assert.NotContains(t, pod.ObjectMeta.Labels, "env")
+	assert.NotContains(t, pod.ObjectMeta.Labels, "cost-center")
+}
+
+// TestPodMetadataReservedKeys verifies the managed pod metadata and propagated labels do not
+// overwrite the labels and annotations reserved by the controller
+func TestPodMetadataReservedKeys(t *testing.T) {
+	wf := unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Labels = map[string]string{common.LabelKeyCompleted: "true"}
+	wf.Spec.PodMetadata = &wfv1.Metadata{
+		Annotations: map[string]string{common.AnnotationKeyNodeName: "other"},
+		Labels:      map[string]string{common.LabelKeyManaged: "other"},
+	}
+	woc := newWoc(*wf)
+	woc.controller.Config.PropagatedLabels = []string{common.LabelKeyCompleted}
+	woc.executeContainer(woc.wf.Spec.Entrypoint, &woc.wf.Spec.Templates[0], "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, woc.wf.Spec.Entrypoint, pod.ObjectMeta.Annotations[common.AnnotationKeyNodeName])
+	assert.Equal(t, woc.wf.ObjectMeta.Name, pod.ObjectMeta.Labels[common.LabelKeyManaged])
+	assert.Equal(t, "false", pod.ObjectMeta.Labels[common.LabelKeyCompleted])
+
+	err = woc.controller.updateConfig(&apiv1.ConfigMap{
+		Data: map[string]string{
+			common.ManagedControllerConfigMapKey: "executorImage: executor:latest\npropagatedLabels: [team, manageds.jbrette.io/phase]\n",
+		},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "label 'manageds.jbrette.io/phase' is reserved")
+	}
+}
+
+// TestPodSpecPatch verifies the managed and template pod spec patches are applied to the pod
+func TestPodSpecPatch(t *testing.T) {
+	wf := unmarshalWF(helloWorldWf)
+	wf.Spec.PodSpecPatch = `{"priorityClassName": "low", "schedulerName": "{{managed.name}}-scheduler"}`
+	wf.Spec.Templates[0].PodSpecPatch = `
+priorityClassName: high
+hostAliases:
+- ip: 127.0.0.1
+  hostnames: [foo.local]
+containers:
+- name: main
+  env:
+  - name: FOO
+    value: bar
+`
+	woc := newWoc(*wf)
+	woc.operate()
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "high", pod.Spec.PriorityClassName)
+	assert.Equal(t, woc.wf.ObjectMeta.Name+"-scheduler", pod.Spec.SchedulerName)
+	assert.Equal(t, []string{"foo.local"}, pod.Spec.HostAliases[0].Hostnames)
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.MainContainerName {
+			// the patch is merged into the main container rather than replacing it
+			assert.Equal(t, "docker/whalesay:latest", ctr.Image)
+			assert.Equal(t, []apiv1.EnvVar{{Name: "FOO", Value: "bar"}}, ctr.Env)
+		}
+	}
+}
+
+var initContainersTemplate = `
+name: init-containers
+inputs:
+  artifacts:
+  - name: kubectl
+    path: /bin/kubectl
+    http:
+      url: https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubectl
+initContainers:
+- name: fetch-certs
+  image: alpine:latest
+  command: [sh, -c, "ls /bin/kubectl"]
+  mirrorVolumeMounts: true
+- name: wait-for-db
+  image: alpine:latest
+container:
+  image: alpine:latest
+  command: [sh, -c, "ls /bin/kubectl"]
+`
+
+// TestInitContainers verifies user init containers run after the executor init container and mirror the main volume mounts
+func TestInitContainers(t *testing.T) {
+	tmpl := unmarshalTemplate(initContainersTemplate)
+	woc := newWoc()
+	woc.executeContainer(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	if assert.Len(t, pod.Spec.InitContainers, 3) {
+		assert.Equal(t, common.InitContainerName, pod.Spec.InitContainers[0].Name)
+		assert.Equal(t, "fetch-certs", pod.Spec.InitContainers[1].Name)
+		assert.Equal(t, "wait-for-db", pod.Spec.InitContainers[2].Name)
+		assert.Equal(t, getMainContainer(pod).VolumeMounts, pod.Spec.InitContainers[1].VolumeMounts)
+		assert.NotEmpty(t, pod.Spec.InitContainers[1].VolumeMounts)
+		assert.Empty(t, pod.Spec.InitContainers[2].VolumeMounts)
+	}
+}
+
+var sidecarReadinessTemplate = `
+name: with-db
+sidecars:
+- name: db
+  image: postgres:latest
+  readinessProbe:
+    tcpSocket:
+      port: 5432
+container:
+  image: alpine:latest
+  command: [sh, -c]
+  args: ["psql -h localhost"]
+`
+
+// TestSidecarReadinessGate verifies the main container waits for the signal of the executor when a sidecar has a readiness probe
+func TestSidecarReadinessGate(t *testing.T) {
+	tmpl := unmarshalTemplate(sidecarReadinessTemplate)
+	woc := newWoc()
+	woc.executeContainer(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	mainCtr := getMainContainer(pod)
+	if assert.NotNil(t, mainCtr) {
+		assert.Equal(t, []string{"sh", "-c", containerGateScript(true, false, nil), "sh", "sh", "-c"}, mainCtr.Command)
+		assert.Equal(t, []string{"psql -h localhost"}, mainCtr.Args)
+		assert.Contains(t, mainCtr.VolumeMounts, apiv1.VolumeMount{Name: "kubext-signals", MountPath: common.ExecutorSignalsDir})
+	}
+}
+
+// TestExecutorLogFormat verifies the executor log format is passed to the executor containers
+func TestExecutorLogFormat(t *testing.T) {
+	tmpl := unmarshalTemplate(scriptTemplateWithInputArtifact)
+	woc := newWoc()
+	woc.controller.Config.ExecutorLogFormat = "json"
+	woc.executeScript(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"init", "--log-format", "json"}, pod.Spec.InitContainers[0].Args)
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.WaitContainerName {
+			assert.Equal(t, []string{"wait", "--log-format", "json"}, ctr.Args)
+		}
+	}
+}
+
+// TestK8sAPIContainerRuntimeExecutor verifies the k8sapi executor is selected without mounting the docker socket
+func TestK8sAPIContainerRuntimeExecutor(t *testing.T) {
+	tmpl := unmarshalTemplate(scriptTemplateWithInputArtifact)
+	woc := newWoc()
+	woc.controller.Config.ContainerRuntimeExecutor = common.ContainerRuntimeExecutorK8sAPI
+	woc.executeScript(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	for _, vol := range pod.Spec.Volumes {
+		assert.NotEqual(t, volumeDockerSock.Name, vol.Name)
+		assert.NotEqual(t, volumeDockerLib.Name, vol.Name)
+	}
+	mainCtr := getMainContainer(pod)
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.WaitContainerName {
+			assert.Contains(t, ctr.Env, apiv1.EnvVar{Name: common.EnvVarContainerRuntimeExecutor, Value: common.ContainerRuntimeExecutorK8sAPI})
+			// the wait container reads the outputs from the volumes of the main container
+			for _, volMnt := range mainCtr.VolumeMounts {
+				assert.Contains(t, ctr.VolumeMounts, volMnt)
+			}
+		}
+	}
+}
+
+var outputsOnVolumeTemplate = `
+name: outputs-on-volume
+container:
+  image: alpine:latest
+  command: [sh, -c, "echo hello > /work/message"]
+  volumeMounts:
+  - name: work
+    mountPath: /work
+outputs:
+  parameters:
+  - name: message
+    valueFrom:
+      path: /work/message
+  artifacts:
+  - name: message
+    path: /work/message
+`
+
+// TestK8sAPIOutputsOnVolumes verifies the k8sapi executor rejects outputs which are not saved on a volume
+func TestK8sAPIOutputsOnVolumes(t *testing.T) {
+	wf := unmarshalWF(helloWorldWf)
+	wf.Spec.Volumes = []apiv1.Volume{{Name: "work", VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}}}}
+	woc := newWoc(*wf)
+	woc.controller.Config.ContainerRuntimeExecutor = common.ContainerRuntimeExecutorK8sAPI
+	woc.controller.Config.ArtifactRepository.GCS = &GCSArtifactRepository{GCSBucket: wfv1.GCSBucket{Bucket: "bucket"}}
+	tmpl := unmarshalTemplate(outputsOnVolumeTemplate)
+	node := woc.executeContainer(tmpl.Name, tmpl, "")
+	assert.Equal(t, wfv1.NodeRunning, node.Phase, node.Message)
+
+	woc = newWoc()
+	woc.controller.Config.ContainerRuntimeExecutor = common.ContainerRuntimeExecutorK8sAPI
+	tmpl = unmarshalTemplate(outputsOnVolumeTemplate)
+	tmpl.Outputs.Artifacts[0].Path = "/tmp/message"
+	node = woc.executeContainer(tmpl.Name, tmpl, "")
+	assert.Equal(t, wfv1.NodeError, node.Phase)
+	assert.Contains(t, node.Message, "outputs.artifacts.message")
+}
+
+// TestPNSContainerRuntimeExecutor verifies the pns executor shares the process namespace of the pod
+func TestPNSContainerRuntimeExecutor(t *testing.T) {
+	tmpl := unmarshalTemplate(scriptTemplateWithInputArtifact)
+	woc := newWoc()
+	woc.controller.Config.ContainerRuntimeExecutor = common.ContainerRuntimeExecutorPNS
+	woc.executeScript(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	if assert.NotNil(t, pod.Spec.ShareProcessNamespace) {
+		assert.True(t, *pod.Spec.ShareProcessNamespace)
+	}
+	for _, vol := range pod.Spec.Volumes {
+		assert.NotEqual(t, volumeDockerSock.Name, vol.Name)
+	}
+	signalsMount := apiv1.VolumeMount{Name: "kubext-signals", MountPath: common.ExecutorSignalsDir}
+	for _, ctr := range pod.Spec.Containers {
+		switch ctr.Name {
+		case common.WaitContainerName:
+			assert.Equal(t, []apiv1.Capability{"SYS_PTRACE"}, ctr.SecurityContext.Capabilities.Add)
+			assert.Contains(t, ctr.VolumeMounts, signalsMount)
+		case common.MainContainerName:
+			// the main container runs once the wait container holds its root filesystem
+			assert.Equal(t, []string{"sh", "-c", containerGateScript(false, true, nil), "sh", "sh"}, ctr.Command)
+			assert.Contains(t, ctr.VolumeMounts, signalsMount)
+		}
+	}
+
+	// a container without a command cannot be gated
+	woc = newWoc()
+	woc.controller.Config.ContainerRuntimeExecutor = common.ContainerRuntimeExecutorPNS
+	woc.wf.Spec.Templates[0].Container.Command = nil
+	woc.executeContainer(woc.wf.Spec.Entrypoint, &woc.wf.Spec.Templates[0], "")
+	pod, err = woc.controller.kubeclientset.CoreV1().Pods("").Get(getPodName(woc.wf), metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, getMainContainer(pod).Command)
+}
+
+// TestGCSArtifactRepository verifies templates archive their outputs in the GCS artifact repository of the controller
+func TestGCSArtifactRepository(t *testing.T) {
+	tmpl := unmarshalTemplate(scriptTemplateWithInputArtifact)
+	woc := newWoc()
+	woc.controller.Config.ArtifactRepository.GCS = &GCSArtifactRepository{
+		GCSBucket: wfv1.GCSBucket{
+			Bucket:                  "my-bucket",
+			ServiceAccountKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-gcs-credentials"}, Key: "serviceAccountKey"},
+		},
+		KeyPrefix: "my-prefix",
+	}
+	woc.executeScript(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	var podTmpl wfv1.Template
+	err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyTemplate]), &podTmpl)
+	assert.Nil(t, err)
+	if assert.NotNil(t, podTmpl.ArchiveLocation) && assert.NotNil(t, podTmpl.ArchiveLocation.GCS) {
+		assert.Equal(t, "my-bucket", podTmpl.ArchiveLocation.GCS.Bucket)
+		assert.Equal(t, "my-gcs-credentials", podTmpl.ArchiveLocation.GCS.ServiceAccountKeySecret.Name)
+		assert.Equal(t, "my-prefix/"+woc.wf.ObjectMeta.Name+"/"+podName, podTmpl.ArchiveLocation.GCS.Key)
+	}
+}
+
+// TestAzureArtifactRepository verifies templates archive their outputs in the Azure artifact repository of the controller
+func TestAzureArtifactRepository(t *testing.T) {
+	tmpl := unmarshalTemplate(scriptTemplateWithInputArtifact)
+	woc := newWoc()
+	woc.controller.Config.ArtifactRepository.Azure = &AzureArtifactRepository{
+		AzureContainer: wfv1.AzureContainer{
+			Account:          "myaccount",
+			Container:        "my-container",
+			AccountKeySecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "my-azure-credentials"}, Key: "accountKey"},
+		},
+		BlobPrefix: "my-prefix",
+	}
+	woc.executeScript(tmpl.Name, tmpl, "")
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	var podTmpl wfv1.Template
+	err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyTemplate]), &podTmpl)
+	assert.Nil(t, err)
+	if assert.NotNil(t, podTmpl.ArchiveLocation) && assert.NotNil(t, podTmpl.ArchiveLocation.Azure) {
+		assert.Equal(t, "my-container", podTmpl.ArchiveLocation.Azure.Container)
+		assert.Equal(t, "my-azure-credentials", podTmpl.ArchiveLocation.Azure.AccountKeySecret.Name)
+		assert.Equal(t, "my-prefix/"+woc.wf.ObjectMeta.Name+"/"+podName, podTmpl.ArchiveLocation.Azure.Blob)
+	}
+}
+
+// TestTraceContextAnnotation verifies the trace context of the executing template is propagated to the pod
+func TestTraceContextAnnotation(t *testing.T) {
+	recorder := &tracing.Recorder{}
+	tracing.SetProcessor(recorder)
+	defer tracing.SetProcessor(nil)
+
+	woc := newWoc()
+	woc.operate()
+	podName := getPodName(woc.wf)
+	pod, err := woc.controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	var carrier map[string]string
+	err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyTraceContext]), &carrier)
+	assert.Nil(t, err)
+	remoteCtx := tracing.SpanContextFromContext(tracing.Extract(context.Background(), carrier))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "executeTemplate", spans[0].Name())
+		assert.Equal(t, "operate", spans[1].Name())
+		assert.Equal(t, spans[1].SpanContext().SpanID, spans[0].Parent())
+		assert.Equal(t, spans[0].SpanContext(), remoteCtx)
+	}
+}
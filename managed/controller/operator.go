@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -12,12 +13,15 @@ import (
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/jbrette/kubext/pkg/client/clientset/versioned/typed/managed/v1alpha1"
 	"github.com/jbrette/kubext/util/retry"
+	"github.com/jbrette/kubext/util/tracing"
 	"github.com/jbrette/kubext/managed/common"
 	jsonpatch "github.com/evanphx/json-patch"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -47,6 +51,9 @@ type wfOperationCtx struct {
 	// activePods tracks the number of active (Running/Pending) pods for controlling
 	// parallelism
 	activePods int64
+	// ctx carries the trace span of the template currently being executed, which is
+	// propagated to the pods created for it
+	ctx context.Context
 }
 
 var (
@@ -56,10 +63,6 @@ var (
 	ErrParallelismReached = errors.New(errors.CodeForbidden, "Max parallelism reached")
 )
 
-// maxOperationTime is the maximum time a managed operation is allowed to run
-// for before requeuing the managed onto the workqueue.
-const maxOperationTime time.Duration = 10 * time.Second
-
 // wfScope contains the current scope of variables available when iterating steps in a managed
 type wfScope struct {
 	tmpl  *wfv1.Template
@@ -82,7 +85,8 @@ func newManagedOperationCtx(wf *wfv1.Managed, wfc *ManagedController) *wfOperati
 		controller:    wfc,
 		globalParams:  make(map[string]string),
 		completedPods: make(map[string]bool),
-		deadline:      time.Now().UTC().Add(maxOperationTime),
+		deadline:      time.Now().UTC().Add(wfc.maxOperationTime()),
+		ctx:           context.Background(),
 	}
 
 	if woc.wf.Status.Nodes == nil {
@@ -92,11 +96,35 @@ func newManagedOperationCtx(wf *wfv1.Managed, wfc *ManagedController) *wfOperati
 	return &woc
 }
 
+// nodeLogger returns the operation logger with fields identifying the node and its template,
+// as well as its pod when the node is a pod node. Pods are named after their node ID.
+func (woc *wfOperationCtx) nodeLogger(nodeID string, templateName string, isPod bool) *log.Entry {
+	fields := log.Fields{
+		"nodeID":   nodeID,
+		"template": templateName,
+	}
+	if isPod {
+		fields["podName"] = nodeID
+	}
+	return woc.log.WithFields(fields)
+}
+
+// nodeLog returns the operation logger with fields identifying the given node
+func (woc *wfOperationCtx) nodeLog(node *wfv1.NodeStatus) *log.Entry {
+	return woc.nodeLogger(node.ID, node.TemplateName, node.Type == wfv1.NodeTypePod)
+}
+
 // operate is the main operator logic of a managed. It evaluates the current state of the managed,
 // and its pods and decides how to proceed down the execution path.
 // TODO: an error returned by this method should result in requeuing the managed to be retried at a
 // later time
 func (woc *wfOperationCtx) operate() {
+	ctx, span := tracing.Start(context.Background(), "operate",
+		tracing.String("managed", woc.wf.ObjectMeta.Name),
+		tracing.String("namespace", woc.wf.ObjectMeta.Namespace),
+	)
+	woc.ctx = ctx
+	defer span.End()
 	defer woc.persistUpdates()
 	defer func() {
 		if r := recover(); r != nil {
@@ -177,6 +205,12 @@ func (woc *wfOperationCtx) operate() {
 		return
 	}
 
+	if woc.wf.Spec.DeleteResourcesOnCompletion {
+		// unlike PVCs, resources the controller fails to delete, e.g. because its service account
+		// may not delete their kind, do not prevent the completion of the managed
+		woc.deleteResources()
+	}
+
 	// If we get here, the managed completed, all PVCs were deleted successfully, and
 	// exit handlers were executed. We now need to infer the managed phase from the
 	// node phase.
@@ -324,6 +358,16 @@ func (woc *wfOperationCtx) requeue() {
 	woc.controller.wfQueue.Add(key)
 }
 
+// requeueAfter requeues the managed after the given duration
+func (woc *wfOperationCtx) requeueAfter(d time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(woc.wf)
+	if err != nil {
+		woc.log.Errorf("Failed to requeue managed %s: %v", woc.wf.ObjectMeta.Name, err)
+		return
+	}
+	woc.controller.wfQueue.AddAfter(key, d)
+}
+
 func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrategy wfv1.RetryStrategy) error {
 	if node.Completed() {
 		return nil
@@ -350,30 +394,38 @@ func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrate
 	}
 
 	if !lastChildNode.CanRetry() {
-		woc.log.Infof("Node cannot be retried. Marking it failed")
+		woc.nodeLog(node).Infof("Node cannot be retried. Marking it failed")
+		woc.markNodePhase(node.Name, wfv1.NodeFailed, lastChildNode.Message)
+		return nil
+	}
+
+	if !retryStrategy.ShouldRetry(lastChildNode.FailureReason) {
+		woc.nodeLog(node).Infof("Node failure reason '%s' is not retried. Marking it failed", lastChildNode.FailureReason)
 		woc.markNodePhase(node.Name, wfv1.NodeFailed, lastChildNode.Message)
 		return nil
 	}
 
 	if retryStrategy.Limit != nil && int32(len(node.Children)) > *retryStrategy.Limit {
-		woc.log.Infoln("No more retries left. Failing...")
+		woc.nodeLog(node).Infoln("No more retries left. Failing...")
 		woc.markNodePhase(node.Name, wfv1.NodeFailed, "No more retries left")
 		return nil
 	}
 
-	woc.log.Infof("%d child nodes of %s failed. Trying again...", len(node.Children), node.Name)
+	woc.nodeLog(node).Infof("%d child nodes of %s failed. Trying again...", len(node.Children), node.Name)
 	return nil
 }
 
 // podReconciliation is the process by which a managed will examine all its related
 // pods and update the node state before continuing the evaluation of the managed.
 // Records all pods which were observed completed, which will be labeled completed=true
-// after successful persist of the managed.
+// after successful persist of the managed. The resources duration of the managed is updated
+// from the assessed pod nodes whichever way the reconciliation returns.
 func (woc *wfOperationCtx) podReconciliation() error {
-	podList, err := woc.getRunningManagedPods()
+	podList, err := woc.getIncompleteManagedPods()
 	if err != nil {
 		return err
 	}
+	defer woc.updateResourcesDuration()
 	seenPods := make(map[string]bool)
 
 	performAssessment := func(pod *apiv1.Pod) {
@@ -381,7 +433,7 @@ func (woc *wfOperationCtx) podReconciliation() error {
 		nodeID := woc.wf.NodeID(nodeNameForPod)
 		seenPods[nodeID] = true
 		if node, ok := woc.wf.Status.Nodes[nodeID]; ok {
-			if newState := assessNodeStatus(pod, &node); newState != nil {
+			if newState := woc.assessNodeStatus(pod, &node); newState != nil {
 				woc.wf.Status.Nodes[nodeID] = *newState
 				if node.Outputs != nil {
 					for _, param := range node.Outputs.Parameters {
@@ -393,7 +445,12 @@ func (woc *wfOperationCtx) podReconciliation() error {
 				}
 				woc.updated = true
 			}
-			if woc.wf.Status.Nodes[pod.ObjectMeta.Name].Completed() {
+			node = woc.wf.Status.Nodes[nodeID]
+			woc.assessContainerNodes(pod, &node)
+			if pod.Status.Phase == apiv1.PodPending && woc.wf.Status.Nodes[nodeID].Phase == wfv1.NodeError {
+				// the pod exceeded its pending timeout. Delete it so it does not start running later
+				woc.deletePod(pod)
+			} else if woc.wf.Status.Nodes[pod.ObjectMeta.Name].Completed() {
 				woc.completedPods[pod.ObjectMeta.Name] = true
 			}
 		}
@@ -409,17 +466,28 @@ func (woc *wfOperationCtx) podReconciliation() error {
 		return nil
 	}
 	// If we get here, our initial query for pods related to this managed returned nothing.
-	// Note that our initial query excludes Pending/completed=true pods for performance reasons
-	// since there's generally no action needed to be taken on pending pods or ones we have
-	// already processed (completed=true).
+	// Note that our initial query excludes completed=true pods for performance reasons
+	// since there's generally no action needed to be taken on pods we have already
+	// processed (completed=true).
 	// There are a few scenarios where the pod list would have been empty:
-	//  1. managed's pods are still pending (best case scenario)
+	//  1. managed's pods were created but are not in the informer cache yet
 	//  2. managed's pods were deleted unbeknownst to the controller
 	//  3. managed's pods were marked completed=true, but we are operating on a stale managed object
 	//  4. combination of any the above scenarios
 	// In order to detect deleted pods, we repeat the pod reconciliation process, this time
 	// including ALL managed pods in the query. If any one of our nodes does not show up in this
 	// returned list, it implies that the pod was deleted without the controller seeing the event.
+	// Since this requires a live List call, skip it when no pod node is expected to have a pod.
+	incompletePodNodes := false
+	for _, node := range woc.wf.Status.Nodes {
+		if node.Type == wfv1.NodeTypePod && !node.Completed() {
+			incompletePodNodes = true
+			break
+		}
+	}
+	if !incompletePodNodes {
+		return nil
+	}
 	woc.log.Info("Checking for deleted pods")
 	podList, err = woc.getAllManagedPods()
 	if err != nil {
@@ -442,8 +510,10 @@ func (woc *wfOperationCtx) podReconciliation() error {
 		if _, ok := seenPods[nodeID]; !ok {
 			node.Message = "pod deleted"
 			node.Phase = wfv1.NodeError
+			node.FailureReason = wfv1.NodeDeleted
 			woc.wf.Status.Nodes[nodeID] = node
-			woc.log.Warnf("pod %s deleted", nodeID)
+			woc.nodeLog(&node).Warnf("pod %s deleted", nodeID)
+			woc.assessContainerNodes(nil, &node)
 			woc.updated = true
 		}
 	}
@@ -471,14 +541,29 @@ func (woc *wfOperationCtx) countActivePods(boundaryIDs ...string) int64 {
 	return activePods
 }
 
-// getRunningManagedPods returns running pods of the current managed.
-func (woc *wfOperationCtx) getRunningManagedPods() (*apiv1.PodList, error) {
+// getIncompleteManagedPods returns the pods of the current managed not yet labeled completed. Pods are looked up in the
+// pod informer cache, and are only listed from the API server if the informer is not running.
+func (woc *wfOperationCtx) getIncompleteManagedPods() (*apiv1.PodList, error) {
+	if woc.controller.podInformer != nil {
+		objs, err := woc.controller.podInformer.GetIndexer().ByIndex(indexManaged, woc.wf.ObjectMeta.Namespace+"/"+woc.wf.ObjectMeta.Name)
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		podList := apiv1.PodList{}
+		for _, obj := range objs {
+			pod, ok := obj.(*apiv1.Pod)
+			if !ok {
+				continue
+			}
+			podList.Items = append(podList.Items, *pod)
+		}
+		return &podList, nil
+	}
 	options := metav1.ListOptions{
 		LabelSelector: fmt.Sprintf("%s=%s,%s=false",
 			common.LabelKeyManaged,
 			woc.wf.ObjectMeta.Name,
 			common.LabelKeyCompleted),
-		FieldSelector: "status.phase!=Pending",
 	}
 	podList, err := woc.controller.kubeclientset.CoreV1().Pods(woc.wf.Namespace).List(options)
 	if err != nil {
@@ -487,6 +572,72 @@ func (woc *wfOperationCtx) getRunningManagedPods() (*apiv1.PodList, error) {
 	return podList, nil
 }
 
+// deletePod deletes a pod of the managed
+func (woc *wfOperationCtx) deletePod(pod *apiv1.Pod) {
+	err := woc.controller.kubeclientset.CoreV1().Pods(pod.ObjectMeta.Namespace).Delete(pod.ObjectMeta.Name, &metav1.DeleteOptions{})
+	if err != nil && !apierr.IsNotFound(err) {
+		woc.log.Warnf("Failed to delete pod %s: %v", pod.ObjectMeta.Name, err)
+		return
+	}
+	woc.log.Infof("Deleted pod %s", pod.ObjectMeta.Name)
+}
+
+// getPodTemplate returns the template of a pod from its template annotation
+func getPodTemplate(pod *apiv1.Pod) (*wfv1.Template, error) {
+	tmplStr, ok := pod.Annotations[common.AnnotationKeyTemplate]
+	if !ok {
+		return nil, errors.InternalErrorf("%s missing template annotation", pod.ObjectMeta.Name)
+	}
+	var tmpl wfv1.Template
+	err := json.Unmarshal([]byte(tmplStr), &tmpl)
+	if err != nil {
+		return nil, errors.InternalWrapErrorf(err, "%s template annotation unreadable: %v", pod.ObjectMeta.Name, err)
+	}
+	return &tmpl, nil
+}
+
+// getPendingReason returns the reason a pending pod is not running yet, such as an unschedulable
+// condition or a container waiting on an image pull error. Returns an empty string if the pod is
+// progressing normally (e.g. waiting to be scheduled or creating its containers), apart from
+// reporting the user specified init container which is running.
+func getPendingReason(pod *apiv1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == apiv1.PodScheduled && cond.Status == apiv1.ConditionFalse && cond.Reason == apiv1.PodReasonUnschedulable {
+			return fmt.Sprintf("%s: %s", cond.Reason, cond.Message)
+		}
+	}
+	var ctrStatuses []apiv1.ContainerStatus
+	ctrStatuses = append(ctrStatuses, pod.Status.InitContainerStatuses...)
+	ctrStatuses = append(ctrStatuses, pod.Status.ContainerStatuses...)
+	for _, ctr := range ctrStatuses {
+		if ctr.State.Waiting == nil {
+			continue
+		}
+		switch ctr.State.Waiting.Reason {
+		case "", "ContainerCreating", "PodInitializing":
+			continue
+		}
+		if ctr.State.Waiting.Message == "" {
+			return fmt.Sprintf("%s: %s", ctr.Name, ctr.State.Waiting.Reason)
+		}
+		return fmt.Sprintf("%s: %s: %s", ctr.Name, ctr.State.Waiting.Reason, ctr.State.Waiting.Message)
+	}
+	for _, ctr := range pod.Status.InitContainerStatuses {
+		if ctr.Name != common.InitContainerName && ctr.State.Running != nil {
+			return fmt.Sprintf("running init container '%s'", ctr.Name)
+		}
+	}
+	return ""
+}
+
+// updatedNode returns the node if it was updated, or nil otherwise
+func updatedNode(node *wfv1.NodeStatus, updated bool) *wfv1.NodeStatus {
+	if updated {
+		return node
+	}
+	return nil
+}
+
 // getAllManagedPods returns all pods related to the current managed
 func (woc *wfOperationCtx) getAllManagedPods() (*apiv1.PodList, error) {
 	options := metav1.ListOptions{
@@ -503,52 +654,94 @@ func (woc *wfOperationCtx) getAllManagedPods() (*apiv1.PodList, error) {
 
 // assessNodeStatus compares the current state of a pod with its corresponding node
 // and returns the new node status if something changed
-func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
+func (woc *wfOperationCtx) assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
+	logger := woc.nodeLog(node)
 	var newPhase wfv1.NodePhase
 	var newDaemonStatus *bool
 	var message string
 	updated := false
 	f := false
+	if pod.Spec.NodeName != node.HostNodeName || pod.Status.HostIP != node.HostIP {
+		logger.Infof("Updating node %s host %s (%s)", node, pod.Spec.NodeName, pod.Status.HostIP)
+		node.HostNodeName = pod.Spec.NodeName
+		node.HostIP = pod.Status.HostIP
+		updated = true
+	}
 	switch pod.Status.Phase {
 	case apiv1.PodPending:
-		return nil
+		// A pending pod keeps the node running, but the reason it is not running yet is surfaced
+		// in the node message, and the node errors once it has been pending for too long
+		newPhase = node.Phase
+		message = getPendingReason(pod)
+		if message != node.Message {
+			logger.Infof("Updating node %s pending message: %s", node, message)
+			node.Message = message
+			updated = true
+		}
+		tmpl, err := getPodTemplate(pod)
+		if err != nil {
+			logger.Warnf("%v", err)
+			break
+		}
+		if tmpl.PodPendingTimeout == nil {
+			break
+		}
+		timeout := time.Duration(*tmpl.PodPendingTimeout) * time.Second
+		pending := time.Since(pod.ObjectMeta.CreationTimestamp.Time)
+		if pending < timeout {
+			woc.requeueAfter(timeout - pending)
+			break
+		}
+		newPhase = wfv1.NodeError
+		message = fmt.Sprintf("pod pending for more than %ds", *tmpl.PodPendingTimeout)
+		if node.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, node.Message)
+		}
 	case apiv1.PodSucceeded:
 		newPhase = wfv1.NodeSucceeded
 		newDaemonStatus = &f
 	case apiv1.PodFailed:
 		newPhase, message = inferFailedReason(pod)
 		newDaemonStatus = &f
+		reason, exitCode, signal := inferFailureDetails(pod)
+		// the details of a failed pod never change, so they only need to be recorded once
+		if node.FailureReason != reason || (node.ExitCode == nil) != (exitCode == nil) || (node.Signal == nil) != (signal == nil) {
+			logger.Infof("Updating node %s failure reason: %s", node, reason)
+			node.FailureReason = reason
+			node.ExitCode = exitCode
+			node.Signal = signal
+			updated = true
+		}
 	case apiv1.PodRunning:
-		tmplStr, ok := pod.Annotations[common.AnnotationKeyTemplate]
-		if !ok {
-			log.Warnf("%s missing template annotation", pod.ObjectMeta.Name)
-			return nil
+		if node.Message != "" && node.Phase == wfv1.NodeRunning {
+			// clear the reason the pod was pending
+			node.Message = ""
+			updated = true
 		}
-		var tmpl wfv1.Template
-		err := json.Unmarshal([]byte(tmplStr), &tmpl)
+		tmpl, err := getPodTemplate(pod)
 		if err != nil {
-			log.Warnf("%s template annotation unreadable: %v", pod.ObjectMeta.Name, err)
-			return nil
+			logger.Warnf("%v", err)
+			return updatedNode(node, updated)
 		}
 		if tmpl.Daemon == nil || !*tmpl.Daemon {
 			// incidental state change of a running pod. No need to inspect further
-			return nil
+			return updatedNode(node, updated)
 		}
 		// pod is running and template is marked daemon. check if everything is ready
 		for _, ctrStatus := range pod.Status.ContainerStatuses {
 			if !ctrStatus.Ready {
-				return nil
+				return updatedNode(node, updated)
 			}
 		}
 		// proceed to mark node status as succeeded (and daemoned)
 		newPhase = wfv1.NodeSucceeded
 		t := true
 		newDaemonStatus = &t
-		log.Infof("Processing ready daemon pod: %v", pod.ObjectMeta.SelfLink)
+		logger.Infof("Processing ready daemon pod: %v", pod.ObjectMeta.SelfLink)
 	default:
 		newPhase = wfv1.NodeError
 		message = fmt.Sprintf("Unexpected pod phase for %s: %s", pod.ObjectMeta.Name, pod.Status.Phase)
-		log.Error(message)
+		logger.Error(message)
 	}
 
 	if newDaemonStatus != nil {
@@ -558,12 +751,12 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 			newDaemonStatus = nil
 		}
 		if (newDaemonStatus != nil && node.Daemoned == nil) || (newDaemonStatus == nil && node.Daemoned != nil) {
-			log.Infof("Setting node %v daemoned: %v -> %v", node, node.Daemoned, newDaemonStatus)
+			logger.Infof("Setting node %v daemoned: %v -> %v", node, node.Daemoned, newDaemonStatus)
 			node.Daemoned = newDaemonStatus
 			updated = true
 			if pod.Status.PodIP != "" && pod.Status.PodIP != node.PodIP {
 				// only update Pod IP for daemoned nodes to reduce number of updates
-				log.Infof("Updating daemon node %s IP %s -> %s", node, node.PodIP, pod.Status.PodIP)
+				logger.Infof("Updating daemon node %s IP %s -> %s", node, node.PodIP, pod.Status.PodIP)
 				node.PodIP = pod.Status.PodIP
 			}
 		}
@@ -571,27 +764,41 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 	outputStr, ok := pod.Annotations[common.AnnotationKeyOutputs]
 	if ok && node.Outputs == nil {
 		updated = true
-		log.Infof("Setting node %v outputs", node)
+		logger.Infof("Setting node %v outputs", node)
 		var outputs wfv1.Outputs
 		err := json.Unmarshal([]byte(outputStr), &outputs)
 		if err != nil {
-			log.Errorf("Failed to unmarshal %s outputs from pod annotation: %v", pod.Name, err)
+			logger.Errorf("Failed to unmarshal %s outputs from pod annotation: %v", pod.Name, err)
 			node.Phase = wfv1.NodeError
 		} else {
 			node.Outputs = &outputs
 		}
 	}
+	refsStr, ok := pod.Annotations[common.AnnotationKeyResourceRefs]
+	if ok && node.ResourceRefs == nil {
+		updated = true
+		logger.Infof("Setting node %v resource refs", node)
+		var refs []wfv1.ResourceRef
+		err := json.Unmarshal([]byte(refsStr), &refs)
+		if err != nil {
+			logger.Errorf("Failed to unmarshal %s resource refs from pod annotation: %v", pod.Name, err)
+			node.Phase = wfv1.NodeError
+		} else {
+			node.ResourceRefs = refs
+		}
+	}
 	if message != "" && node.Message != message {
-		log.Infof("Updating node %s message: %s", node, message)
+		logger.Infof("Updating node %s message: %s", node, message)
 		node.Message = message
 	}
 	if node.Phase != newPhase {
-		log.Infof("Updating node %s status %s -> %s", node, node.Phase, newPhase)
+		logger.Infof("Updating node %s status %s -> %s", node, node.Phase, newPhase)
 		updated = true
 		node.Phase = newPhase
 	}
 	if node.Completed() && node.FinishedAt.IsZero() {
 		updated = true
+		node.ResourcesDuration = podResourcesDuration(pod, time.Now())
 		if !node.IsDaemoned() {
 			node.FinishedAt = getLatestFinishedAt(pod)
 		}
@@ -624,6 +831,94 @@ func getLatestFinishedAt(pod *apiv1.Pod) metav1.Time {
 	return latest
 }
 
+// getMainContainerNames returns the names of the containers of a pod which run the user workload
+func getMainContainerNames(pod *apiv1.Pod) []string {
+	tmpl, err := getPodTemplate(pod)
+	if err != nil {
+		return []string{common.MainContainerName}
+	}
+	return common.GetMainContainerNames(tmpl)
+}
+
+// getFailedMainContainer returns the status of the main container of a pod which failed first,
+// or nil if none failed. In a container set, the containers depending on a failed container
+// fail after it, so the first failure is the most relevant one.
+func getFailedMainContainer(pod *apiv1.Pod) *apiv1.ContainerStatus {
+	var failed *apiv1.ContainerStatus
+	for _, name := range getMainContainerNames(pod) {
+		for i, ctr := range pod.Status.ContainerStatuses {
+			if ctr.Name != name || ctr.State.Terminated == nil || ctr.State.Terminated.ExitCode == 0 {
+				continue
+			}
+			if failed == nil || ctr.State.Terminated.FinishedAt.Before(&failed.State.Terminated.FinishedAt) {
+				failed = &pod.Status.ContainerStatuses[i]
+			}
+		}
+	}
+	return failed
+}
+
+// getFailedInitContainer returns the status of the user specified init container of a pod which
+// failed, or nil if none failed
+func getFailedInitContainer(pod *apiv1.Pod) *apiv1.ContainerStatus {
+	for i, ctr := range pod.Status.InitContainerStatuses {
+		if ctr.Name != common.InitContainerName && ctr.State.Terminated != nil && ctr.State.Terminated.ExitCode != 0 {
+			return &pod.Status.InitContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// inferFailureDetails returns the machine-readable reason a Failed pod failed, along with the
+// exit code and signal of its main container (or of the container of its container set which
+// failed first). Reason, exit code and signal are empty when unknown.
+func inferFailureDetails(pod *apiv1.Pod) (wfv1.NodeFailureReason, *int32, *int32) {
+	var reason wfv1.NodeFailureReason
+	switch pod.Status.Reason {
+	case "Evicted":
+		reason = wfv1.NodeEvicted
+	case "DeadlineExceeded":
+		reason = wfv1.NodeDeadlineExceeded
+	case "NodeLost":
+		reason = wfv1.NodeLost
+	case "Preempting", "Preempted":
+		reason = wfv1.NodePreempted
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == "DisruptionTarget" && cond.Status == apiv1.ConditionTrue && strings.HasPrefix(cond.Reason, "PreemptionBy") {
+			reason = wfv1.NodePreempted
+		}
+	}
+	if reason == "" && getFailedInitContainer(pod) != nil {
+		reason = wfv1.NodeInitContainerFailed
+	}
+	var exitCode, signal *int32
+	mainCtrName := common.MainContainerName
+	if failedCtr := getFailedMainContainer(pod); failedCtr != nil {
+		mainCtrName = failedCtr.Name
+	}
+	for _, ctr := range pod.Status.ContainerStatuses {
+		if ctr.Name != mainCtrName || ctr.State.Terminated == nil {
+			continue
+		}
+		terminated := ctr.State.Terminated
+		if reason == "" && terminated.Reason == "OOMKilled" {
+			reason = wfv1.NodeOOMKilled
+		}
+		code := terminated.ExitCode
+		exitCode = &code
+		if terminated.Signal != 0 {
+			sig := terminated.Signal
+			signal = &sig
+		} else if code > 128 {
+			// shells and container runtimes report death by signal N as exit code 128+N
+			sig := code - 128
+			signal = &sig
+		}
+	}
+	return reason, exitCode, signal
+}
+
 // inferFailedReason returns metadata about a Failed pod to be used in its NodeStatus
 // Returns a tuple of the new phase and message
 func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
@@ -632,9 +927,14 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
 		return wfv1.NodeFailed, pod.Status.Message
 	}
 	annotatedMsg := pod.Annotations[common.AnnotationKeyNodeMessage]
+	isMainCtr := make(map[string]bool)
+	for _, name := range getMainContainerNames(pod) {
+		isMainCtr[name] = true
+	}
 	// We only get one message to set for the overall node status.
 	// If multiple containers failed, in order of preference:
 	// init, main (annotated), main (exit code), wait, sidecars
+	// In a container set, the container which failed first is preferred over main.
 	for _, ctr := range pod.Status.InitContainerStatuses {
 		if ctr.State.Terminated == nil {
 			// We should never get here
@@ -644,6 +944,13 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
 		if ctr.State.Terminated.ExitCode == 0 {
 			continue
 		}
+		if ctr.Name != common.InitContainerName {
+			// user specified init container
+			if ctr.State.Terminated.Message != "" {
+				return wfv1.NodeFailed, fmt.Sprintf("init container '%s' failed: %s", ctr.Name, ctr.State.Terminated.Message)
+			}
+			return wfv1.NodeFailed, fmt.Sprintf("init container '%s' failed with exit code %d", ctr.Name, ctr.State.Terminated.ExitCode)
+		}
 		errMsg := fmt.Sprintf("failed to load artifacts")
 		for _, msg := range []string{annotatedMsg, ctr.State.Terminated.Message} {
 			if msg != "" {
@@ -680,6 +987,12 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
 			}
 			errMsg := fmt.Sprintf("failed to save outputs: %s", errDetails)
 			failMessages[ctr.Name] = errMsg
+		} else if isMainCtr[ctr.Name] && ctr.Name != common.MainContainerName {
+			if ctr.State.Terminated.Message != "" {
+				failMessages[ctr.Name] = fmt.Sprintf("container '%s' failed: %s", ctr.Name, ctr.State.Terminated.Message)
+			} else {
+				failMessages[ctr.Name] = fmt.Sprintf("container '%s' failed with exit code %d", ctr.Name, ctr.State.Terminated.ExitCode)
+			}
 		} else {
 			if ctr.State.Terminated.Message != "" {
 				failMessages[ctr.Name] = ctr.State.Terminated.Message
@@ -692,6 +1005,9 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
 			}
 		}
 	}
+	if failedCtr := getFailedMainContainer(pod); failedCtr != nil && failedCtr.Name != common.MainContainerName {
+		return wfv1.NodeFailed, failMessages[failedCtr.Name]
+	}
 	if failMsg, ok := failMessages[common.MainContainerName]; ok {
 		_, ok = failMessages[common.WaitContainerName]
 		isResourceTemplate := !ok
@@ -750,6 +1066,12 @@ func (woc *wfOperationCtx) createPVCs() error {
 		pvcTmpl.OwnerReferences = []metav1.OwnerReference{
 			*metav1.NewControllerRef(woc.wf, wfv1.SchemaGroupVersionKind),
 		}
+		for k, v := range woc.propagatedLabels() {
+			if pvcTmpl.ObjectMeta.Labels == nil {
+				pvcTmpl.ObjectMeta.Labels = make(map[string]string)
+			}
+			pvcTmpl.ObjectMeta.Labels[k] = v
+		}
 		pvc, err := pvcClient.Create(&pvcTmpl)
 		if err != nil {
 			return err
@@ -800,6 +1122,81 @@ func (woc *wfOperationCtx) deletePVCs() error {
 	return firstErr
 }
 
+// deleteResources deletes the resources created by the resource templates of the managed. Only
+// the resources in the namespace of the managed which carry its owner reference or UID are
+// deleted. Failures are logged as warnings.
+func (woc *wfOperationCtx) deleteResources() {
+	total, deleted := 0, 0
+	for _, node := range woc.wf.Status.Nodes {
+		for _, ref := range node.ResourceRefs {
+			total++
+			err := woc.deleteResource(ref)
+			if err != nil {
+				woc.log.Warnf("Failed to delete %s %s/%s: %v", ref.Kind, ref.Namespace, ref.Name, err)
+				continue
+			}
+			deleted++
+		}
+	}
+	if total > 0 {
+		woc.log.Infof("Deleted %d/%d resources", deleted, total)
+	}
+}
+
+// deleteResource deletes a resource created by a resource template. The reference is recorded
+// from a pod annotation, which the containers of the pod may overwrite, so the resource must
+// belong to the managed. A resource which is gone, or which was recreated with the same name,
+// is ignored.
+func (woc *wfOperationCtx) deleteResource(ref wfv1.ResourceRef) error {
+	if ref.Namespace != woc.wf.ObjectMeta.Namespace {
+		return errors.Errorf(errors.CodeForbidden, "only resources in namespace %s can be deleted", woc.wf.ObjectMeta.Namespace)
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	client, err := woc.controller.dynamicClientPool.ClientForGroupVersionResource(gv.WithResource(ref.Resource))
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	apiResource := &metav1.APIResource{Name: ref.Resource, Kind: ref.Kind, Namespaced: true}
+	ri := client.Resource(apiResource, ref.Namespace)
+	obj, err := ri.Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if ref.UID != "" && string(obj.GetUID()) != ref.UID {
+		return nil
+	}
+	if !woc.ownsResource(obj) {
+		return errors.Errorf(errors.CodeForbidden, "%s %s/%s does not belong to the managed", ref.Kind, ref.Namespace, ref.Name)
+	}
+	uid := obj.GetUID()
+	woc.log.Infof("Deleting %s %s/%s", ref.Kind, ref.Namespace, ref.Name)
+	err = ri.Delete(ref.Name, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+	if err != nil && !apierr.IsNotFound(err) && !apierr.IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// ownsResource returns whether a resource carries an owner reference to the managed, or its UID
+// as annotated by the executor which created it
+func (woc *wfOperationCtx) ownsResource(obj *unstructured.Unstructured) bool {
+	if woc.wf.ObjectMeta.UID == "" {
+		return false
+	}
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.UID == woc.wf.ObjectMeta.UID {
+			return true
+		}
+	}
+	return obj.GetAnnotations()[common.AnnotationKeyManagedUID] == string(woc.wf.ObjectMeta.UID)
+}
+
 func (woc *wfOperationCtx) getLastChildNode(node *wfv1.NodeStatus) (*wfv1.NodeStatus, error) {
 	if len(node.Children) <= 0 {
 		return nil, nil
@@ -819,10 +1216,21 @@ func (woc *wfOperationCtx) getLastChildNode(node *wfv1.NodeStatus) (*wfv1.NodeSt
 // nodeName is the name to be used as the name of the node, and boundaryID indicates which template
 // boundary this node belongs to.
 func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Arguments, nodeName string, boundaryID string) (*wfv1.NodeStatus, error) {
-	woc.log.Debugf("Evaluating node %s: template: %s", nodeName, templateName)
+	ctx, span := tracing.Start(woc.ctx, "executeTemplate",
+		tracing.String("node", nodeName),
+		tracing.String("template", templateName),
+	)
+	parentCtx := woc.ctx
+	woc.ctx = ctx
+	defer func() {
+		woc.ctx = parentCtx
+		span.End()
+	}()
+	logger := woc.nodeLogger(woc.wf.NodeID(nodeName), templateName, false)
+	logger.Debugf("Evaluating node %s: template: %s", nodeName, templateName)
 	node := woc.getNodeByName(nodeName)
 	if node != nil && node.Completed() {
-		woc.log.Debugf("Node %s already completed", nodeName)
+		logger.Debugf("Node %s already completed", nodeName)
 		return node, nil
 	}
 
@@ -854,7 +1262,7 @@ func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Argume
 	}
 
 	switch tmpl.GetType() {
-	case wfv1.TemplateTypeContainer:
+	case wfv1.TemplateTypeContainer, wfv1.TemplateTypeContainerSet:
 		if tmpl.RetryStrategy != nil {
 			node = woc.executeRetryContainer(nodeName, tmpl, boundaryID)
 		} else {
@@ -870,6 +1278,8 @@ func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Argume
 		node = woc.executeDAG(nodeName, tmpl, boundaryID)
 	case wfv1.TemplateTypeSuspend:
 		node = woc.executeSuspend(nodeName, tmpl, boundaryID)
+	case wfv1.TemplateTypeHTTP:
+		node = woc.executeHTTP(nodeName, tmpl, boundaryID)
 	default:
 		err = errors.Errorf(errors.CodeBadRequest, "Template '%s' missing specification", tmpl.Name)
 		node = woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, templateName, boundaryID, wfv1.NodeError, err.Error())
@@ -973,7 +1383,7 @@ func (woc *wfOperationCtx) initializeNode(nodeName string, nodeType wfv1.NodeTyp
 		node.Message = messages[0]
 	}
 	woc.wf.Status.Nodes[nodeID] = node
-	woc.log.Infof("%s node %s initialized %s%s", node.Type, node, node.Phase, message)
+	woc.nodeLog(&node).Infof("%s node %s initialized %s%s", node.Type, node, node.Phase, message)
 	woc.updated = true
 	return &node
 }
@@ -985,20 +1395,20 @@ func (woc *wfOperationCtx) markNodePhase(nodeName string, phase wfv1.NodePhase,
 		panic(fmt.Sprintf("node %s uninitialized", nodeName))
 	}
 	if node.Phase != phase {
-		woc.log.Infof("node %s phase %s -> %s", node, node.Phase, phase)
+		woc.nodeLog(node).Infof("node %s phase %s -> %s", node, node.Phase, phase)
 		node.Phase = phase
 		woc.updated = true
 	}
 	if len(message) > 0 {
 		if message[0] != node.Message {
-			woc.log.Infof("node %s message: %s", node, message[0])
+			woc.nodeLog(node).Infof("node %s message: %s", node, message[0])
 			node.Message = message[0]
 			woc.updated = true
 		}
 	}
 	if node.Completed() && node.FinishedAt.IsZero() {
 		node.FinishedAt = metav1.Time{Time: time.Now().UTC()}
-		woc.log.Infof("node %s finished: %s", node, node.FinishedAt)
+		woc.nodeLog(node).Infof("node %s finished: %s", node, node.FinishedAt)
 		woc.updated = true
 	}
 	woc.wf.Status.Nodes[node.ID] = *node
@@ -1084,18 +1494,91 @@ func (woc *wfOperationCtx) executeContainer(nodeName string, tmpl *wfv1.Template
 	if node != nil {
 		return node
 	}
-	woc.log.Debugf("Executing node %s with container template: %v\n", nodeName, tmpl)
-	_, err := woc.createManagedPod(nodeName, *tmpl.Container, tmpl)
+	woc.nodeLogger(woc.wf.NodeID(nodeName), tmpl.Name, true).Debugf("Executing node %s with container template: %v\n", nodeName, tmpl)
+	mainCtr := tmpl.Container
+	if tmpl.ContainerSet != nil {
+		ctrNode := tmpl.ContainerSet.GetContainer(common.MainContainerName)
+		if ctrNode == nil {
+			err := errors.Errorf(errors.CodeBadRequest, "containerSet of template '%s' has no container named '%s'", tmpl.Name, common.MainContainerName)
+			return woc.initializeNode(nodeName, wfv1.NodeTypePod, tmpl.Name, boundaryID, wfv1.NodeError, err.Error())
+		}
+		mainCtr = &ctrNode.Container
+	}
+	_, err := woc.createManagedPod(nodeName, *mainCtr, tmpl)
 	if err != nil {
 		return woc.initializeNode(nodeName, wfv1.NodeTypePod, tmpl.Name, boundaryID, wfv1.NodeError, err.Error())
 	}
-	return woc.initializeNode(nodeName, wfv1.NodeTypePod, tmpl.Name, boundaryID, wfv1.NodeRunning)
+	node = woc.initializeNode(nodeName, wfv1.NodeTypePod, tmpl.Name, boundaryID, wfv1.NodeRunning)
+	if tmpl.ContainerSet != nil {
+		woc.initializeContainerNodes(nodeName, tmpl, boundaryID)
+		node = woc.getNodeByName(nodeName)
+	}
+	return node
+}
+
+// initializeContainerNodes initializes a child node of the pod node for each container of the
+// container set of the template, to report the status of each container
+func (woc *wfOperationCtx) initializeContainerNodes(nodeName string, tmpl *wfv1.Template, boundaryID string) {
+	for _, ctr := range tmpl.ContainerSet.Containers {
+		ctrNodeName := fmt.Sprintf("%s.%s", nodeName, ctr.Name)
+		ctrNode := woc.initializeNode(ctrNodeName, wfv1.NodeTypeContainer, tmpl.Name, boundaryID, wfv1.NodeRunning)
+		ctrNode.DisplayName = ctr.Name
+		woc.wf.Status.Nodes[ctrNode.ID] = *ctrNode
+		woc.addChildNode(nodeName, ctrNodeName)
+	}
+}
+
+// assessContainerNodes updates the child nodes of a container set pod node from the statuses of
+// the containers of the pod. The pod is nil when it was deleted.
+func (woc *wfOperationCtx) assessContainerNodes(pod *apiv1.Pod, node *wfv1.NodeStatus) {
+	for _, childID := range node.Children {
+		ctrNode, ok := woc.wf.Status.Nodes[childID]
+		if !ok || ctrNode.Type != wfv1.NodeTypeContainer || ctrNode.Completed() {
+			continue
+		}
+		var terminated *apiv1.ContainerStateTerminated
+		if pod != nil {
+			for _, ctrStatus := range pod.Status.ContainerStatuses {
+				if ctrStatus.Name == ctrNode.DisplayName {
+					terminated = ctrStatus.State.Terminated
+				}
+			}
+		}
+		switch {
+		case terminated != nil:
+			exitCode := terminated.ExitCode
+			ctrNode.ExitCode = &exitCode
+			ctrNode.FinishedAt = terminated.FinishedAt
+			if exitCode == 0 {
+				ctrNode.Phase = wfv1.NodeSucceeded
+			} else {
+				ctrNode.Phase = wfv1.NodeFailed
+				ctrNode.Message = terminated.Message
+				if ctrNode.Message == "" {
+					ctrNode.Message = fmt.Sprintf("failed with exit code %d", exitCode)
+				}
+			}
+		case node.Completed():
+			// the pod completed or was deleted without the container reporting its termination
+			ctrNode.Phase = node.Phase
+			ctrNode.Message = node.Message
+			ctrNode.FinishedAt = node.FinishedAt
+		default:
+			continue
+		}
+		if ctrNode.FinishedAt.IsZero() {
+			ctrNode.FinishedAt = metav1.Time{Time: time.Now().UTC()}
+		}
+		woc.nodeLog(&ctrNode).Infof("Updating container node %s status %s", ctrNode, ctrNode.Phase)
+		woc.wf.Status.Nodes[childID] = ctrNode
+		woc.updated = true
+	}
 }
 
 func (woc *wfOperationCtx) getOutboundNodes(nodeID string) []string {
 	node := woc.wf.Status.Nodes[nodeID]
 	switch node.Type {
-	case wfv1.NodeTypePod, wfv1.NodeTypeSkipped, wfv1.NodeTypeSuspend:
+	case wfv1.NodeTypePod, wfv1.NodeTypeSkipped, wfv1.NodeTypeSuspend, wfv1.NodeTypeHTTP:
 		return []string{node.ID}
 	}
 	outbound := make([]string, 0)
@@ -1340,7 +1823,7 @@ func (woc *wfOperationCtx) executeResource(nodeName string, tmpl *wfv1.Template,
 	mainCtr := apiv1.Container{
 		Image:   woc.controller.Config.ExecutorImage,
 		Command: []string{"kubextexec"},
-		Args:    []string{"resource", tmpl.Resource.Action},
+		Args:    woc.executorArgs("resource", tmpl.Resource.Action),
 		VolumeMounts: []apiv1.VolumeMount{
 			volumeMountPodMetadata,
 		},
@@ -3,14 +3,24 @@ package controller
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/jbrette/kubext/test"
 	"github.com/jbrette/kubext/managed/common"
 	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
 // TestOperateManagedPanicRecover ensures we can recover from unexpected panics
@@ -104,6 +114,23 @@ func TestSidecarWithVolume(t *testing.T) {
 	assert.True(t, existingVolFound, "existing vol was not referenced by sidecar")
 }
 
+// TestPropagatedLabelsPVC verifies the propagated labels of a managed are applied to its PVCs
+func TestPropagatedLabelsPVC(t *testing.T) {
+	controller := newController()
+	controller.Config.PropagatedLabels = []string{"team"}
+	wf := unmarshalWF(sidecarWithVol)
+	wf.ObjectMeta.Labels = map[string]string{"team": "finance"}
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+	pvcs, err := controller.kubeclientset.CoreV1().PersistentVolumeClaims(wf.ObjectMeta.Namespace).List(metav1.ListOptions{})
+	assert.Nil(t, err)
+	if assert.Len(t, pvcs.Items, 1) {
+		assert.Equal(t, "finance", pvcs.Items[0].ObjectMeta.Labels["team"])
+	}
+}
+
 // TestProcessNodesWithRetries tests the processNodesWithRetries() method.
 func TestProcessNodesWithRetries(t *testing.T) {
 	controller := newController()
@@ -814,3 +841,399 @@ func TestGlobalParamSubstitutionWithArtifact(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, len(pods.Items), 1)
 }
+
+// TestPodReconciliationFromInformer verifies pods are reconciled from the pod informer cache
+func TestPodReconciliationFromInformer(t *testing.T) {
+	controller := newController()
+	wf := unmarshalWF(helloWorldWf)
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+
+	// move the pod from the API server into the informer cache only
+	podName := getPodName(woc.wf)
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	err = controller.kubeclientset.CoreV1().Pods("").Delete(podName, &metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	pod.Status.Phase = apiv1.PodSucceeded
+	controller.podInformer = cache.NewSharedIndexInformer(nil, &apiv1.Pod{}, 0, cache.Indexers{
+		indexManaged: managedIndexFunc,
+	})
+	err = controller.podInformer.GetIndexer().Add(pod)
+	assert.Nil(t, err)
+
+	woc = newManagedOperationCtx(woc.wf, controller)
+	err = woc.podReconciliation()
+	assert.Nil(t, err)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Nodes[podName].Phase)
+	assert.True(t, woc.completedPods[podName])
+}
+
+// TestPendingPod verifies the reason of a pending pod is surfaced and the node errors after the pending timeout
+func TestPendingPod(t *testing.T) {
+	controller := newController()
+	controller.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	wf := unmarshalWF(helloWorldWf)
+	timeout := int64(60)
+	wf.Spec.Templates[0].PodPendingTimeout = &timeout
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	podName := getPodName(woc.wf)
+	pod, err := podcs.Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	pod.ObjectMeta.CreationTimestamp = metav1.Now()
+	pod.Status.Phase = apiv1.PodPending
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name: common.MainContainerName,
+		State: apiv1.ContainerState{
+			Waiting: &apiv1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"},
+		},
+	}}
+	_, err = podcs.Update(pod)
+	assert.Nil(t, err)
+
+	woc = newManagedOperationCtx(woc.wf, controller)
+	err = woc.podReconciliation()
+	assert.Nil(t, err)
+	node := woc.wf.Status.Nodes[podName]
+	assert.Equal(t, wfv1.NodeRunning, node.Phase)
+	assert.Equal(t, "main: ImagePullBackOff: Back-off pulling image", node.Message)
+
+	pod.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	_, err = podcs.Update(pod)
+	assert.Nil(t, err)
+	woc = newManagedOperationCtx(woc.wf, controller)
+	err = woc.podReconciliation()
+	assert.Nil(t, err)
+	node = woc.wf.Status.Nodes[podName]
+	assert.Equal(t, wfv1.NodeError, node.Phase)
+	assert.Equal(t, "pod pending for more than 60s: main: ImagePullBackOff: Back-off pulling image", node.Message)
+	_, err = podcs.Get(podName, metav1.GetOptions{})
+	assert.True(t, apierr.IsNotFound(err))
+}
+
+// TestPendingReason verifies the reasons of a pending pod
+func TestPendingReason(t *testing.T) {
+	pod := &apiv1.Pod{}
+	assert.Equal(t, "", getPendingReason(pod))
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  common.MainContainerName,
+		State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+	}}
+	assert.Equal(t, "", getPendingReason(pod))
+	pod.Status.Conditions = []apiv1.PodCondition{{
+		Type:    apiv1.PodScheduled,
+		Status:  apiv1.ConditionFalse,
+		Reason:  apiv1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 3 Insufficient cpu.",
+	}}
+	assert.Equal(t, "Unschedulable: 0/3 nodes are available: 3 Insufficient cpu.", getPendingReason(pod))
+}
+
+// TestInferFailureDetails verifies the failure reason, exit code and signal of failed pods
+func TestInferFailureDetails(t *testing.T) {
+	pod := &apiv1.Pod{}
+	pod.Status.Reason = "Evicted"
+	reason, exitCode, signal := inferFailureDetails(pod)
+	assert.Equal(t, wfv1.NodeEvicted, reason)
+	assert.Nil(t, exitCode)
+	assert.Nil(t, signal)
+
+	pod = &apiv1.Pod{}
+	pod.Status.Conditions = []apiv1.PodCondition{{
+		Type:   "DisruptionTarget",
+		Status: apiv1.ConditionTrue,
+		Reason: "PreemptionByScheduler",
+	}}
+	reason, _, _ = inferFailureDetails(pod)
+	assert.Equal(t, wfv1.NodePreempted, reason)
+
+	pod = &apiv1.Pod{}
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{
+		{
+			Name:  common.WaitContainerName,
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}},
+		},
+		{
+			Name:  common.MainContainerName,
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+		},
+	}
+	reason, exitCode, signal = inferFailureDetails(pod)
+	assert.Equal(t, wfv1.NodeOOMKilled, reason)
+	if assert.NotNil(t, exitCode) && assert.NotNil(t, signal) {
+		assert.Equal(t, int32(137), *exitCode)
+		assert.Equal(t, int32(9), *signal)
+	}
+}
+
+// TestRetryOn verifies nodes are only retried for the failure reasons of the retry strategy
+func TestRetryOn(t *testing.T) {
+	controller := newController()
+	wf := unmarshalWF(helloWorldWf)
+	woc := newManagedOperationCtx(wf, controller)
+
+	nodeName := "test-node"
+	woc.initializeNode(nodeName, wfv1.NodeTypeRetry, "", "", wfv1.NodeRunning)
+	retries := wfv1.RetryStrategy{RetryOn: []wfv1.NodeFailureReason{wfv1.NodeEvicted}}
+	woc.initializeNode("child-node-0", wfv1.NodeTypePod, "", "", wfv1.NodeRunning)
+	woc.addChildNode(nodeName, "child-node-0")
+
+	child := woc.getNodeByName("child-node-0")
+	child.FailureReason = wfv1.NodeEvicted
+	woc.wf.Status.Nodes[child.ID] = *child
+	woc.markNodePhase(child.Name, wfv1.NodeFailed, "The node was low on resource: memory.")
+	err := woc.processNodeRetries(woc.getNodeByName(nodeName), retries)
+	assert.Nil(t, err)
+	assert.Equal(t, wfv1.NodeRunning, woc.getNodeByName(nodeName).Phase)
+
+	child = woc.getNodeByName("child-node-0")
+	child.FailureReason = wfv1.NodeOOMKilled
+	woc.wf.Status.Nodes[child.ID] = *child
+	err = woc.processNodeRetries(woc.getNodeByName(nodeName), retries)
+	assert.Nil(t, err)
+	node := woc.getNodeByName(nodeName)
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Equal(t, "The node was low on resource: memory.", node.Message)
+}
+
+// TestInitContainerFailed verifies the failure of a user init container is distinguished from artifact loading
+func TestInitContainerFailed(t *testing.T) {
+	pod := &apiv1.Pod{}
+	pod.Status.InitContainerStatuses = []apiv1.ContainerStatus{
+		{
+			Name:  common.InitContainerName,
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}},
+		},
+		{
+			Name:  "wait-for-db",
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 2}},
+		},
+	}
+	phase, message := inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, "init container 'wait-for-db' failed with exit code 2", message)
+	reason, exitCode, _ := inferFailureDetails(pod)
+	assert.Equal(t, wfv1.NodeInitContainerFailed, reason)
+	assert.Nil(t, exitCode)
+
+	pod.Status.Phase = apiv1.PodPending
+	pod.Status.InitContainerStatuses[1].State = apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	assert.Equal(t, "running init container 'wait-for-db'", getPendingReason(pod))
+}
+
+var containerSetWf = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  name: container-set
+spec:
+  entrypoint: client-server
+  volumes:
+  - name: workspace
+    emptyDir: {}
+  templates:
+  - name: client-server
+    containerSet:
+      volumeMounts:
+      - name: workspace
+        mountPath: /workspace
+      containers:
+      - name: server
+        image: nginx:latest
+        command: [nginx]
+      - name: main
+        image: alpine:latest
+        command: [sh, -c]
+        args: ["wget -O /workspace/index.html localhost"]
+        dependencies: [setup]
+      - name: setup
+        image: alpine:latest
+        command: [sh, -c]
+        args: ["echo setup"]
+`
+
+// TestContainerSet verifies the containers of a container set run in one pod, each reported by a child node
+func TestContainerSet(t *testing.T) {
+	controller := newController()
+	wf := unmarshalWF(containerSetWf)
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+
+	podName := woc.wf.NodeID(wf.ObjectMeta.Name)
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	var ctrNames []string
+	for _, ctr := range pod.Spec.Containers {
+		ctrNames = append(ctrNames, ctr.Name)
+		if ctr.Name != common.WaitContainerName {
+			assert.Contains(t, ctr.VolumeMounts, apiv1.VolumeMount{Name: "workspace", MountPath: "/workspace"})
+		}
+	}
+	assert.Equal(t, []string{"server", common.MainContainerName, "setup", common.WaitContainerName}, ctrNames)
+	mainCtr := getMainContainer(pod)
+	assert.Equal(t, []string{"sh", "-c", containerGateScript(false, false, []string{"setup"}), "sh", "sh", "-c"}, mainCtr.Command)
+	assert.Equal(t, []string{"nginx"}, pod.Spec.Containers[0].Command)
+
+	node := woc.wf.Status.Nodes[podName]
+	assert.Equal(t, wfv1.NodeTypePod, node.Type)
+	if assert.Len(t, node.Children, 3) {
+		for _, childID := range node.Children {
+			assert.Equal(t, wfv1.NodeTypeContainer, woc.wf.Status.Nodes[childID].Type)
+			assert.Equal(t, wfv1.NodeRunning, woc.wf.Status.Nodes[childID].Phase)
+		}
+	}
+
+	terminated := func(exitCode int32) apiv1.ContainerState {
+		return apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: exitCode}}
+	}
+	pod.Status.Phase = apiv1.PodFailed
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{
+		{Name: "server", State: terminated(0)},
+		{Name: "setup", State: terminated(3)},
+		{Name: common.MainContainerName, State: terminated(1)},
+		{Name: common.WaitContainerName, State: terminated(0)},
+	}
+	pod.Status.ContainerStatuses[2].State.Terminated.FinishedAt = metav1.Time{Time: time.Now().Add(time.Second)}
+	_, err = controller.kubeclientset.CoreV1().Pods("").Update(pod)
+	assert.Nil(t, err)
+	woc = newManagedOperationCtx(woc.wf, controller)
+	err = woc.podReconciliation()
+	assert.Nil(t, err)
+
+	node = woc.wf.Status.Nodes[podName]
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Equal(t, "container 'setup' failed with exit code 3", node.Message)
+	if assert.NotNil(t, node.ExitCode) {
+		assert.Equal(t, int32(3), *node.ExitCode)
+	}
+	server := woc.getNodeByName(wf.ObjectMeta.Name + ".server")
+	assert.Equal(t, wfv1.NodeSucceeded, server.Phase)
+	setup := woc.getNodeByName(wf.ObjectMeta.Name + ".setup")
+	assert.Equal(t, wfv1.NodeFailed, setup.Phase)
+	if assert.NotNil(t, setup.ExitCode) {
+		assert.Equal(t, int32(3), *setup.ExitCode)
+	}
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName(wf.ObjectMeta.Name+".main").Phase)
+}
+
+var resourceCleanupWf = `
+apiVersion: jbrette.io/v1alpha1
+kind: Managed
+metadata:
+  name: resource-cleanup
+spec:
+  entrypoint: create
+  deleteResourcesOnCompletion: true
+  templates:
+  - name: create
+    resource:
+      action: create
+      manifest: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: nginx
+`
+
+// newResourceCleanupWoc returns the operation context of a completed resource cleanup managed
+// whose pod recorded the resource refs
+func newResourceCleanupWoc(t *testing.T, pool *fakedynamic.FakeClientPool, refs string) *wfOperationCtx {
+	controller := newController()
+	controller.dynamicClientPool = pool
+	wf := unmarshalWF(resourceCleanupWf)
+	wf.ObjectMeta.Namespace = "default"
+	wf.ObjectMeta.UID = "managed-uid"
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("default").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+
+	podName := woc.wf.NodeID(wf.ObjectMeta.Name)
+	pod, err := controller.kubeclientset.CoreV1().Pods("default").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	pod.Status.Phase = apiv1.PodSucceeded
+	pod.Annotations[common.AnnotationKeyResourceRefs] = refs
+	_, err = controller.kubeclientset.CoreV1().Pods("default").Update(pod)
+	assert.Nil(t, err)
+	return newManagedOperationCtx(woc.wf, controller)
+}
+
+// newFakeDeployment returns a deployment as returned by the dynamic client
+func newFakeDeployment(namespace, name, uid string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetUID(types.UID(uid))
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+// TestDeleteResourcesOnCompletion verifies the resources recorded by resource templates are deleted once the managed completes
+func TestDeleteResourcesOnCompletion(t *testing.T) {
+	pool := &fakedynamic.FakeClientPool{}
+	pool.AddReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, newFakeDeployment("default", "nginx", "1234", map[string]string{common.AnnotationKeyManagedUID: "managed-uid"}), nil
+	})
+	woc := newResourceCleanupWoc(t, pool, `[{"apiVersion":"apps/v1","kind":"Deployment","resource":"deployments","namespace":"default","name":"nginx","uid":"1234"}]`)
+	woc.operate()
+
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+	assert.Len(t, woc.wf.Status.Nodes[woc.wf.NodeID(woc.wf.ObjectMeta.Name)].ResourceRefs, 1)
+	actions := pool.Actions()
+	if assert.Len(t, actions, 2) {
+		deleteAction := actions[1].(k8stesting.DeleteAction)
+		assert.Equal(t, "deployments", deleteAction.GetResource().Resource)
+		assert.Equal(t, "default", deleteAction.GetNamespace())
+		assert.Equal(t, "nginx", deleteAction.GetName())
+	}
+}
+
+// TestDeleteResourcesOnCompletionOwnership verifies only the resources of the managed in its namespace are deleted, and that
+// failed deletions do not prevent the completion of the managed
+func TestDeleteResourcesOnCompletionOwnership(t *testing.T) {
+	pool := &fakedynamic.FakeClientPool{}
+	pool.AddReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		switch name {
+		case "owned":
+			obj := newFakeDeployment("default", name, "1", nil)
+			obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "jbrette.io/v1alpha1", Kind: "Managed", Name: "resource-cleanup", UID: "managed-uid"}})
+			return true, obj, nil
+		case "recreated":
+			return true, newFakeDeployment("default", name, "other", map[string]string{common.AnnotationKeyManagedUID: "managed-uid"}), nil
+		default:
+			return true, newFakeDeployment("default", name, "3", nil), nil
+		}
+	})
+	pool.AddReactor("delete", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierr.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, action.(k8stesting.DeleteAction).GetName(), nil)
+	})
+	woc := newResourceCleanupWoc(t, pool, `[
+		{"apiVersion":"v1","kind":"Pod","resource":"pods","namespace":"kube-system","name":"kube-apiserver"},
+		{"apiVersion":"apps/v1","kind":"Deployment","resource":"deployments","namespace":"default","name":"owned","uid":"1"},
+		{"apiVersion":"apps/v1","kind":"Deployment","resource":"deployments","namespace":"default","name":"recreated","uid":"2"},
+		{"apiVersion":"apps/v1","kind":"Deployment","resource":"deployments","namespace":"default","name":"unowned","uid":"3"}
+	]`)
+	woc.operate()
+
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+	var deleted []string
+	for _, action := range pool.Actions() {
+		assert.Equal(t, "default", action.GetNamespace())
+		if action.GetVerb() == "delete" {
+			deleted = append(deleted, action.(k8stesting.DeleteAction).GetName())
+		}
+	}
+	assert.Equal(t, []string{"owned"}, deleted)
+}
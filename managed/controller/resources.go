@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"time"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podResourcesDuration returns the resources requested by each container of the pod multiplied
+// by the time the container ran, or nil if the pod did not use any resources. Containers without
+// requests are accounted by their limits, which is what Kubernetes defaults their requests to.
+// Containers still running at the given time are accounted up until then.
+func podResourcesDuration(pod *apiv1.Pod, now time.Time) *wfv1.ResourcesDuration {
+	statuses := make(map[string]apiv1.ContainerStatus)
+	for _, ctrStatus := range pod.Status.InitContainerStatuses {
+		statuses[ctrStatus.Name] = ctrStatus
+	}
+	for _, ctrStatus := range pod.Status.ContainerStatuses {
+		statuses[ctrStatus.Name] = ctrStatus
+	}
+	var cpuMilliSeconds, memoryByteSeconds float64
+	ctrs := append(append([]apiv1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, ctr := range ctrs {
+		seconds := containerRuntime(statuses[ctr.Name], now).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		if cpu, ok := containerRequest(ctr, apiv1.ResourceCPU); ok {
+			cpuMilliSeconds += float64(cpu.MilliValue()) * seconds
+		}
+		if memory, ok := containerRequest(ctr, apiv1.ResourceMemory); ok {
+			memoryByteSeconds += float64(memory.Value()) * seconds
+		}
+	}
+	resourcesDuration := wfv1.ResourcesDuration{
+		CPU:    int64(cpuMilliSeconds/1000 + 0.5),
+		Memory: int64(memoryByteSeconds/(1<<20) + 0.5),
+	}
+	if resourcesDuration.IsZero() {
+		return nil
+	}
+	return &resourcesDuration
+}
+
+// containerRuntime returns how long a container ran, or has been running up until now
+func containerRuntime(ctrStatus apiv1.ContainerStatus, now time.Time) time.Duration {
+	switch {
+	case ctrStatus.State.Terminated != nil:
+		terminated := ctrStatus.State.Terminated
+		if terminated.StartedAt.IsZero() || terminated.FinishedAt.IsZero() {
+			return 0
+		}
+		return terminated.FinishedAt.Sub(terminated.StartedAt.Time)
+	case ctrStatus.State.Running != nil:
+		return now.Sub(ctrStatus.State.Running.StartedAt.Time)
+	}
+	return 0
+}
+
+// containerRequest returns the quantity of a resource requested by a container, falling back
+// to its limit
+func containerRequest(ctr apiv1.Container, name apiv1.ResourceName) (quantity resource.Quantity, ok bool) {
+	if quantity, ok = ctr.Resources.Requests[name]; ok {
+		return quantity, true
+	}
+	quantity, ok = ctr.Resources.Limits[name]
+	return quantity, ok
+}
+
+// updateResourcesDuration sums the resources duration of all the pod nodes into the managed status
+func (woc *wfOperationCtx) updateResourcesDuration() {
+	var total wfv1.ResourcesDuration
+	for _, node := range woc.wf.Status.Nodes {
+		if node.Type == wfv1.NodeTypePod {
+			total.Add(node.ResourcesDuration)
+		}
+	}
+	current := woc.wf.Status.ResourcesDuration
+	if total.IsZero() || (current != nil && *current == total) {
+		return
+	}
+	woc.wf.Status.ResourcesDuration = &total
+	woc.updated = true
+}
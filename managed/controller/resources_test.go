@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/jbrette/kubext/managed/common"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPodResourcesDuration verifies the resources duration is computed from the container requests and runtime
+func TestPodResourcesDuration(t *testing.T) {
+	now := time.Now()
+	started := metav1.NewTime(now.Add(-100 * time.Second))
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				{
+					Name: common.MainContainerName,
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("500m"),
+							apiv1.ResourceMemory: resource.MustParse("256Mi"),
+						},
+					},
+				},
+				{
+					Name: common.WaitContainerName,
+					Resources: apiv1.ResourceRequirements{
+						Limits: apiv1.ResourceList{
+							apiv1.ResourceCPU: resource.MustParse("100m"),
+						},
+					},
+				},
+			},
+		},
+		Status: apiv1.PodStatus{
+			ContainerStatuses: []apiv1.ContainerStatus{
+				{
+					Name: common.MainContainerName,
+					State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{
+						StartedAt:  started,
+						FinishedAt: metav1.NewTime(started.Add(60 * time.Second)),
+					}},
+				},
+				{
+					Name:  common.WaitContainerName,
+					State: apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{StartedAt: started}},
+				},
+			},
+		},
+	}
+	resourcesDuration := podResourcesDuration(pod, now)
+	if assert.NotNil(t, resourcesDuration) {
+		// 0.5 cpu * 60s + 0.1 cpu * 100s
+		assert.Equal(t, int64(40), resourcesDuration.CPU)
+		// 256Mi * 60s
+		assert.Equal(t, int64(15360), resourcesDuration.Memory)
+	}
+
+	pod.Status.ContainerStatuses = nil
+	assert.Nil(t, podResourcesDuration(pod, now))
+}
+
+// TestUpdateResourcesDuration verifies the resources duration of the pod nodes are summed into the managed status
+func TestUpdateResourcesDuration(t *testing.T) {
+	woc := newManagedOperationCtx(unmarshalWF(helloWorldWf), newController())
+	woc.wf.Status.Nodes = map[string]wfv1.NodeStatus{
+		"a": {Type: wfv1.NodeTypePod, ResourcesDuration: &wfv1.ResourcesDuration{CPU: 10, Memory: 100}},
+		"b": {Type: wfv1.NodeTypePod, ResourcesDuration: &wfv1.ResourcesDuration{CPU: 5}},
+		"c": {Type: wfv1.NodeTypeSteps},
+	}
+	woc.updateResourcesDuration()
+	assert.True(t, woc.updated)
+	assert.Equal(t, &wfv1.ResourcesDuration{CPU: 15, Memory: 100}, woc.wf.Status.ResourcesDuration)
+
+	woc.updated = false
+	woc.updateResourcesDuration()
+	assert.False(t, woc.updated)
+}
+
+// TestResourcesDurationOnCompletion verifies the resources duration of a managed is set once its pod completes
+func TestResourcesDurationOnCompletion(t *testing.T) {
+	controller := newController()
+	wf := unmarshalWF(helloWorldWf)
+	wf, err := controller.wfclientset.KubextprojV1alpha1().Manageds("").Create(wf)
+	assert.Nil(t, err)
+	woc := newManagedOperationCtx(wf, controller)
+	woc.operate()
+
+	podName := getPodName(woc.wf)
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(podName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	for i, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.MainContainerName {
+			pod.Spec.Containers[i].Resources.Requests = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")}
+		}
+	}
+	pod.Status.Phase = apiv1.PodSucceeded
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name: common.MainContainerName,
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{
+			StartedAt:  started,
+			FinishedAt: metav1.NewTime(started.Add(30 * time.Second)),
+		}},
+	}}
+	_, err = controller.kubeclientset.CoreV1().Pods("").Update(pod)
+	assert.Nil(t, err)
+	woc = newManagedOperationCtx(woc.wf, controller)
+	woc.operate()
+
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+	assert.Equal(t, &wfv1.ResourcesDuration{CPU: 30}, woc.wf.Status.ResourcesDuration)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// workerPool runs a resizable number of workers, each repeatedly invoking process until it
+// returns false (i.e. the work queue was shut down) or the worker is stopped
+type workerPool struct {
+	process func() bool
+	stopChs []chan struct{}
+}
+
+func newWorkerPool(process func() bool) *workerPool {
+	return &workerPool{process: process}
+}
+
+// size returns the current number of workers in the pool
+func (p *workerPool) size() int {
+	return len(p.stopChs)
+}
+
+// resize starts or stops workers until the pool has n workers. A stopped worker exits after
+// finishing the item it is currently processing or waiting on.
+func (p *workerPool) resize(n int) {
+	for len(p.stopChs) < n {
+		stopCh := make(chan struct{})
+		p.stopChs = append(p.stopChs, stopCh)
+		go wait.Until(func() { p.work(stopCh) }, time.Second, stopCh)
+	}
+	for len(p.stopChs) > n {
+		last := len(p.stopChs) - 1
+		close(p.stopChs[last])
+		p.stopChs = p.stopChs[:last]
+	}
+}
+
+func (p *workerPool) work(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		if !p.process() {
+			return
+		}
+	}
+}
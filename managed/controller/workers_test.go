@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// TestWorkerPoolResize verifies workers are started and stopped as the pool is resized
+func TestWorkerPoolResize(t *testing.T) {
+	queue := workqueue.New()
+	defer queue.ShutDown()
+	processed := make(chan interface{}, 10)
+	pool := newWorkerPool(func() bool {
+		key, quit := queue.Get()
+		if quit {
+			return false
+		}
+		defer queue.Done(key)
+		processed <- key
+		return true
+	})
+
+	pool.resize(3)
+	assert.Equal(t, 3, pool.size())
+	queue.Add("a")
+	assert.Equal(t, "a", <-processed)
+
+	pool.resize(1)
+	assert.Equal(t, 1, pool.size())
+	// stopped workers exit after the next item they receive, so every item is still processed
+	for _, key := range []string{"b", "c", "d"} {
+		queue.Add(key)
+		assert.Equal(t, key, <-processed)
+	}
+
+	pool.resize(0)
+	assert.Equal(t, 0, pool.size())
+	queue.Add("e")
+	// the last worker may pick up one more item before exiting
+	select {
+	case <-processed:
+	case <-time.After(100 * time.Millisecond):
+	}
+	queue.Add("f")
+	err := wait.Poll(10*time.Millisecond, 200*time.Millisecond, func() (bool, error) {
+		return len(processed) > 0, nil
+	})
+	assert.Error(t, err)
+}
+
+// TestUpdateConfigTuning verifies the tuning settings from the config map take precedence over the defaults
+func TestUpdateConfigTuning(t *testing.T) {
+	controller := newController()
+	controller.configUpdated = make(chan struct{}, 1)
+	controller.Defaults = ManagedControllerConfig{
+		ManagedWorkers:   4,
+		MaxOperationTime: &metav1.Duration{Duration: 30 * time.Second},
+	}
+	assert.Equal(t, 4, controller.managedWorkers())
+	assert.Equal(t, DefaultPodWorkers, controller.podWorkers())
+	assert.Equal(t, 30*time.Second, controller.maxOperationTime())
+	assert.Equal(t, DefaultPodResyncPeriod, controller.podResyncPeriod())
+
+	err := controller.updateConfig(&apiv1.ConfigMap{
+		Data: map[string]string{
+			"config": "executorImage: executor:latest\nmanagedWorkers: 16\npodWorkers: 2\nmaxOperationTime: 1m\npodResyncPeriod: 1h\n",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 16, controller.managedWorkers())
+	assert.Equal(t, 2, controller.podWorkers())
+	assert.Equal(t, time.Minute, controller.maxOperationTime())
+	assert.Equal(t, time.Hour, controller.podResyncPeriod())
+	assert.Equal(t, DefaultManagedResyncPeriod, controller.managedResyncPeriod())
+	assert.Len(t, controller.configUpdated, 1)
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/DataDog/zstd"
+	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+)
+
+// artifactFileName returns the file name of an artifact in the archive location, with the extension
+// of its archive
+func artifactFileName(art *wfv1.Artifact) string {
+	switch {
+	case art.Archive.None != nil:
+		return art.Name + filepath.Ext(art.Path)
+	case art.Archive.Zstd != nil:
+		return fmt.Sprintf("%s.tar.zst", art.Name)
+	default:
+		return fmt.Sprintf("%s.tgz", art.Name)
+	}
+}
+
+// archiveStream returns a stream of the tarball of an artifact, as archived by the strategy
+func archiveStream(tarball io.Reader, archive *wfv1.ArchiveStrategy) (io.ReadCloser, error) {
+	switch {
+	case archive.None != nil:
+		tr := tar.NewReader(tarball)
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s is not a regular file. Only a single file can be saved without archive", hdr.Name)
+		}
+		return ioutil.NopCloser(tr), nil
+	case archive.Zstd != nil:
+		return common.PipeStream(func(w io.Writer) error {
+			zw := zstd.NewWriter(w)
+			_, err := io.Copy(zw, tarball)
+			if err != nil {
+				return err
+			}
+			return zw.Close()
+		}), nil
+	default:
+		level := gzip.DefaultCompression
+		if archive.Tar != nil && archive.Tar.CompressionLevel != nil {
+			level = int(*archive.Tar.CompressionLevel)
+		}
+		return common.PipeStream(func(w io.Writer) error {
+			gzw, err := gzip.NewWriterLevel(w, level)
+			if err != nil {
+				return errors.InternalWrapError(err)
+			}
+			_, err = io.Copy(gzw, tarball)
+			if err != nil {
+				return err
+			}
+			return gzw.Close()
+		}), nil
+	}
+}
+
+// extractArtifact moves a loaded artifact to its path, extracting it as recorded by its archive.
+// Artifacts without archive, which were not saved by an executor, are extracted if they are
+// tarballs.
+func extractArtifact(loadedPath string, artPath string, archive *wfv1.ArchiveStrategy) error {
+	switch {
+	case archive == nil:
+		if isTarball(loadedPath) {
+			defer func() { _ = os.Remove(loadedPath) }()
+			return untar(loadedPath, artPath)
+		}
+		return renameArtifact(loadedPath, artPath)
+	case archive.None != nil:
+		return renameArtifact(loadedPath, artPath)
+	case archive.Zstd != nil:
+		defer func() { _ = os.Remove(loadedPath) }()
+		tarPath := loadedPath + ".tar"
+		err := decompressZstd(loadedPath, tarPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(tarPath) }()
+		return untar(tarPath, artPath)
+	default:
+		defer func() { _ = os.Remove(loadedPath) }()
+		return untar(loadedPath, artPath)
+	}
+}
+
+// decompressZstd decompresses a file compressed with zstd
+func decompressZstd(srcPath string, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = src.Close() }()
+	zr := zstd.NewReader(src)
+	defer func() { _ = zr.Close() }()
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	_, err = io.Copy(dest, zr)
+	if err != nil {
+		_ = dest.Close()
+		return errors.InternalWrapError(err)
+	}
+	err = dest.Close()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+func renameArtifact(loadedPath string, artPath string) error {
+	err := os.Rename(loadedPath, artPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTarball returns a tarball of a file, or of a directory containing the file, like the runtime
+// executors stream
+func newTarball(t *testing.T, dir bool) io.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	name := "output.txt"
+	if dir {
+		err := tw.WriteHeader(&tar.Header{Name: "output/", Typeflag: tar.TypeDir, Mode: 0755})
+		assert.NoError(t, err)
+		name = "output/output.txt"
+	}
+	err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 5})
+	assert.NoError(t, err)
+	_, err = tw.Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	return &buf
+}
+
+// saveAndLoad archives a tarball as the strategy and extracts it like an input artifact
+func saveAndLoad(t *testing.T, tarball io.Reader, archive *wfv1.ArchiveStrategy) string {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	stream, err := archiveStream(tarball, archive)
+	if !assert.NoError(t, err) {
+		return dir
+	}
+	loadedPath := filepath.Join(dir, "artifact.tmp")
+	data, err := ioutil.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Close())
+	err = ioutil.WriteFile(loadedPath, data, 0644)
+	assert.NoError(t, err)
+	err = extractArtifact(loadedPath, filepath.Join(dir, "artifact"), archive)
+	assert.NoError(t, err)
+	_, err = os.Stat(loadedPath)
+	assert.True(t, os.IsNotExist(err))
+	return dir
+}
+
+// TestArchiveTar verifies artifacts are saved and loaded as gzipped tarballs
+func TestArchiveTar(t *testing.T) {
+	level := int32(1)
+	dir := saveAndLoad(t, newTarball(t, true), &wfv1.ArchiveStrategy{Tar: &wfv1.TarStrategy{CompressionLevel: &level}})
+	defer func() { _ = os.RemoveAll(dir) }()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "artifact", "output.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+// TestArchiveNone verifies a single file is saved and loaded as is, and directories are rejected
+func TestArchiveNone(t *testing.T) {
+	archive := &wfv1.ArchiveStrategy{None: &wfv1.NoneStrategy{}}
+	dir := saveAndLoad(t, newTarball(t, false), archive)
+	defer func() { _ = os.RemoveAll(dir) }()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "artifact"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = archiveStream(newTarball(t, true), archive)
+	assert.Error(t, err)
+}
+
+// TestArchiveZstd verifies artifacts are saved and loaded as tarballs compressed with zstd
+func TestArchiveZstd(t *testing.T) {
+	dir := saveAndLoad(t, newTarball(t, true), &wfv1.ArchiveStrategy{Zstd: &wfv1.ZstdStrategy{}})
+	defer func() { _ = os.RemoveAll(dir) }()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "artifact", "output.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+// TestArtifactFileName verifies the file name of an artifact has the extension of its archive
+func TestArtifactFileName(t *testing.T) {
+	art := wfv1.Artifact{Name: "report", Path: "/tmp/report.json", Archive: &wfv1.ArchiveStrategy{Tar: &wfv1.TarStrategy{}}}
+	assert.Equal(t, "report.tgz", artifactFileName(&art))
+	art.Archive = &wfv1.ArchiveStrategy{None: &wfv1.NoneStrategy{}}
+	assert.Equal(t, "report.json", artifactFileName(&art))
+	art.Archive = &wfv1.ArchiveStrategy{Zstd: &wfv1.ZstdStrategy{}}
+	assert.Equal(t, "report.tar.zst", artifactFileName(&art))
+}
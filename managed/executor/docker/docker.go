@@ -1,7 +1,9 @@
 package docker
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
@@ -11,10 +13,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// killGracePeriod is the time in seconds after sending SIGTERM before
-// forcefully killing the sidcar with SIGKILL (value matches k8s)
-const killGracePeriod = 30
-
 type DockerExecutor struct{}
 
 func (d *DockerExecutor) GetFileContents(containerID string, sourcePath string) (string, error) {
@@ -47,6 +45,24 @@ func (d *DockerExecutor) CopyFile(containerID string, sourcePath string, destPat
 	return nil
 }
 
+// CopyFileStream returns a stream of a source file in a container, as a tarball
+func (d *DockerExecutor) CopyFileStream(containerID string, sourcePath string) (io.ReadCloser, error) {
+	log.Infof("Streaming %s:%s", containerID, sourcePath)
+	return common.PipeStream(func(w io.Writer) error {
+		cmd := exec.Command("docker", "cp", "-a", fmt.Sprintf("%s:%s", containerID, sourcePath), "-")
+		log.Info(cmd.Args)
+		var stderr bytes.Buffer
+		cmd.Stdout = w
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil {
+			log.Errorf("`%s` stderr:\n%s", cmd.Args, stderr.String())
+			return errors.InternalWrapError(err)
+		}
+		return nil
+	}), nil
+}
+
 // GetOutput returns the entirety of the container output as a string
 // Used to capturing script results as an output parameter
 func (d *DockerExecutor) GetOutput(containerID string) (string, error) {
@@ -62,7 +78,7 @@ func (d *DockerExecutor) Wait(containerID string) error {
 }
 
 // killContainers kills a list of containerIDs first with a SIGTERM then with a SIGKILL after a grace period
-func (d *DockerExecutor) Kill(containerIDs []string) error {
+func (d *DockerExecutor) Kill(containerIDs []string, terminationGracePeriod time.Duration) error {
 	killArgs := append([]string{"kill", "--signal", "TERM"}, containerIDs...)
 	err := common.RunCommand("docker", killArgs...)
 	if err != nil {
@@ -74,8 +90,8 @@ func (d *DockerExecutor) Kill(containerIDs []string) error {
 	if err := waitCmd.Start(); err != nil {
 		return errors.InternalWrapError(err)
 	}
-	timer := time.AfterFunc(killGracePeriod*time.Second, func() {
-		log.Infof("Timed out (%ds) for containers to terminate gracefully. Killing forcefully", killGracePeriod)
+	timer := time.AfterFunc(terminationGracePeriod, func() {
+		log.Infof("Timed out (%v) for containers to terminate gracefully. Killing forcefully", terminationGracePeriod)
 		_ = waitCmd.Process.Kill()
 		forceKillArgs := append([]string{"kill", "--signal", "KILL"}, containerIDs...)
 		forceKillCmd := exec.Command("docker", forceKillArgs...)
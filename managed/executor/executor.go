@@ -15,14 +15,18 @@ import (
 	"path"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	"github.com/jbrette/kubext/util/retry"
+	"github.com/jbrette/kubext/util/tracing"
 	artifact "github.com/jbrette/kubext/managed/artifacts"
 	"github.com/jbrette/kubext/managed/artifacts/artifactory"
+	"github.com/jbrette/kubext/managed/artifacts/azure"
+	"github.com/jbrette/kubext/managed/artifacts/gcs"
 	"github.com/jbrette/kubext/managed/artifacts/git"
 	"github.com/jbrette/kubext/managed/artifacts/http"
 	"github.com/jbrette/kubext/managed/artifacts/raw"
@@ -33,7 +37,9 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 // ManagedExecutor is program which runs as the init/wait container
@@ -45,14 +51,25 @@ type ManagedExecutor struct {
 	PodAnnotationsPath string
 	ExecutionControl   *common.ExecutionControl
 	RuntimeExecutor    ContainerRuntimeExecutor
+	// DynamicClientPool is used by resource templates to act on resources of any kind
+	DynamicClientPool dynamic.ClientPool
+	// RESTClient is used by resource templates for the requests not supported by the dynamic
+	// client, e.g. server-side apply
+	RESTClient rest.Interface
 
 	// memoized container ID to prevent multiple lookups
 	mainContainerID string
+	// container IDs of the main containers (i.e. the containers of a container set), once started
+	mainContainerIDs []string
 	// memoized secrets
 	memoizedSecrets map[string]string
+	// memoized owner reference to the managed, which controls the pod
+	managedOwner *metav1.OwnerReference
 	// list of errors that occurred during execution.
 	// the first of these is used as the overall message of the node
 	errors []error
+	// traceCtx carries the trace context of the controller span which created the pod
+	traceCtx context.Context
 }
 
 // ContainerRuntimeExecutor is the interface for interacting with a container runtime (e.g. docker)
@@ -63,6 +80,10 @@ type ContainerRuntimeExecutor interface {
 	// CopyFile copies a source file in a container to a local path
 	CopyFile(containerID string, sourcePath string, destPath string) error
 
+	// CopyFileStream returns a stream of a source file in a container, as an uncompressed
+	// tarball. Errors copying the file are returned by Read or Close.
+	CopyFileStream(containerID string, sourcePath string) (io.ReadCloser, error)
+
 	// GetOutput returns the entirety of the container output as a string
 	// Used to capturing script results as an output parameter
 	GetOutput(containerID string) (string, error)
@@ -71,9 +92,37 @@ type ContainerRuntimeExecutor interface {
 	Wait(containerID string) error
 
 	// Kill a list of containerIDs first with a SIGTERM then with a SIGKILL after a grace period
-	Kill(containerIDs []string) error
+	Kill(containerIDs []string, terminationGracePeriod time.Duration) error
+}
+
+// rootFSHolder is implemented by the container runtime executors which read the outputs of the
+// main containers through their root filesystem, and must hold it before the containers exit
+type rootFSHolder interface {
+	// HoldRootFS waits for the process of a container to start and holds its root filesystem
+	HoldRootFS(containerID string) error
 }
 
+// defaultSidecarReadinessTimeout is the time the main container waits for a sidecar with a readiness
+// probe to be ready, unless the sidecar sets its own readiness timeout
+const defaultSidecarReadinessTimeout = 10 * time.Minute
+
+// sidecarReadinessPollInterval is the interval at which the readiness of the sidecars is checked
+var sidecarReadinessPollInterval = 1 * time.Second
+
+// sidecarsReadyPath is the file signaling the main container that the sidecars are ready
+var sidecarsReadyPath = common.ExecutorSidecarsReadyPath
+
+// rootFSSecuredPath is the file signaling the main containers that their root filesystem is held
+var rootFSSecuredPath = common.ExecutorRootFSSecuredPath
+
+// containerExitCodePath returns the file signaling the containers of a container set that one of
+// their dependencies completed
+var containerExitCodePath = common.GetContainerExitCodePath
+
+// defaultTerminationGracePeriod is the time after sending SIGTERM to a container before it is
+// forcefully killed with SIGKILL (value matches k8s)
+const defaultTerminationGracePeriod = 30 * time.Second
+
 // NewExecutor instantiates a new managed executor
 func NewExecutor(clientset kubernetes.Interface, podName, namespace, podAnnotationsPath string, cre ContainerRuntimeExecutor) ManagedExecutor {
 	return ManagedExecutor{
@@ -84,6 +133,7 @@ func NewExecutor(clientset kubernetes.Interface, podName, namespace, podAnnotati
 		RuntimeExecutor:    cre,
 		memoizedSecrets:    map[string]string{},
 		errors:             []error{},
+		traceCtx:           context.Background(),
 	}
 }
 
@@ -101,6 +151,7 @@ func (we *ManagedExecutor) HandleError() {
 
 // LoadArtifacts loads aftifacts from location to a container path
 func (we *ManagedExecutor) LoadArtifacts() error {
+	defer we.startSpan("LoadArtifacts").End()
 	log.Infof("Start loading input artifacts...")
 
 	for _, art := range we.Template.Inputs.Artifacts {
@@ -127,20 +178,14 @@ func (we *ManagedExecutor) LoadArtifacts() error {
 			artPath = path.Join(common.InitContainerMainFilesystemDir, art.Path)
 		}
 
-		// The artifact is downloaded to a temporary location, after which we determine if
-		// the file is a tarball or not. If it is, it is first extracted then renamed to
-		// the desired location. If not, it is simply renamed to the location.
+		// The artifact is downloaded to a temporary location, after which it is extracted
+		// according to its archive, then renamed to the desired location.
 		tempArtPath := artPath + ".tmp"
 		err = artDriver.Load(&art, tempArtPath)
 		if err != nil {
 			return err
 		}
-		if isTarball(tempArtPath) {
-			err = untar(tempArtPath, artPath)
-			_ = os.Remove(tempArtPath)
-		} else {
-			err = os.Rename(tempArtPath, artPath)
-		}
+		err = extractArtifact(tempArtPath, artPath, art.Archive)
 		if err != nil {
 			return err
 		}
@@ -181,6 +226,7 @@ func (we *ManagedExecutor) StageFiles() error {
 
 // SaveArtifacts uploads artifacts to the archive location
 func (we *ManagedExecutor) SaveArtifacts() error {
+	defer we.startSpan("SaveArtifacts").End()
 	if len(we.Template.Outputs.Artifacts) == 0 {
 		log.Infof("No output artifacts")
 		return nil
@@ -191,13 +237,6 @@ func (we *ManagedExecutor) SaveArtifacts() error {
 		return err
 	}
 
-	// This directory temporarily stores the tarballs of the artifacts before uploading
-	tempOutArtDir := "/kubext/outputs/artifacts"
-	err = os.MkdirAll(tempOutArtDir, os.ModePerm)
-	if err != nil {
-		return errors.InternalWrapError(err)
-	}
-
 	for i, art := range we.Template.Outputs.Artifacts {
 		log.Infof("Saving artifact: %s", art.Name)
 		// Determine the file path of where to find the artifact
@@ -205,58 +244,144 @@ func (we *ManagedExecutor) SaveArtifacts() error {
 			return errors.InternalErrorf("Artifact %s did not specify a path", art.Name)
 		}
 
-		fileName := fmt.Sprintf("%s.tgz", art.Name)
+		if art.Archive == nil {
+			// record the archive, so that the artifact is extracted accordingly when loaded
+			art.Archive = &wfv1.ArchiveStrategy{Tar: &wfv1.TarStrategy{}}
+		}
+		fileName := artifactFileName(&art)
 		if !art.HasLocation() {
 			// If user did not explicitly set an artifact destination location in the template,
 			// use the default archive location (appended with the filename).
-			if we.Template.ArchiveLocation == nil {
-				return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. No archive location", art.Name)
-			}
-			if we.Template.ArchiveLocation.S3 != nil {
-				shallowCopy := *we.Template.ArchiveLocation.S3
-				art.S3 = &shallowCopy
-				art.S3.Key = path.Join(art.S3.Key, fileName)
-			} else if we.Template.ArchiveLocation.Artifactory != nil {
-				shallowCopy := *we.Template.ArchiveLocation.Artifactory
-				art.Artifactory = &shallowCopy
-				artifactoryURL, urlParseErr := url.Parse(art.Artifactory.URL)
-				if urlParseErr != nil {
-					return urlParseErr
-				}
-				artifactoryURL.Path = path.Join(artifactoryURL.Path, fileName)
-				art.Artifactory.URL = artifactoryURL.String()
-			} else {
-				return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. Archive location provided no information", art.Name)
+			err = we.setArchiveLocation(&art, fileName)
+			if err != nil {
+				return err
 			}
 		}
 
-		tempArtPath := path.Join(tempOutArtDir, fileName)
-		err = we.RuntimeExecutor.CopyFile(mainCtrID, art.Path, tempArtPath)
+		artDriver, err := we.InitDriver(art)
 		if err != nil {
 			return err
 		}
-		artDriver, err := we.InitDriver(art)
+		err = we.saveArtifactStream(mainCtrID, artDriver, &art)
 		if err != nil {
 			return err
 		}
-		err = artDriver.Save(tempArtPath, &art)
+		we.Template.Outputs.Artifacts[i] = art
+		log.Infof("Successfully saved artifact: %s", art.Name)
+	}
+	return nil
+}
+
+// saveArtifactStream uploads an artifact, archived as it is copied from the main container, so
+// that large artifacts do not need scratch space in the wait container
+func (we *ManagedExecutor) saveArtifactStream(mainCtrID string, artDriver artifact.ArtifactDriver, art *wfv1.Artifact) error {
+	tarball, err := we.RuntimeExecutor.CopyFileStream(mainCtrID, art.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tarball.Close() }()
+	stream, err := archiveStream(tarball, art.Archive)
+	if err != nil {
+		return err
+	}
+	err = artDriver.SaveStream(stream, art)
+	closeErr := stream.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// setArchiveLocation sets the location of an artifact to the archive location of the template,
+// appended with the file name
+func (we *ManagedExecutor) setArchiveLocation(art *wfv1.Artifact, fileName string) error {
+	if we.Template.ArchiveLocation == nil {
+		return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. No archive location", art.Name)
+	}
+	if we.Template.ArchiveLocation.S3 != nil {
+		shallowCopy := *we.Template.ArchiveLocation.S3
+		art.S3 = &shallowCopy
+		art.S3.Key = path.Join(art.S3.Key, fileName)
+	} else if we.Template.ArchiveLocation.Artifactory != nil {
+		shallowCopy := *we.Template.ArchiveLocation.Artifactory
+		art.Artifactory = &shallowCopy
+		artifactoryURL, urlParseErr := url.Parse(art.Artifactory.URL)
+		if urlParseErr != nil {
+			return urlParseErr
+		}
+		artifactoryURL.Path = path.Join(artifactoryURL.Path, fileName)
+		art.Artifactory.URL = artifactoryURL.String()
+	} else if we.Template.ArchiveLocation.GCS != nil {
+		shallowCopy := *we.Template.ArchiveLocation.GCS
+		art.GCS = &shallowCopy
+		art.GCS.Key = path.Join(art.GCS.Key, fileName)
+	} else if we.Template.ArchiveLocation.Azure != nil {
+		shallowCopy := *we.Template.ArchiveLocation.Azure
+		art.Azure = &shallowCopy
+		art.Azure.Blob = path.Join(art.Azure.Blob, fileName)
+	} else {
+		return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. Archive location provided no information", art.Name)
+	}
+	return nil
+}
+
+// SaveSidecarLogs uploads the logs of the sidecars which archive their logs to the archive
+// location, and adds them to the output artifacts
+func (we *ManagedExecutor) SaveSidecarLogs() error {
+	defer we.startSpan("SaveSidecarLogs").End()
+	for _, sidecar := range we.Template.Sidecars {
+		if sidecar.ArchiveLogs == nil || !*sidecar.ArchiveLogs {
+			continue
+		}
+		log.Infof("Saving logs of sidecar %s", sidecar.Name)
+		art := wfv1.Artifact{Name: sidecar.Name + "-logs"}
+		fileName := fmt.Sprintf("%s.log", art.Name)
+		err := we.setArchiveLocation(&art, fileName)
 		if err != nil {
 			return err
 		}
-		// remove is best effort (the container will go away anyways).
-		// we just want reduce peak space usage
-		err = os.Remove(tempArtPath)
+		tempLogsPath := path.Join(os.TempDir(), fileName)
+		err = we.saveContainerLogs(sidecar.Name, tempLogsPath)
 		if err != nil {
-			log.Warn("Failed to remove %s", tempArtPath)
+			return err
 		}
-		we.Template.Outputs.Artifacts[i] = art
-		log.Infof("Successfully saved file: %s", tempArtPath)
+		artDriver, err := we.InitDriver(art)
+		if err != nil {
+			return err
+		}
+		err = artDriver.Save(tempLogsPath, &art)
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(tempLogsPath)
+		we.Template.Outputs.Artifacts = append(we.Template.Outputs.Artifacts, art)
+		log.Infof("Successfully saved logs of sidecar %s", sidecar.Name)
+	}
+	return nil
+}
+
+// saveContainerLogs writes the logs of a container of the pod to a local path
+func (we *ManagedExecutor) saveContainerLogs(containerName string, destPath string) error {
+	stream, err := we.ClientSet.CoreV1().Pods(we.Namespace).GetLogs(we.PodName, &apiv1.PodLogOptions{Container: containerName}).Stream()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = stream.Close() }()
+	f, err := os.Create(destPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(f, stream)
+	if err != nil {
+		return errors.InternalWrapError(err)
 	}
 	return nil
 }
 
 // SaveParameters will save the content in the specified file path as output parameter value
 func (we *ManagedExecutor) SaveParameters() error {
+	defer we.startSpan("SaveParameters").End()
 	if len(we.Template.Outputs.Parameters) == 0 {
 		log.Infof("No output parameters")
 		return nil
@@ -358,6 +483,35 @@ func (we *ManagedExecutor) InitDriver(art wfv1.Artifact) (artifact.ArtifactDrive
 	if art.Raw != nil {
 		return &raw.RawArtifactDriver{}, nil
 	}
+	if art.GCS != nil {
+		gcsDriver := gcs.GCSArtifactDriver{}
+		if art.GCS.ServiceAccountKeySecret != nil {
+			serviceAccountKey, err := we.getSecrets(we.Namespace, art.GCS.ServiceAccountKeySecret.Name, art.GCS.ServiceAccountKeySecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			gcsDriver.ServiceAccountKey = serviceAccountKey
+		}
+		return &gcsDriver, nil
+	}
+	if art.Azure != nil {
+		azureDriver := azure.AzureArtifactDriver{}
+		if art.Azure.AccountKeySecret != nil {
+			accountKey, err := we.getSecrets(we.Namespace, art.Azure.AccountKeySecret.Name, art.Azure.AccountKeySecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			azureDriver.AccountKey = accountKey
+		}
+		if art.Azure.SASTokenSecret != nil {
+			sasToken, err := we.getSecrets(we.Namespace, art.Azure.SASTokenSecret.Name, art.Azure.SASTokenSecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			azureDriver.SASToken = sasToken
+		}
+		return &azureDriver, nil
+	}
 	return nil, errors.Errorf(errors.CodeBadRequest, "Unsupported artifact driver for %s", art.Name)
 }
 
@@ -420,12 +574,17 @@ func (we *ManagedExecutor) getSecrets(namespace, name, key string) (string, erro
 
 // GetMainContainerStatus returns the container status of the main container, nil if the main container does not exist
 func (we *ManagedExecutor) GetMainContainerStatus() (*apiv1.ContainerStatus, error) {
+	return we.getContainerStatus(common.MainContainerName)
+}
+
+// getContainerStatus returns the status of a container of the pod, nil if the container does not exist
+func (we *ManagedExecutor) getContainerStatus(containerName string) (*apiv1.ContainerStatus, error) {
 	pod, err := we.getPod()
 	if err != nil {
 		return nil, err
 	}
 	for _, ctrStatus := range pod.Status.ContainerStatuses {
-		if ctrStatus.Name == common.MainContainerName {
+		if ctrStatus.Name == containerName {
 			return &ctrStatus, nil
 		}
 	}
@@ -539,15 +698,20 @@ func untar(tarPath string, destPath string) error {
 	return nil
 }
 
-// containerID is a convenience function to strip the 'docker://' from k8s ContainerID string
+// containerID is a convenience function to strip the runtime prefix, e.g. 'docker://', from k8s
+// ContainerID string
 func containerID(ctrID string) string {
-	return strings.Replace(ctrID, "docker://", "", 1)
+	if i := strings.Index(ctrID, "://"); i >= 0 {
+		return ctrID[i+3:]
+	}
+	return ctrID
 }
 
 // Wait is the sidecar container logic which waits for the main container to complete.
 // Also monitors for updates in the pod annotations which may change (e.g. terminate)
 // Upon completion, kills any sidecars after it finishes.
 func (we *ManagedExecutor) Wait() (err error) {
+	defer we.startSpan("Wait").End()
 	defer func() {
 		killSidecarsErr := we.killSidecars()
 		if killSidecarsErr != nil {
@@ -558,50 +722,139 @@ func (we *ManagedExecutor) Wait() (err error) {
 			}
 		}
 	}()
-	log.Infof("Waiting on main container")
-	var mainContainerID string
-	mainContainerID, err = we.waitMainContainerStart()
+	log.Infof("Waiting on main containers")
+	err = we.waitMainContainersStart()
 	if err != nil {
 		return err
 	}
-	log.Infof("main container started with container ID: %s", mainContainerID)
+	err = we.holdRootFS()
+	if err == nil {
+		err = we.waitSidecarsReady()
+	}
+	if err != nil {
+		log.Infof("Killing main containers")
+		if killErr := we.RuntimeExecutor.Kill(we.mainContainerIDs, defaultTerminationGracePeriod); killErr != nil {
+			log.Warnf("Failed to kill main containers: %v", killErr)
+		}
+		return err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	annotationUpdatesCh := we.monitorAnnotations(ctx)
 	go we.monitorDeadline(ctx, annotationUpdatesCh)
 
-	err = we.RuntimeExecutor.Wait(mainContainerID)
-	log.Infof("Main container completed")
+	err = we.waitMainContainers()
+	log.Infof("Main containers completed")
 	return
 }
 
-// waitMainContainerStart waits for the main container to start and returns its container ID.
-func (we *ManagedExecutor) waitMainContainerStart() (string, error) {
+// waitMainContainersStart waits for the main container, or all the containers of a container set,
+// to start and records their container IDs.
+func (we *ManagedExecutor) waitMainContainersStart() error {
+	for _, name := range common.GetMainContainerNames(&we.Template) {
+		ctrID, err := we.waitContainerStart(name)
+		if err != nil {
+			return err
+		}
+		log.Infof("%s container started with container ID: %s", name, ctrID)
+		if name == common.MainContainerName {
+			we.mainContainerID = ctrID
+		}
+		we.mainContainerIDs = append(we.mainContainerIDs, ctrID)
+	}
+	return nil
+}
+
+// waitContainerStart waits for a container to start and returns its container ID.
+func (we *ManagedExecutor) waitContainerStart(containerName string) (string, error) {
 	for {
-		ctrStatus, err := we.GetMainContainerStatus()
+		ctrStatus, err := we.getContainerStatus(containerName)
 		if err != nil {
 			return "", err
 		}
 		if ctrStatus != nil {
 			log.Debug(ctrStatus)
 			if ctrStatus.ContainerID != "" {
-				we.mainContainerID = containerID(ctrStatus.ContainerID)
 				return containerID(ctrStatus.ContainerID), nil
 			} else if ctrStatus.State.Waiting == nil && ctrStatus.State.Running == nil && ctrStatus.State.Terminated == nil {
 				// status still not ready, wait
 				time.Sleep(1 * time.Second)
 			} else if ctrStatus.State.Waiting != nil {
-				// main container is still in waiting status
+				// container is still in waiting status
 				time.Sleep(1 * time.Second)
 			} else {
-				// main container in running or terminated state but missing container ID
-				return "", errors.InternalError("Main container ID cannot be found")
+				// container in running or terminated state but missing container ID
+				return "", errors.InternalErrorf("%s container ID cannot be found", containerName)
 			}
 		}
 	}
 }
 
+// waitMainContainers waits for the main containers to complete. When containers of a container
+// set depend on others, the exit code of each container is written once it completes, to signal
+// the containers depending on it.
+func (we *ManagedExecutor) waitMainContainers() error {
+	names := common.GetMainContainerNames(&we.Template)
+	signalDependents := common.HasContainerSetDependencies(&we.Template)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for i, name := range names {
+		wg.Add(1)
+		go func(name string, ctrID string) {
+			defer wg.Done()
+			err := we.RuntimeExecutor.Wait(ctrID)
+			log.Infof("%s container completed", name)
+			if signalDependents {
+				if signalErr := we.signalContainerExitCode(name); signalErr != nil && err == nil {
+					err = signalErr
+				}
+			}
+			errs <- err
+		}(name, we.mainContainerIDs[i])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signalContainerExitCode writes the exit code of a completed container, as reported in the pod
+// status, for the containers depending on it. A container whose exit code cannot be determined
+// is reported as failed, so that its dependents do not wait forever.
+func (we *ManagedExecutor) signalContainerExitCode(containerName string) error {
+	exitCode := int32(1)
+	err := wait.PollImmediate(1*time.Second, 1*time.Minute, func() (bool, error) {
+		ctrStatus, err := we.getContainerStatus(containerName)
+		if err != nil {
+			return false, err
+		}
+		if ctrStatus == nil || ctrStatus.State.Terminated == nil {
+			return false, nil
+		}
+		exitCode = ctrStatus.State.Terminated.ExitCode
+		return true, nil
+	})
+	if err != nil {
+		log.Warnf("Failed to determine the exit code of %s container: %v", containerName, err)
+	}
+	exitCodePath := containerExitCodePath(containerName)
+	// write then rename, so the dependents never read a partially written file
+	err = ioutil.WriteFile(exitCodePath+".tmp", []byte(fmt.Sprintf("%d", exitCode)), 0644)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	err = os.Rename(exitCodePath+".tmp", exitCodePath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
 // monitorAnnotations starts a goroutine which monitors for any changes to the pod annotations.
 // Emits an event on the returned channel upon any updates
 func (we *ManagedExecutor) monitorAnnotations(ctx context.Context) <-chan struct{} {
@@ -699,11 +952,10 @@ func (we *ManagedExecutor) monitorDeadline(ctx context.Context, annotationsUpdat
 					} else {
 						log.Info("step has been cancelled")
 					}
-					log.Infof("Killing main container")
-					mainContainerID, _ := we.GetMainContainerID()
-					err := we.RuntimeExecutor.Kill([]string{mainContainerID})
+					log.Infof("Killing main containers")
+					err := we.RuntimeExecutor.Kill(we.mainContainerIDs, defaultTerminationGracePeriod)
 					if err != nil {
-						log.Warnf("Failed to kill main container: %v", err)
+						log.Warnf("Failed to kill main containers: %v", err)
 					}
 					return
 				}
@@ -713,7 +965,92 @@ func (we *ManagedExecutor) monitorDeadline(ctx context.Context, annotationsUpdat
 	}
 }
 
-// killSidecars kills any sidecars to the main container
+// holdRootFS holds the root filesystem of the main containers when the container runtime executor
+// reads the outputs through it, then signals the main containers that they may run their command
+func (we *ManagedExecutor) holdRootFS() error {
+	holder, ok := we.RuntimeExecutor.(rootFSHolder)
+	if !ok {
+		return nil
+	}
+	for _, ctrID := range we.mainContainerIDs {
+		err := holder.HoldRootFS(ctrID)
+		if err != nil {
+			return err
+		}
+	}
+	if !common.HasRootFSGate(&we.Template) {
+		return nil
+	}
+	log.Infof("Root filesystems are held. Signaling main containers")
+	err := ioutil.WriteFile(rootFSSecuredPath, nil, 0644)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// waitSidecarsReady waits for all sidecars with a readiness probe to be ready, then signals the
+// main container that it may run its command. Returns an error if such a sidecar terminates
+// before becoming ready, or is not ready within its readiness timeout.
+func (we *ManagedExecutor) waitSidecarsReady() error {
+	if !common.HasSidecarReadinessGate(&we.Template) {
+		return nil
+	}
+	timeouts := make(map[string]time.Duration)
+	var maxTimeout time.Duration
+	for _, sidecar := range we.Template.Sidecars {
+		if sidecar.ReadinessProbe == nil {
+			continue
+		}
+		timeout := defaultSidecarReadinessTimeout
+		if sidecar.ReadinessTimeoutSeconds != nil {
+			timeout = time.Duration(*sidecar.ReadinessTimeoutSeconds) * time.Second
+		}
+		timeouts[sidecar.Name] = timeout
+		if timeout > maxTimeout {
+			maxTimeout = timeout
+		}
+	}
+	log.Infof("Waiting for %d sidecars to be ready", len(timeouts))
+	start := time.Now()
+	err := wait.PollImmediate(sidecarReadinessPollInterval, maxTimeout, func() (bool, error) {
+		pod, err := we.getPod()
+		if err != nil {
+			return false, err
+		}
+		ready := 0
+		for _, ctrStatus := range pod.Status.ContainerStatuses {
+			timeout, gated := timeouts[ctrStatus.Name]
+			if !gated {
+				continue
+			}
+			if ctrStatus.State.Terminated != nil {
+				return false, errors.Errorf(errors.CodeBadRequest, "sidecar '%s' terminated before becoming ready", ctrStatus.Name)
+			}
+			if ctrStatus.Ready {
+				ready++
+			} else if time.Since(start) >= timeout {
+				return false, errors.Errorf(errors.CodeTimeout, "sidecar '%s' was not ready within %v", ctrStatus.Name, timeout)
+			}
+		}
+		return ready == len(timeouts), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf(errors.CodeTimeout, "sidecars were not ready within %v", maxTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Sidecars are ready. Signaling main container")
+	err = ioutil.WriteFile(sidecarsReadyPath, nil, 0644)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// killSidecars kills any sidecars to the main container. Sidecars are sent SIGTERM, then
+// killed with SIGKILL after their termination grace period.
 func (we *ManagedExecutor) killSidecars() error {
 	if len(we.Template.Sidecars) == 0 {
 		log.Infof("No sidecars")
@@ -724,22 +1061,51 @@ func (we *ManagedExecutor) killSidecars() error {
 	if err != nil {
 		return err
 	}
-	sidecarIDs := make([]string, 0)
+	gracePeriods := make(map[string]time.Duration)
+	for _, sidecar := range we.Template.Sidecars {
+		gracePeriods[sidecar.Name] = defaultTerminationGracePeriod
+		if sidecar.TerminationGracePeriodSeconds != nil {
+			gracePeriods[sidecar.Name] = time.Duration(*sidecar.TerminationGracePeriodSeconds) * time.Second
+		}
+	}
+	isMainCtr := make(map[string]bool)
+	for _, name := range common.GetMainContainerNames(&we.Template) {
+		isMainCtr[name] = true
+	}
+	// sidecars sharing the same grace period are killed together
+	sidecarIDs := make(map[time.Duration][]string)
 	for _, ctrStatus := range pod.Status.ContainerStatuses {
-		if ctrStatus.Name == common.MainContainerName || ctrStatus.Name == common.WaitContainerName {
+		if isMainCtr[ctrStatus.Name] || ctrStatus.Name == common.WaitContainerName {
 			continue
 		}
 		if ctrStatus.State.Terminated != nil {
 			continue
 		}
+		gracePeriod, ok := gracePeriods[ctrStatus.Name]
+		if !ok {
+			gracePeriod = defaultTerminationGracePeriod
+		}
 		containerID := containerID(ctrStatus.ContainerID)
-		log.Infof("Killing sidecar %s (%s)", ctrStatus.Name, containerID)
-		sidecarIDs = append(sidecarIDs, containerID)
+		log.Infof("Killing sidecar %s (%s) with grace period %v", ctrStatus.Name, containerID, gracePeriod)
+		sidecarIDs[gracePeriod] = append(sidecarIDs[gracePeriod], containerID)
 	}
-	if len(sidecarIDs) == 0 {
-		return nil
+	var wg sync.WaitGroup
+	errs := make(chan error, len(sidecarIDs))
+	for gracePeriod, containerIDs := range sidecarIDs {
+		wg.Add(1)
+		go func(containerIDs []string, gracePeriod time.Duration) {
+			defer wg.Done()
+			errs <- we.RuntimeExecutor.Kill(containerIDs, gracePeriod)
+		}(containerIDs, gracePeriod)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
 	}
-	return we.RuntimeExecutor.Kill(sidecarIDs)
+	return nil
 }
 
 // LoadTemplate reads the template definition from the the Kubernetes downward api annotations volume file
@@ -748,9 +1114,36 @@ func (we *ManagedExecutor) LoadTemplate() error {
 	if err != nil {
 		return err
 	}
+	return we.loadTraceContext()
+}
+
+// loadTraceContext reads the trace context, if any, from the Kubernetes downward api annotations volume file
+func (we *ManagedExecutor) loadTraceContext() error {
+	var carrier map[string]string
+	err := unmarshalAnnotationField(we.PodAnnotationsPath, common.AnnotationKeyTraceContext, &carrier)
+	if err != nil {
+		if errors.IsCode(errors.CodeNotFound, err) {
+			return nil
+		}
+		return err
+	}
+	we.traceCtx = tracing.Extract(context.Background(), carrier)
 	return nil
 }
 
+// startSpan starts a span of an executor phase as a child of the controller span which created the pod
+func (we *ManagedExecutor) startSpan(name string) *tracing.Span {
+	ctx := we.traceCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Start(ctx, name,
+		tracing.String("pod", we.PodName),
+		tracing.String("template", we.Template.Name),
+	)
+	return span
+}
+
 // LoadExecutionControl reads the execution control definition from the the Kubernetes downward api annotations volume file
 func (we *ManagedExecutor) LoadExecutionControl() error {
 	err := unmarshalAnnotationField(we.PodAnnotationsPath, common.AnnotationKeyExecutionControl, &we.ExecutionControl)
@@ -1,11 +1,25 @@
 package executor
 
 import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jbrette/kubext/errors"
 	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/jbrette/kubext/managed/common"
 	"github.com/jbrette/kubext/managed/executor/mocks"
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -46,3 +60,237 @@ func TestSaveParameters(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, *we.Template.Outputs.Parameters[0].Value, "has a newline")
 }
+
+func newFakePod(ctrStatuses ...apiv1.ContainerStatus) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: fakePodName, Namespace: fakeNamespace},
+		Status:     apiv1.PodStatus{ContainerStatuses: ctrStatuses},
+	}
+}
+
+// TestWaitSidecarsReady verifies the main container is signaled once the gated sidecars are ready
+func TestWaitSidecarsReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signals")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	sidecarsReadyPath = filepath.Join(dir, "sidecars-ready")
+	defer func() { sidecarsReadyPath = common.ExecutorSidecarsReadyPath }()
+
+	pod := newFakePod(
+		apiv1.ContainerStatus{Name: "db", Ready: true},
+		apiv1.ContainerStatus{Name: "proxy", Ready: false},
+	)
+	we := ManagedExecutor{
+		PodName:   fakePodName,
+		Namespace: fakeNamespace,
+		ClientSet: fake.NewSimpleClientset(pod),
+		Template: wfv1.Template{
+			Sidecars: []wfv1.Sidecar{
+				{Container: apiv1.Container{Name: "db", ReadinessProbe: &apiv1.Probe{}}},
+				{Container: apiv1.Container{Name: "proxy"}},
+			},
+		},
+	}
+	err = we.waitSidecarsReady()
+	assert.NoError(t, err)
+	_, err = os.Stat(sidecarsReadyPath)
+	assert.NoError(t, err)
+}
+
+// TestWaitSidecarsReadyTerminated verifies an error is returned when a gated sidecar terminates before being ready
+func TestWaitSidecarsReadyTerminated(t *testing.T) {
+	pod := newFakePod(apiv1.ContainerStatus{
+		Name:  "db",
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 1}},
+	})
+	we := ManagedExecutor{
+		PodName:   fakePodName,
+		Namespace: fakeNamespace,
+		ClientSet: fake.NewSimpleClientset(pod),
+		Template: wfv1.Template{
+			Sidecars: []wfv1.Sidecar{
+				{Container: apiv1.Container{Name: "db", ReadinessProbe: &apiv1.Probe{}}},
+			},
+		},
+	}
+	err := we.waitSidecarsReady()
+	assert.Error(t, err)
+}
+
+// TestWaitSidecarsReadyTimeout verifies an error is returned when a gated sidecar is not ready within its readiness timeout
+func TestWaitSidecarsReadyTimeout(t *testing.T) {
+	sidecarReadinessPollInterval = 100 * time.Millisecond
+	defer func() { sidecarReadinessPollInterval = 1 * time.Second }()
+
+	pod := newFakePod(apiv1.ContainerStatus{Name: "db", Ready: false})
+	timeout := int64(1)
+	we := ManagedExecutor{
+		PodName:   fakePodName,
+		Namespace: fakeNamespace,
+		ClientSet: fake.NewSimpleClientset(pod),
+		Template: wfv1.Template{
+			Sidecars: []wfv1.Sidecar{
+				{Container: apiv1.Container{Name: "db", ReadinessProbe: &apiv1.Probe{}}, ReadinessTimeoutSeconds: &timeout},
+			},
+		},
+	}
+	err := we.waitSidecarsReady()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not ready within 1s")
+	}
+}
+
+// fakeRootFSHolder is a container runtime executor recording the containers whose root filesystem it holds
+type fakeRootFSHolder struct {
+	mocks.ContainerRuntimeExecutor
+	held []string
+}
+
+func (f *fakeRootFSHolder) HoldRootFS(containerID string) error {
+	f.held = append(f.held, containerID)
+	return nil
+}
+
+// TestHoldRootFS verifies the main containers are signaled once their root filesystem is held
+func TestHoldRootFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signals")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	rootFSSecuredPath = filepath.Join(dir, "rootfs-secured")
+	defer func() { rootFSSecuredPath = common.ExecutorRootFSSecuredPath }()
+
+	holder := &fakeRootFSHolder{}
+	we := ManagedExecutor{
+		RuntimeExecutor:  holder,
+		Template:         wfv1.Template{Container: &apiv1.Container{Command: []string{"sh"}}},
+		mainContainerIDs: []string{fakeContainerID},
+	}
+	err = we.holdRootFS()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{fakeContainerID}, holder.held)
+	_, err = os.Stat(rootFSSecuredPath)
+	assert.NoError(t, err)
+}
+
+// TestKillSidecars verifies sidecars are killed with their termination grace period
+func TestKillSidecars(t *testing.T) {
+	pod := newFakePod(
+		apiv1.ContainerStatus{Name: common.MainContainerName, ContainerID: "docker://main"},
+		apiv1.ContainerStatus{Name: common.WaitContainerName, ContainerID: "docker://wait"},
+		apiv1.ContainerStatus{Name: "db", ContainerID: "docker://db"},
+		apiv1.ContainerStatus{Name: "proxy", ContainerID: "docker://proxy"},
+		apiv1.ContainerStatus{
+			Name:        "done",
+			ContainerID: "docker://done",
+			State:       apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{}},
+		},
+	)
+	mockRuntimeExecutor := mocks.ContainerRuntimeExecutor{}
+	gracePeriod := int64(5)
+	we := ManagedExecutor{
+		PodName:         fakePodName,
+		Namespace:       fakeNamespace,
+		ClientSet:       fake.NewSimpleClientset(pod),
+		RuntimeExecutor: &mockRuntimeExecutor,
+		Template: wfv1.Template{
+			Sidecars: []wfv1.Sidecar{
+				{Container: apiv1.Container{Name: "db"}, TerminationGracePeriodSeconds: &gracePeriod},
+				{Container: apiv1.Container{Name: "proxy"}},
+				{Container: apiv1.Container{Name: "done"}},
+			},
+		},
+	}
+	mockRuntimeExecutor.On("Kill", []string{"db"}, 5*time.Second).Return(nil)
+	mockRuntimeExecutor.On("Kill", []string{"proxy"}, defaultTerminationGracePeriod).Return(nil)
+	err := we.killSidecars()
+	assert.NoError(t, err)
+	mockRuntimeExecutor.AssertExpectations(t)
+	mockRuntimeExecutor.AssertNumberOfCalls(t, "Kill", 2)
+}
+
+// TestSignalContainerExitCode verifies the exit code of a completed container is written for its dependents
+func TestSignalContainerExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signals")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	containerExitCodePath = func(containerName string) string {
+		return filepath.Join(dir, containerName+".exitcode")
+	}
+	defer func() { containerExitCodePath = common.GetContainerExitCodePath }()
+
+	pod := newFakePod(apiv1.ContainerStatus{
+		Name:  "setup",
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 3}},
+	})
+	we := ManagedExecutor{
+		PodName:   fakePodName,
+		Namespace: fakeNamespace,
+		ClientSet: fake.NewSimpleClientset(pod),
+	}
+	err = we.signalContainerExitCode("setup")
+	assert.NoError(t, err)
+	exitCode, err := ioutil.ReadFile(filepath.Join(dir, "setup.exitcode"))
+	assert.NoError(t, err)
+	assert.Equal(t, "3", string(exitCode))
+}
+
+// TestSaveArtifactsStream verifies artifacts are uploaded as they are streamed from the main container
+func TestSaveArtifactsStream(t *testing.T) {
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/repo/output.tgz", r.URL.Path)
+		uploaded, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "artifactory-creds"},
+		Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("password")},
+	}
+	mockRuntimeExecutor := mocks.ContainerRuntimeExecutor{}
+	we := ManagedExecutor{
+		PodName:   fakePodName,
+		Namespace: fakeNamespace,
+		ClientSet: fake.NewSimpleClientset(secret),
+		Template: wfv1.Template{
+			Outputs: wfv1.Outputs{
+				Artifacts: []wfv1.Artifact{{
+					Name: "output",
+					Path: "/tmp/output",
+					ArtifactLocation: wfv1.ArtifactLocation{
+						Artifactory: &wfv1.ArtifactoryArtifact{
+							URL: server.URL + "/repo/output.tgz",
+							ArtifactoryAuth: wfv1.ArtifactoryAuth{
+								UsernameSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "artifactory-creds"}, Key: "username"},
+								PasswordSecret: &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "artifactory-creds"}, Key: "password"},
+							},
+						},
+					},
+				}},
+			},
+		},
+		RuntimeExecutor: &mockRuntimeExecutor,
+		mainContainerID: fakeContainerID,
+		memoizedSecrets: map[string]string{},
+	}
+	mockRuntimeExecutor.On("CopyFileStream", fakeContainerID, "/tmp/output").Return(ioutil.NopCloser(strings.NewReader("tarball")), nil).Once()
+	err := we.SaveArtifacts()
+	assert.NoError(t, err)
+	gzr, err := gzip.NewReader(bytes.NewReader(uploaded))
+	if assert.NoError(t, err) {
+		data, err := ioutil.ReadAll(gzr)
+		assert.NoError(t, err)
+		assert.Equal(t, "tarball", string(data))
+	}
+	// the default archive is recorded
+	assert.NotNil(t, we.Template.Outputs.Artifacts[0].Archive.Tar)
+
+	// a failure copying the artifact fails the upload
+	stream := common.PipeStream(func(w io.Writer) error {
+		return errors.InternalError("tar failed")
+	})
+	mockRuntimeExecutor.On("CopyFileStream", fakeContainerID, "/tmp/output").Return(stream, nil).Once()
+	err = we.SaveArtifacts()
+	assert.Error(t, err)
+}
@@ -0,0 +1,1009 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jbrette/kubext/errors"
+)
+
+// The jq filters of output parameters are evaluated by a small interpreter of the subset of the jq
+// language which is useful to extract values from a resource:
+//
+//   paths:        . .foo ."foo" .[0] .[-1] .["foo"] .[] .[1:3] and the optional suffix ?
+//   operators:    | , // and or == != < <= > >=
+//   constructors: literals, (f), [f] and {key: f, "key": f, (f): f, key}
+//   functions:    length keys has(f) select(f) map(f) not empty type tostring tonumber add
+//
+// Object values are iterated in the order of their sorted keys.
+
+// jqFilter is a compiled jq filter, which produces zero or more outputs for an input
+type jqFilter func(input interface{}) ([]interface{}, error)
+
+// evaluateJQFilter evaluates a jq filter against an object. Like jq -c, each result is
+// written as compact JSON on its own line.
+func evaluateJQFilter(obj map[string]interface{}, filter string) (string, error) {
+	query, err := parseJQ(filter)
+	if err != nil {
+		return "", errors.Errorf(errors.CodeBadRequest, "jqFilter '%s' failed to parse: %v", filter, err)
+	}
+	// normalize the object to the JSON types which the filter operates on, e.g. float64 numbers
+	jsonBytes, err := json.Marshal(obj)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	var input interface{}
+	err = json.Unmarshal(jsonBytes, &input)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	outputs, err := query(input)
+	if err != nil {
+		return "", errors.Errorf(errors.CodeBadRequest, "jqFilter '%s' failed: %v", filter, err)
+	}
+	results := make([]string, len(outputs))
+	for i, v := range outputs {
+		result, err := json.Marshal(v)
+		if err != nil {
+			return "", errors.InternalWrapError(err)
+		}
+		results[i] = string(result)
+	}
+	return strings.Join(results, "\n"), nil
+}
+
+type jqTokenKind int
+
+const (
+	jqEOF jqTokenKind = iota
+	jqIdent
+	jqString
+	jqNumber
+	jqPunct
+)
+
+type jqToken struct {
+	kind jqTokenKind
+	text string
+	// value is the decoded value of string and number tokens
+	value interface{}
+}
+
+// jqPuncts are the punctuation tokens, the two character ones first
+var jqPuncts = []string{"//", "==", "!=", "<=", ">=", ".", "[", "]", "(", ")", "{", "}", "|", ",", ":", "?", "<", ">"}
+
+func tokenizeJQ(filter string) ([]jqToken, error) {
+	var tokens []jqToken
+	for i := 0; i < len(filter); {
+		c := rune(filter[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(filter) && (filter[j] == '_' || unicode.IsLetter(rune(filter[j])) || unicode.IsDigit(rune(filter[j]))) {
+				j++
+			}
+			tokens = append(tokens, jqToken{kind: jqIdent, text: filter[i:j]})
+			i = j
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(filter) && unicode.IsDigit(rune(filter[i+1]))):
+			j := i + 1
+			for j < len(filter) && (unicode.IsDigit(rune(filter[j])) || filter[j] == '.' || filter[j] == 'e' || filter[j] == 'E') {
+				j++
+			}
+			number, err := strconv.ParseFloat(filter[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s'", filter[i:j])
+			}
+			tokens = append(tokens, jqToken{kind: jqNumber, text: filter[i:j], value: number})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(filter) && filter[j] != '"' {
+				if filter[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(filter) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var str string
+			err := json.Unmarshal([]byte(filter[i:j+1]), &str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", filter[i:j+1])
+			}
+			tokens = append(tokens, jqToken{kind: jqString, text: filter[i : j+1], value: str})
+			i = j + 1
+		default:
+			matched := false
+			for _, punct := range jqPuncts {
+				if strings.HasPrefix(filter[i:], punct) {
+					tokens = append(tokens, jqToken{kind: jqPunct, text: punct})
+					i += len(punct)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unsupported character '%c'", c)
+			}
+		}
+	}
+	return append(tokens, jqToken{kind: jqEOF}), nil
+}
+
+type jqParser struct {
+	tokens []jqToken
+	pos    int
+}
+
+func parseJQ(filter string) (jqFilter, error) {
+	tokens, err := tokenizeJQ(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &jqParser{tokens: tokens}
+	f, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != jqEOF {
+		return nil, fmt.Errorf("unexpected '%s'", p.peek().text)
+	}
+	return f, nil
+}
+
+func (p *jqParser) peek() jqToken {
+	return p.tokens[p.pos]
+}
+
+func (p *jqParser) next() jqToken {
+	t := p.tokens[p.pos]
+	if t.kind != jqEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given punctuation or keyword
+func (p *jqParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == jqPunct || t.kind == jqIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) expect(text string) error {
+	if !p.accept(text) {
+		if p.peek().kind == jqEOF {
+			return fmt.Errorf("expected '%s' at end of filter", text)
+		}
+		return fmt.Errorf("expected '%s' but found '%s'", text, p.peek().text)
+	}
+	return nil
+}
+
+// parsePipe parses the lowest precedence operator: f | g
+func (p *jqParser) parsePipe() (jqFilter, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = jqPipe(left, right)
+	}
+	return left, nil
+}
+
+// parseComma parses f, g
+func (p *jqParser) parseComma() (jqFilter, error) {
+	left, err := p.parseAlternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		left = jqComma(left, right)
+	}
+	return left, nil
+}
+
+// parseAlternative parses f // g
+func (p *jqParser) parseAlternative() (jqFilter, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("//") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = jqAlternative(left, right)
+	}
+	return left, nil
+}
+
+// parseOr parses f or g
+func (p *jqParser) parseOr() (jqFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary(left, right, func(l, r interface{}) (interface{}, error) {
+			return jqTruthy(l) || jqTruthy(r), nil
+		})
+	}
+	return left, nil
+}
+
+// parseAnd parses f and g
+func (p *jqParser) parseAnd() (jqFilter, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary(left, right, func(l, r interface{}) (interface{}, error) {
+			return jqTruthy(l) && jqTruthy(r), nil
+		})
+	}
+	return left, nil
+}
+
+// parseComparison parses the non-associative comparison operators
+func (p *jqParser) parseComparison() (jqFilter, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != jqPunct {
+		return left, nil
+	}
+	var test func(int) bool
+	switch t.text {
+	case "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	return jqBinary(left, right, func(l, r interface{}) (interface{}, error) {
+		return test(jqCompare(l, r)), nil
+	}), nil
+}
+
+// parsePostfix parses a term followed by any number of path suffixes
+func (p *jqParser) parsePostfix() (jqFilter, error) {
+	f, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek().kind == jqPunct && p.peek().text == "." && p.pos+1 < len(p.tokens) &&
+			(p.tokens[p.pos+1].kind == jqIdent || p.tokens[p.pos+1].kind == jqString || p.tokens[p.pos+1].text == "["):
+			p.next()
+			suffix, err := p.parseSuffix()
+			if err != nil {
+				return nil, err
+			}
+			f = jqPipe(f, suffix)
+		case p.peek().kind == jqPunct && p.peek().text == "[":
+			suffix, err := p.parseSuffix()
+			if err != nil {
+				return nil, err
+			}
+			f = jqPipe(f, suffix)
+		case p.accept("?"):
+			f = jqTry(f)
+		default:
+			return f, nil
+		}
+	}
+}
+
+// parseSuffix parses the path following a '.': foo, "foo" or [...]
+func (p *jqParser) parseSuffix() (jqFilter, error) {
+	t := p.next()
+	switch {
+	case t.kind == jqIdent:
+		return jqIndex(jqLiteral(t.text)), nil
+	case t.kind == jqString:
+		return jqIndex(jqLiteral(t.value)), nil
+	case t.kind == jqPunct && t.text == "[":
+		if p.accept("]") {
+			return jqIterate, nil
+		}
+		var from, to jqFilter
+		var err error
+		if p.peek().text != ":" {
+			from, err = p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if p.accept(":") {
+			if p.peek().text != "]" {
+				to, err = p.parsePipe()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return jqSlice(from, to), nil
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return jqIndex(from), nil
+	}
+	return nil, fmt.Errorf("unexpected '%s' after '.'", t.text)
+}
+
+func (p *jqParser) parseTerm() (jqFilter, error) {
+	t := p.next()
+	switch t.kind {
+	case jqEOF:
+		return nil, fmt.Errorf("unexpected end of filter")
+	case jqString, jqNumber:
+		return jqLiteral(t.value), nil
+	case jqIdent:
+		return p.parseFunction(t.text)
+	}
+	switch t.text {
+	case ".":
+		next := p.peek()
+		if next.kind == jqIdent || next.kind == jqString || (next.kind == jqPunct && next.text == "[") {
+			return p.parseSuffix()
+		}
+		return jqIdentity, nil
+	case "(":
+		f, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	case "[":
+		if p.accept("]") {
+			return jqLiteral([]interface{}{}), nil
+		}
+		f, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return jqCollect(f), p.expect("]")
+	case "{":
+		return p.parseObject()
+	}
+	return nil, fmt.Errorf("unexpected '%s'", t.text)
+}
+
+// parseObject parses the entries of an object construction after its '{'
+func (p *jqParser) parseObject() (jqFilter, error) {
+	type entry struct {
+		key   jqFilter
+		value jqFilter
+	}
+	var entries []entry
+	for !p.accept("}") {
+		if len(entries) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		var e entry
+		t := p.next()
+		switch {
+		case t.kind == jqIdent:
+			e.key = jqLiteral(t.text)
+		case t.kind == jqString:
+			e.key = jqLiteral(t.value)
+		case t.kind == jqPunct && t.text == "(":
+			key, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			e.key = key
+		default:
+			return nil, fmt.Errorf("unexpected '%s' in object construction", t.text)
+		}
+		if p.accept(":") {
+			value, err := p.parseAlternative()
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		} else if t.kind == jqIdent {
+			e.value = jqIndex(jqLiteral(t.text))
+		} else {
+			return nil, fmt.Errorf("expected ':' after object key")
+		}
+		entries = append(entries, e)
+	}
+	return func(input interface{}) ([]interface{}, error) {
+		objects := []interface{}{map[string]interface{}{}}
+		for _, e := range entries {
+			keys, err := e.key(input)
+			if err != nil {
+				return nil, err
+			}
+			values, err := e.value(input)
+			if err != nil {
+				return nil, err
+			}
+			var product []interface{}
+			for _, obj := range objects {
+				for _, key := range keys {
+					k, ok := key.(string)
+					if !ok {
+						return nil, fmt.Errorf("object keys must be strings, not %s", jqType(key))
+					}
+					for _, value := range values {
+						copied := map[string]interface{}{}
+						for ck, cv := range obj.(map[string]interface{}) {
+							copied[ck] = cv
+						}
+						copied[k] = value
+						product = append(product, copied)
+					}
+				}
+			}
+			objects = product
+		}
+		return objects, nil
+	}, nil
+}
+
+// parseFunction parses the keywords and the call of a builtin function
+func (p *jqParser) parseFunction(name string) (jqFilter, error) {
+	switch name {
+	case "true":
+		return jqLiteral(true), nil
+	case "false":
+		return jqLiteral(false), nil
+	case "null":
+		return jqLiteral(nil), nil
+	case "empty":
+		return func(interface{}) ([]interface{}, error) { return nil, nil }, nil
+	case "not":
+		return jqMap(func(v interface{}) (interface{}, error) { return !jqTruthy(v), nil }), nil
+	case "type":
+		return jqMap(func(v interface{}) (interface{}, error) { return jqType(v), nil }), nil
+	case "length":
+		return jqMap(jqLength), nil
+	case "keys":
+		return jqMap(jqKeys), nil
+	case "add":
+		return jqMap(jqAdd), nil
+	case "tostring":
+		return jqMap(func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			b, err := json.Marshal(v)
+			return string(b), err
+		}), nil
+	case "tonumber":
+		return jqMap(func(v interface{}) (interface{}, error) {
+			switch val := v.(type) {
+			case float64:
+				return val, nil
+			case string:
+				n, err := strconv.ParseFloat(val, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse '%s' as a number", val)
+				}
+				return n, nil
+			}
+			return nil, fmt.Errorf("%s cannot be parsed as a number", jqType(v))
+		}), nil
+	case "select", "map", "has":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		switch name {
+		case "select":
+			return jqSelect(arg), nil
+		case "map":
+			return jqCollect(jqPipe(jqIterate, arg)), nil
+		default:
+			return jqHas(arg), nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a supported function", name)
+}
+
+func jqIdentity(input interface{}) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+func jqLiteral(value interface{}) jqFilter {
+	return func(interface{}) ([]interface{}, error) {
+		return []interface{}{value}, nil
+	}
+}
+
+// jqMap returns a filter producing one output per input
+func jqMap(fn func(interface{}) (interface{}, error)) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		output, err := fn(input)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{output}, nil
+	}
+}
+
+func jqPipe(left, right jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		values, err := left(input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []interface{}
+		for _, v := range values {
+			results, err := right(v)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, results...)
+		}
+		return outputs, nil
+	}
+}
+
+func jqComma(left, right jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		l, err := left(input)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(input)
+		if err != nil {
+			return nil, err
+		}
+		return append(l, r...), nil
+	}
+}
+
+// jqAlternative produces the truthy outputs of left or, if there are none, the outputs of right
+func jqAlternative(left, right jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		values, _ := left(input)
+		var outputs []interface{}
+		for _, v := range values {
+			if jqTruthy(v) {
+				outputs = append(outputs, v)
+			}
+		}
+		if len(outputs) > 0 {
+			return outputs, nil
+		}
+		return right(input)
+	}
+}
+
+// jqBinary applies an operator to the cartesian product of the outputs of both operands
+func jqBinary(left, right jqFilter, op func(l, r interface{}) (interface{}, error)) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		rights, err := right(input)
+		if err != nil {
+			return nil, err
+		}
+		lefts, err := left(input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []interface{}
+		for _, r := range rights {
+			for _, l := range lefts {
+				v, err := op(l, r)
+				if err != nil {
+					return nil, err
+				}
+				outputs = append(outputs, v)
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// jqTry suppresses the errors of f
+func jqTry(f jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		outputs, err := f(input)
+		if err != nil {
+			return nil, nil
+		}
+		return outputs, nil
+	}
+}
+
+func jqCollect(f jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		outputs, err := f(input)
+		if err != nil {
+			return nil, err
+		}
+		if outputs == nil {
+			outputs = []interface{}{}
+		}
+		return []interface{}{outputs}, nil
+	}
+}
+
+func jqSelect(cond jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		results, err := cond(input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []interface{}
+		for _, r := range results {
+			if jqTruthy(r) {
+				outputs = append(outputs, input)
+			}
+		}
+		return outputs, nil
+	}
+}
+
+func jqHas(key jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		keys, err := key(input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []interface{}
+		for _, k := range keys {
+			switch val := input.(type) {
+			case map[string]interface{}:
+				s, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether object has a key of type %s", jqType(k))
+				}
+				_, found := val[s]
+				outputs = append(outputs, found)
+			case []interface{}:
+				n, ok := k.(float64)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether array has a key of type %s", jqType(k))
+				}
+				outputs = append(outputs, n >= 0 && int(n) < len(val))
+			default:
+				return nil, fmt.Errorf("cannot check whether %s has a key", jqType(input))
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// jqIndex implements .[f], .foo and ."foo"
+func jqIndex(index jqFilter) jqFilter {
+	return func(input interface{}) ([]interface{}, error) {
+		indexes, err := index(input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []interface{}
+		for _, idx := range indexes {
+			v, err := jqIndexValue(input, idx)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, v)
+		}
+		return outputs, nil
+	}
+}
+
+func jqIndexValue(input, idx interface{}) (interface{}, error) {
+	switch val := input.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		if key, ok := idx.(string); ok {
+			return val[key], nil
+		}
+	case []interface{}:
+		if n, ok := idx.(float64); ok {
+			i := int(n)
+			if i < 0 {
+				i += len(val)
+			}
+			if i < 0 || i >= len(val) {
+				return nil, nil
+			}
+			return val[i], nil
+		}
+	}
+	if key, ok := idx.(string); ok {
+		return nil, fmt.Errorf("cannot index %s with \"%s\"", jqType(input), key)
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", jqType(input), jqType(idx))
+}
+
+// jqIterate implements .[]
+func jqIterate(input interface{}) ([]interface{}, error) {
+	switch val := input.(type) {
+	case []interface{}:
+		return val, nil
+	case map[string]interface{}:
+		keys, _ := jqKeys(val)
+		var outputs []interface{}
+		for _, k := range keys.([]interface{}) {
+			outputs = append(outputs, val[k.(string)])
+		}
+		return outputs, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", jqType(input))
+}
+
+// jqSlice implements .[from:to] on arrays and strings
+func jqSlice(from, to jqFilter) jqFilter {
+	bound := func(f jqFilter, input interface{}, length, def int) (int, error) {
+		if f == nil {
+			return def, nil
+		}
+		values, err := f(input)
+		if err != nil {
+			return 0, err
+		}
+		if len(values) != 1 {
+			return 0, fmt.Errorf("slice bounds must produce a single value")
+		}
+		if values[0] == nil {
+			return def, nil
+		}
+		n, ok := values[0].(float64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", jqType(values[0]))
+		}
+		i := int(n)
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i > length {
+			i = length
+		}
+		return i, nil
+	}
+	return func(input interface{}) ([]interface{}, error) {
+		var length int
+		switch val := input.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			length = len(val)
+		case string:
+			length = len(val)
+		default:
+			return nil, fmt.Errorf("cannot slice %s", jqType(input))
+		}
+		start, err := bound(from, input, length, 0)
+		if err != nil {
+			return nil, err
+		}
+		end, err := bound(to, input, length, length)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			end = start
+		}
+		if s, ok := input.(string); ok {
+			return []interface{}{s[start:end]}, nil
+		}
+		return []interface{}{input.([]interface{})[start:end]}, nil
+	}
+}
+
+func jqLength(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return float64(0), nil
+	case bool:
+		return nil, fmt.Errorf("boolean has no length")
+	case float64:
+		if val < 0 {
+			return -val, nil
+		}
+		return val, nil
+	case string:
+		return float64(len([]rune(val))), nil
+	case []interface{}:
+		return float64(len(val)), nil
+	case map[string]interface{}:
+		return float64(len(val)), nil
+	}
+	return nil, fmt.Errorf("%s has no length", jqType(v))
+}
+
+func jqKeys(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		result := make([]interface{}, len(keys))
+		for i, k := range keys {
+			result[i] = k
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i := range val {
+			result[i] = float64(i)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%s has no keys", jqType(v))
+}
+
+// jqAdd sums the numbers, or concatenates the strings or arrays, of an array
+func jqAdd(v interface{}) (interface{}, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot add the elements of %s", jqType(v))
+	}
+	var sum interface{}
+	for _, value := range values {
+		switch acc := sum.(type) {
+		case nil:
+			sum = value
+		case float64:
+			n, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("number and %s cannot be added", jqType(value))
+			}
+			sum = acc + n
+		case string:
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("string and %s cannot be added", jqType(value))
+			}
+			sum = acc + s
+		case []interface{}:
+			a, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("array and %s cannot be added", jqType(value))
+			}
+			sum = append(append([]interface{}{}, acc...), a...)
+		default:
+			return nil, fmt.Errorf("%s cannot be added", jqType(acc))
+		}
+	}
+	return sum, nil
+}
+
+func jqTruthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+func jqType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jqTypeOrder ranks the types in the order jq sorts them
+var jqTypeOrder = map[string]int{"null": 0, "boolean": 1, "number": 2, "string": 3, "array": 4, "object": 5}
+
+// jqCompare returns -1, 0 or 1 as a sorts before, equal to or after b. Values of different types
+// are ordered by type, and objects by their JSON encoding.
+func jqCompare(a, b interface{}) int {
+	ta, tb := jqTypeOrder[jqType(a)], jqTypeOrder[jqType(b)]
+	if ta != tb {
+		if ta < tb {
+			return -1
+		}
+		return 1
+	}
+	switch va := a.(type) {
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		}
+		if !va {
+			return -1
+		}
+		return 1
+	case float64:
+		vb := b.(float64)
+		if va < vb {
+			return -1
+		}
+		if va > vb {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(va, b.(string))
+	case []interface{}:
+		vb := b.([]interface{})
+		for i := 0; i < len(va) && i < len(vb); i++ {
+			if c := jqCompare(va[i], vb[i]); c != 0 {
+				return c
+			}
+		}
+		return jqCompare(float64(len(va)), float64(len(vb)))
+	case map[string]interface{}:
+		if reflect.DeepEqual(a, b) {
+			return 0
+		}
+		ja, _ := json.Marshal(a)
+		jb, _ := json.Marshal(b)
+		return strings.Compare(string(ja), string(jb))
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/jbrette/kubext/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var jqTestObject = map[string]interface{}{
+	"metadata": map[string]interface{}{
+		"name":   "my-widget",
+		"labels": map[string]interface{}{"app.kubernetes.io/name": "widget", "tier": "backend"},
+	},
+	"status": map[string]interface{}{
+		"replicas": 3,
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "True"},
+			map[string]interface{}{"type": "Progressing", "status": "False"},
+		},
+	},
+}
+
+// TestEvaluateJQFilter verifies the supported subset of jq evaluates like jq -c
+func TestEvaluateJQFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		output string
+	}{
+		{".metadata.name", `"my-widget"`},
+		{`.metadata.labels["app.kubernetes.io/name"]`, `"widget"`},
+		{`.metadata.labels."tier"`, `"backend"`},
+		{".status.replicas", "3"},
+		{".status.missing", "null"},
+		{".status.conditions[-1].type", `"Progressing"`},
+		{".status.conditions[].type", "\"Ready\"\n\"Progressing\""},
+		{".status.conditions[0:1] | length", "1"},
+		{`.status.conditions[] | select(.status == "True") | .type`, `"Ready"`},
+		{"[.status.conditions[].type]", `["Ready","Progressing"]`},
+		{".metadata.labels | keys", `["app.kubernetes.io/name","tier"]`},
+		{"{name: .metadata.name, replicas: .status.replicas}", `{"name":"my-widget","replicas":3}`},
+		{".metadata.name, .status.replicas", "\"my-widget\"\n3"},
+		{".status.ready // false", "false"},
+		{".status.replicas > 2 and has(\"status\")", "true"},
+		{".status.conditions | map(.type) | add", `"ReadyProgressing"`},
+		{".metadata.name.first?", ""},
+		{".status.replicas | tostring", `"3"`},
+		{"empty", ""},
+	}
+	for _, test := range tests {
+		output, err := evaluateJQFilter(jqTestObject, test.filter)
+		if assert.NoError(t, err, test.filter) {
+			assert.Equal(t, test.output, output, test.filter)
+		}
+	}
+}
+
+// TestEvaluateJQFilterErrors verifies invalid and unsupported filters are bad requests
+func TestEvaluateJQFilterErrors(t *testing.T) {
+	for _, filter := range []string{".metadata.name.first", ".status[", "sort_by(.name)", ".a + .b", ".status.replicas[]"} {
+		_, err := evaluateJQFilter(jqTestObject, filter)
+		if assert.Error(t, err, filter) {
+			assert.Equal(t, errors.CodeBadRequest, err.(errors.KubextError).Code(), filter)
+		}
+	}
+}
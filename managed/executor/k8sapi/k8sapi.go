@@ -0,0 +1,304 @@
+// Package k8sapi implements a container runtime executor which acts on the containers of the
+// managed pod through the Kubernetes API instead of the docker daemon of the node. It does not
+// need any host path volume, which makes it suitable for nodes running containerd or CRI-O, at
+// the cost of requiring the service account of the pod to watch pods, get their logs and exec
+// into them. The filesystem of a terminated container cannot be reached through the API, so
+// outputs must be saved on volumes of the main container (e.g. an emptyDir), which the controller
+// mounts to the wait container at the same paths. Containers are signaled with kill, which must
+// be available in the image of the main container.
+package k8sapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// killTimeout is how long to wait for containers to terminate after SIGKILL
+var killTimeout = 10 * time.Second
+
+// K8sAPIExecutor is a container runtime executor using the Kubernetes API
+type K8sAPIExecutor struct {
+	clientset kubernetes.Interface
+	podName   string
+	namespace string
+	// rootDir is the directory under which the volumes of the containers are mounted
+	rootDir string
+	// execContainer runs a command in a container of the pod, writing its stdout to stdout
+	execContainer func(containerName string, stdout io.Writer, command ...string) error
+}
+
+// NewK8sAPIExecutor returns an executor acting on the containers of a pod
+func NewK8sAPIExecutor(clientset kubernetes.Interface, config *rest.Config, podName, namespace string) *K8sAPIExecutor {
+	return &K8sAPIExecutor{
+		clientset: clientset,
+		podName:   podName,
+		namespace: namespace,
+		rootDir:   "/",
+		execContainer: func(containerName string, stdout io.Writer, command ...string) error {
+			exec, err := common.ExecPodContainer(config, namespace, podName, containerName, true, true, command...)
+			if err != nil {
+				return err
+			}
+			var stderr bytes.Buffer
+			err = exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: &stderr})
+			if err != nil {
+				return errors.InternalErrorf("`%s` in container %s failed: %v: %s", strings.Join(command, " "), containerName, err, stderr.String())
+			}
+			return nil
+		},
+	}
+}
+
+// GetFileContents returns the file contents of a file in a container as a string. The file is
+// read from the volume of the container mounted to the wait container at the same path.
+func (k *K8sAPIExecutor) GetFileContents(containerID string, sourcePath string) (string, error) {
+	path, err := k.volumePath(containerID, sourcePath)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	return string(data), nil
+}
+
+// CopyFile copies a source file or directory in a container to a local path, as a gzipped
+// tarball like docker cp
+func (k *K8sAPIExecutor) CopyFile(containerID string, sourcePath string, destPath string) error {
+	path, err := k.volumePath(containerID, sourcePath)
+	if err != nil {
+		return err
+	}
+	log.Infof("Archiving %s:%s to %s", containerID, sourcePath, destPath)
+	f, err := os.Create(destPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	w := gzip.NewWriter(f)
+	err = common.WriteTarball(w, path)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	log.Infof("Archiving completed")
+	return nil
+}
+
+// CopyFileStream returns a stream of a source file or directory in a container, as a tarball
+func (k *K8sAPIExecutor) CopyFileStream(containerID string, sourcePath string) (io.ReadCloser, error) {
+	path, err := k.volumePath(containerID, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Streaming %s:%s", containerID, sourcePath)
+	return common.PipeStream(func(w io.Writer) error {
+		return common.WriteTarball(w, path)
+	}), nil
+}
+
+// volumePath returns the local path of a file of a container, which must be on a volume of the
+// container mounted to the wait container at the same path
+func (k *K8sAPIExecutor) volumePath(containerID string, path string) (string, error) {
+	_, err := k.getContainerName(containerID)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(k.rootDir, path)
+	_, err = os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.Errorf(errors.CodeNotFound, "%s not found: outputs of the k8sapi executor must be saved on a volume of the main container", path)
+		}
+		return "", errors.InternalWrapError(err)
+	}
+	return path, nil
+}
+
+// GetOutput returns the entirety of the container output as a string
+// Used to capturing script results as an output parameter
+func (k *K8sAPIExecutor) GetOutput(containerID string) (string, error) {
+	containerName, err := k.getContainerName(containerID)
+	if err != nil {
+		return "", err
+	}
+	stream, err := k.clientset.CoreV1().Pods(k.namespace).GetLogs(k.podName, &apiv1.PodLogOptions{Container: containerName}).Stream()
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	defer func() { _ = stream.Close() }()
+	outBytes, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	return strings.TrimSpace(string(outBytes)), nil
+}
+
+// Wait for the container to complete
+func (k *K8sAPIExecutor) Wait(containerID string) error {
+	podsIf := k.clientset.CoreV1().Pods(k.namespace)
+	for {
+		pod, err := podsIf.Get(k.podName, metav1.GetOptions{})
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		if isContainerTerminated(pod, containerID) {
+			return nil
+		}
+		w, err := podsIf.Watch(metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", k.podName).String(),
+			ResourceVersion: pod.ResourceVersion,
+		})
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		terminated, err := waitContainerTerminated(w, containerID)
+		w.Stop()
+		if terminated || err != nil {
+			return err
+		}
+		log.Infof("Watch of pod %s closed. Watching again", k.podName)
+	}
+}
+
+// waitContainerTerminated returns true once the container is terminated, or false when the watch
+// is closed before
+func waitContainerTerminated(w watch.Interface, containerID string) (bool, error) {
+	for event := range w.ResultChan() {
+		switch event.Type {
+		case watch.Error:
+			return false, errors.InternalErrorf("watch of pod failed: %v", event.Object)
+		case watch.Deleted:
+			return false, errors.InternalError("pod was deleted")
+		}
+		pod, ok := event.Object.(*apiv1.Pod)
+		if ok && isContainerTerminated(pod, containerID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Kill a list of containerIDs first with a SIGTERM then with a SIGKILL after a grace period. The
+// signals are sent with kill from inside each container to its first process. The kernel does not
+// deliver to the first process of a PID namespace the signals it has no handler for, SIGKILL
+// included, so a process which does not handle SIGTERM, such as a shell, cannot be killed this way.
+// An error is returned if the containers are still running once the SIGKILL had the time to
+// take effect.
+func (k *K8sAPIExecutor) Kill(containerIDs []string, terminationGracePeriod time.Duration) error {
+	var containerNames []string
+	for _, containerID := range containerIDs {
+		containerName, err := k.getContainerName(containerID)
+		if err != nil {
+			return err
+		}
+		containerNames = append(containerNames, containerName)
+	}
+	err := k.signal(containerNames, "TERM")
+	if err != nil {
+		return err
+	}
+	done := make(chan error, len(containerIDs))
+	for _, containerID := range containerIDs {
+		go func(containerID string) {
+			done <- k.Wait(containerID)
+		}(containerID)
+	}
+	timer := time.NewTimer(terminationGracePeriod)
+	defer timer.Stop()
+	killed := false
+	for remaining := len(containerIDs); remaining > 0; {
+		select {
+		case err = <-done:
+			if err != nil {
+				return err
+			}
+			remaining--
+		case <-timer.C:
+			if killed {
+				return errors.InternalErrorf("containers %s are still running after SIGKILL: their first process must handle SIGTERM to be killed by the k8sapi executor", containerNames)
+			}
+			log.Infof("Timed out (%v) for containers to terminate gracefully. Killing forcefully", terminationGracePeriod)
+			err = k.signal(containerNames, "KILL")
+			if err != nil {
+				return err
+			}
+			killed = true
+			timer.Reset(killTimeout)
+		}
+	}
+	log.Infof("Containers %s killed successfully", containerNames)
+	return nil
+}
+
+// signal sends a signal to the first process of each container
+func (k *K8sAPIExecutor) signal(containerNames []string, signal string) error {
+	for _, containerName := range containerNames {
+		log.Infof("Sending SIG%s to container %s", signal, containerName)
+		err := k.execContainer(containerName, ioutil.Discard, "sh", "-c", fmt.Sprintf("kill -%s 1", signal))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetContainerStatus returns the status of the container of the pod with the given ID
+func (k *K8sAPIExecutor) GetContainerStatus(containerID string) (*apiv1.ContainerStatus, error) {
+	pod, err := k.clientset.CoreV1().Pods(k.namespace).Get(k.podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	status := getContainerStatus(pod, containerID)
+	if status == nil {
+		return nil, errors.InternalErrorf("container %s not found in pod %s", containerID, k.podName)
+	}
+	return status, nil
+}
+
+// getContainerName returns the name of the container of the pod with the given ID
+func (k *K8sAPIExecutor) getContainerName(containerID string) (string, error) {
+	status, err := k.GetContainerStatus(containerID)
+	if err != nil {
+		return "", err
+	}
+	return status.Name, nil
+}
+
+// getContainerStatus returns the status of the container of the pod with the given ID, which
+// is stripped of its runtime prefix, e.g. containerd://
+func getContainerStatus(pod *apiv1.Pod, containerID string) *apiv1.ContainerStatus {
+	for i, status := range pod.Status.ContainerStatuses {
+		if status.ContainerID == containerID || strings.HasSuffix(status.ContainerID, "://"+containerID) {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+func isContainerTerminated(pod *apiv1.Pod, containerID string) bool {
+	status := getContainerStatus(pod, containerID)
+	return status != nil && status.State.Terminated != nil
+}
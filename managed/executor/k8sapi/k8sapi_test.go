@@ -0,0 +1,148 @@
+package k8sapi
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbrette/kubext/managed/executor/k8sapi/k8sapitest"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeExecutor returns an executor whose commands are run by exec instead of the API server
+func newFakeExecutor(pod *apiv1.Pod, exec func(containerName string, stdout io.Writer, command ...string) error) (*K8sAPIExecutor, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset(pod)
+	return &K8sAPIExecutor{
+		clientset:     clientset,
+		podName:       k8sapitest.PodName,
+		namespace:     k8sapitest.Namespace,
+		execContainer: exec,
+	}, clientset
+}
+
+// newFakeVolume returns a directory standing for the root of the wait container, with a file on
+// the volume mounted at /work
+func newFakeVolume(t *testing.T) string {
+	rootDir, err := ioutil.TempDir("", "k8sapi")
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(rootDir, "work", "output"), 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(rootDir, "work", "output", "message"), []byte("hello"), 0644)
+	assert.NoError(t, err)
+	return rootDir
+}
+
+// TestGetFileContents verifies files are read from the volumes mounted to the wait container
+func TestGetFileContents(t *testing.T) {
+	rootDir := newFakeVolume(t)
+	defer func() { _ = os.RemoveAll(rootDir) }()
+	k, _ := newFakeExecutor(k8sapitest.NewPod(k8sapitest.Terminated), nil)
+	k.rootDir = rootDir
+	contents, err := k.GetFileContents(k8sapitest.ContainerID, "/work/output/message")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", contents)
+
+	_, err = k.GetFileContents(k8sapitest.ContainerID, "/tmp/message")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must be saved on a volume")
+	}
+
+	_, err = k.GetFileContents("unknown", "/work/output/message")
+	assert.Error(t, err)
+}
+
+// TestCopyFile verifies the tarball of a directory of a volume is gzipped to the destination
+func TestCopyFile(t *testing.T) {
+	rootDir := newFakeVolume(t)
+	defer func() { _ = os.RemoveAll(rootDir) }()
+	k, _ := newFakeExecutor(k8sapitest.NewPod(k8sapitest.Terminated), nil)
+	k.rootDir = rootDir
+	destPath := filepath.Join(rootDir, "output.tgz")
+	err := k.CopyFile(k8sapitest.ContainerID, "/work/output", destPath)
+	assert.NoError(t, err)
+
+	f, err := os.Open(destPath)
+	assert.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	gzr, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equal(t, []string{"output/", "output/message"}, names)
+}
+
+// TestKill verifies containers are sent SIGTERM, then SIGKILL once the grace period expires
+func TestKill(t *testing.T) {
+	killTimeout = 10 * time.Millisecond
+	var commands []string
+	k, clientset := newFakeExecutor(k8sapitest.NewPod(k8sapitest.Running), func(containerName string, stdout io.Writer, command ...string) error {
+		commands = append(commands, strings.Join(command, " "))
+		return nil
+	})
+	exec := k.execContainer
+
+	// the container terminates after SIGKILL
+	watcher := watch.NewFake()
+	clientset.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	k.execContainer = func(containerName string, stdout io.Writer, command ...string) error {
+		if command[2] == "kill -KILL 1" {
+			go watcher.Modify(k8sapitest.NewPod(k8sapitest.Terminated))
+		}
+		return exec(containerName, stdout, command...)
+	}
+	err := k.Kill([]string{k8sapitest.ContainerID}, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sh -c kill -TERM 1", "sh -c kill -KILL 1"}, commands)
+
+	// the container ignores the signals
+	commands = nil
+	k, clientset = newFakeExecutor(k8sapitest.NewPod(k8sapitest.Running), exec)
+	watcher = watch.NewFake()
+	clientset.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	err = k.Kill([]string{k8sapitest.ContainerID}, 10*time.Millisecond)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "still running after SIGKILL")
+	}
+	assert.Equal(t, []string{"sh -c kill -TERM 1", "sh -c kill -KILL 1"}, commands)
+
+	// the container terminates within the grace period
+	commands = nil
+	k, clientset = newFakeExecutor(k8sapitest.NewPod(k8sapitest.Running), exec)
+	watcher = watch.NewFake()
+	clientset.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	go watcher.Modify(k8sapitest.NewPod(k8sapitest.Terminated))
+	err = k.Kill([]string{k8sapitest.ContainerID}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sh -c kill -TERM 1"}, commands)
+}
+
+// TestWait verifies the pod is watched until the container terminates
+func TestWait(t *testing.T) {
+	k, clientset := newFakeExecutor(k8sapitest.NewPod(k8sapitest.Running), nil)
+	watcher := watch.NewFake()
+	clientset.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	go func() {
+		watcher.Modify(k8sapitest.NewPod(k8sapitest.Running))
+		watcher.Modify(k8sapitest.NewPod(k8sapitest.Terminated))
+	}()
+	err := k.Wait(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,36 @@
+// Package k8sapitest provides the fake pod of the tests of the executors reading the statuses of
+// the containers through the Kubernetes API
+package k8sapitest
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PodName is the name of the fake pod
+	PodName = "fake-pod"
+	// Namespace is the namespace of the fake pod
+	Namespace = "default"
+	// ContainerID is the ID of the main container of the fake pod
+	ContainerID = "0123456789abcdef"
+)
+
+var (
+	// Running is the state of a running container
+	Running = apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	// Terminated is the state of a container terminated by a SIGTERM
+	Terminated = apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 143}}
+)
+
+// NewPod returns the fake pod, whose main container is in the given state
+func NewPod(state apiv1.ContainerState) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: PodName, Namespace: Namespace},
+		Status: apiv1.PodStatus{
+			ContainerStatuses: []apiv1.ContainerStatus{
+				{Name: "main", ContainerID: "containerd://" + ContainerID, State: state},
+			},
+		},
+	}
+}
@@ -1,7 +1,9 @@
 // Code generated by mockery v1.0.0
 package mocks
 
+import io "io"
 import mock "github.com/stretchr/testify/mock"
+import time "time"
 
 // ContainerRuntimeExecutor is an autogenerated mock type for the ContainerRuntimeExecutor type
 type ContainerRuntimeExecutor struct {
@@ -22,6 +24,29 @@ func (_m *ContainerRuntimeExecutor) CopyFile(containerID string, sourcePath stri
 	return r0
 }
 
+// CopyFileStream provides a mock function with given fields: containerID, sourcePath
+func (_m *ContainerRuntimeExecutor) CopyFileStream(containerID string, sourcePath string) (io.ReadCloser, error) {
+	ret := _m.Called(containerID, sourcePath)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(string, string) io.ReadCloser); ok {
+		r0 = rf(containerID, sourcePath)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(containerID, sourcePath)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetFileContents provides a mock function with given fields: containerID, sourcePath
 func (_m *ContainerRuntimeExecutor) GetFileContents(containerID string, sourcePath string) (string, error) {
 	ret := _m.Called(containerID, sourcePath)
@@ -64,13 +89,13 @@ func (_m *ContainerRuntimeExecutor) GetOutput(containerID string) (string, error
 	return r0, r1
 }
 
-// Kill provides a mock function with given fields: containerIDs
-func (_m *ContainerRuntimeExecutor) Kill(containerIDs []string) error {
-	ret := _m.Called(containerIDs)
+// Kill provides a mock function with given fields: containerIDs, terminationGracePeriod
+func (_m *ContainerRuntimeExecutor) Kill(containerIDs []string, terminationGracePeriod time.Duration) error {
+	ret := _m.Called(containerIDs, terminationGracePeriod)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func([]string) error); ok {
-		r0 = rf(containerIDs)
+	if rf, ok := ret.Get(0).(func([]string, time.Duration) error); ok {
+		r0 = rf(containerIDs, terminationGracePeriod)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,349 @@
+// Package pns implements a container runtime executor which acts on the processes of the main
+// containers, found through /proc in the process namespace shared by the containers of the pod
+// (shareProcessNamespace). It does not need any host path volume. Processes are matched to their
+// container by the container ID found in /proc/<pid>/cgroup, which requires the cgroup paths of
+// the containers to be visible from the wait container. This is not the case with cgroup v2 and a
+// private cgroup namespace, in which the wait fails once the container has been running for
+// pidTimeout without its process being found. Container logs and exit codes are read
+// through the Kubernetes API. The main containers with a command wait for the wait container to
+// hold their root filesystem before running it, so that the outputs of the containers exiting
+// right away are not lost.
+package pns
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	"github.com/jbrette/kubext/managed/executor/k8sapi"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// pidPollInterval is the interval at which /proc is polled for the processes of the containers
+	pidPollInterval = 100 * time.Millisecond
+	// statusPollInterval is the interval at which the pod status is checked for a container which
+	// terminated before its process was found
+	statusPollInterval = 1 * time.Second
+	// exitCodeTimeout is how long to wait for the pod status to report the exit code of a container
+	exitCodeTimeout = 10 * time.Second
+	// pidTimeout is how long to look for the process of a container once the pod status reports
+	// it running
+	pidTimeout = 1 * time.Minute
+)
+
+// PNSExecutor is a container runtime executor using the process namespace of the pod
+type PNSExecutor struct {
+	k8sAPI  *k8sapi.K8sAPIExecutor
+	procDir string
+	// kill sends a signal to a process
+	kill func(pid int, sig syscall.Signal) error
+
+	mu sync.Mutex
+	// rootFS holds the root filesystem of the main containers open, keyed by container ID, so
+	// that their files remain accessible after they exited
+	rootFS map[string]*os.File
+}
+
+// NewPNSExecutor returns an executor acting on the processes of the containers of a pod
+func NewPNSExecutor(clientset kubernetes.Interface, config *rest.Config, podName, namespace string) *PNSExecutor {
+	return &PNSExecutor{
+		k8sAPI:  k8sapi.NewK8sAPIExecutor(clientset, config, podName, namespace),
+		procDir: "/proc",
+		kill:    syscall.Kill,
+		rootFS:  make(map[string]*os.File),
+	}
+}
+
+// GetFileContents returns the file contents of a file in a container as a string
+func (p *PNSExecutor) GetFileContents(containerID string, sourcePath string) (string, error) {
+	path, err := p.rootPath(containerID, sourcePath)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	return string(data), nil
+}
+
+// CopyFile copies a source file or directory in a container to a local path, as a gzipped
+// tarball like docker cp
+func (p *PNSExecutor) CopyFile(containerID string, sourcePath string, destPath string) error {
+	path, err := p.rootPath(containerID, sourcePath)
+	if err != nil {
+		return err
+	}
+	log.Infof("Archiving %s:%s to %s", containerID, sourcePath, destPath)
+	f, err := os.Create(destPath)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() { _ = f.Close() }()
+	gzw := gzip.NewWriter(f)
+	err = common.WriteTarball(gzw, path)
+	if err != nil {
+		return err
+	}
+	err = gzw.Close()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	log.Infof("Archiving completed")
+	return nil
+}
+
+// CopyFileStream returns a stream of a source file or directory in a container, as a tarball
+func (p *PNSExecutor) CopyFileStream(containerID string, sourcePath string) (io.ReadCloser, error) {
+	path, err := p.rootPath(containerID, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Streaming %s:%s", containerID, sourcePath)
+	return common.PipeStream(func(w io.Writer) error {
+		return common.WriteTarball(w, path)
+	}), nil
+}
+
+// GetOutput returns the entirety of the container output as a string
+// Used to capturing script results as an output parameter
+func (p *PNSExecutor) GetOutput(containerID string) (string, error) {
+	return p.k8sAPI.GetOutput(containerID)
+}
+
+// Wait for the container to complete. The root filesystem of the container is held open once its
+// process is found, so that its outputs can be copied after it exited.
+func (p *PNSExecutor) Wait(containerID string) error {
+	pid, err := p.waitPid(containerID)
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		log.Warnf("Container %s terminated before its process was found", containerID)
+		return nil
+	}
+	log.Infof("Waiting for process %d of container %s", pid, containerID)
+	p.waitProcess(pid, containerID, nil)
+	p.logExitCode(containerID)
+	return nil
+}
+
+// HoldRootFS waits for the process of a container to start and holds its root filesystem, so that
+// its outputs can be read after it exited. Nothing is held if the container terminated before.
+func (p *PNSExecutor) HoldRootFS(containerID string) error {
+	pid, err := p.waitPid(containerID)
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		log.Warnf("Container %s terminated before its process was found", containerID)
+	}
+	return nil
+}
+
+// waitPid waits for the process of a container to be started and returns its pid, or 0 when the
+// pod status reports the container terminated before its process was found. It fails when the
+// process of a running container cannot be found within pidTimeout, which is the case when the
+// container IDs are not visible in the cgroups of the processes: with cgroup v2 and a private
+// cgroup namespace, /proc/<pid>/cgroup only holds "0::/".
+func (p *PNSExecutor) waitPid(containerID string) (int, error) {
+	var statusCheck, runningSince time.Time
+	for {
+		pid, err := p.getPid(containerID)
+		if err != nil {
+			return 0, err
+		}
+		if pid != 0 {
+			p.secureRootFS(containerID, pid)
+			return pid, nil
+		}
+		if time.Since(statusCheck) >= statusPollInterval {
+			status, err := p.k8sAPI.GetContainerStatus(containerID)
+			if err != nil {
+				return 0, err
+			}
+			if status.State.Terminated != nil {
+				return 0, nil
+			}
+			if status.State.Running == nil {
+				runningSince = time.Time{}
+			} else if runningSince.IsZero() {
+				runningSince = time.Now()
+			} else if time.Since(runningSince) >= pidTimeout {
+				return 0, errors.InternalErrorf("process of running container %s not found in %s after %v: the pns executor matches processes by the container ID in their cgroup, which is hidden with cgroup v2 and a private cgroup namespace. Use the host cgroup namespace or another container runtime executor", containerID, p.procDir, pidTimeout)
+			}
+			statusCheck = time.Now()
+		}
+		time.Sleep(pidPollInterval)
+	}
+}
+
+// waitProcess waits for a process of a container to exit. It returns false if stop is closed before.
+func (p *PNSExecutor) waitProcess(pid int, containerID string, stop <-chan struct{}) bool {
+	ticker := time.NewTicker(pidPollInterval)
+	defer ticker.Stop()
+	for p.isContainerProcess(pid, containerID) {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
+// logExitCode logs the exit code of a container once the pod status reports it
+func (p *PNSExecutor) logExitCode(containerID string) {
+	err := wait.PollImmediate(statusPollInterval, exitCodeTimeout, func() (bool, error) {
+		status, err := p.k8sAPI.GetContainerStatus(containerID)
+		if err != nil {
+			return false, err
+		}
+		if status.State.Terminated == nil {
+			return false, nil
+		}
+		log.Infof("Container %s exited with code %d", containerID, status.State.Terminated.ExitCode)
+		return true, nil
+	})
+	if err != nil {
+		log.Warnf("Failed to determine the exit code of container %s: %v", containerID, err)
+	}
+}
+
+// Kill a list of containerIDs first with a SIGTERM then with a SIGKILL after a grace period. The
+// signals are sent to the first process of each container.
+func (p *PNSExecutor) Kill(containerIDs []string, terminationGracePeriod time.Duration) error {
+	pids := make(map[string]int)
+	for _, containerID := range containerIDs {
+		pid, err := p.getPid(containerID)
+		if err != nil {
+			return err
+		}
+		if pid == 0 {
+			log.Infof("Container %s is not running", containerID)
+			continue
+		}
+		pids[containerID] = pid
+	}
+	err := p.signal(pids, syscall.SIGTERM)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	done := make(chan bool, len(pids))
+	for containerID, pid := range pids {
+		go func(containerID string, pid int) {
+			done <- p.waitProcess(pid, containerID, stop)
+		}(containerID, pid)
+	}
+	timer := time.NewTimer(terminationGracePeriod)
+	defer timer.Stop()
+	for range pids {
+		select {
+		case <-done:
+		case <-timer.C:
+			log.Infof("Timed out (%v) for containers to terminate gracefully. Killing forcefully", terminationGracePeriod)
+			return p.signal(pids, syscall.SIGKILL)
+		}
+	}
+	log.Infof("Containers %s killed successfully", containerIDs)
+	return nil
+}
+
+// signal sends a signal to the processes of containers which are still running
+func (p *PNSExecutor) signal(pids map[string]int, sig syscall.Signal) error {
+	for containerID, pid := range pids {
+		if !p.isContainerProcess(pid, containerID) {
+			continue
+		}
+		log.Infof("Sending %s to process %d of container %s", sig, pid, containerID)
+		err := p.kill(pid, sig)
+		if err != nil && err != syscall.ESRCH {
+			return errors.InternalWrapError(err)
+		}
+	}
+	return nil
+}
+
+// rootPath returns the path of a file in the root filesystem of a container, through the handle
+// held since its process was found, or through its process
+func (p *PNSExecutor) rootPath(containerID string, path string) (string, error) {
+	p.mu.Lock()
+	rootFS, ok := p.rootFS[containerID]
+	p.mu.Unlock()
+	if ok {
+		return filepath.Join(fmt.Sprintf("/proc/self/fd/%d", rootFS.Fd()), path), nil
+	}
+	pid, err := p.getPid(containerID)
+	if err != nil {
+		return "", err
+	}
+	if pid == 0 {
+		return "", errors.InternalErrorf("container %s exited before its root filesystem was held: its outputs are lost. The command of the container must be set for it to wait for the root filesystem to be held", containerID)
+	}
+	return filepath.Join(p.procDir, strconv.Itoa(pid), "root", path), nil
+}
+
+// secureRootFS opens the root filesystem of a container through its process
+func (p *PNSExecutor) secureRootFS(containerID string, pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.rootFS[containerID]; ok {
+		return
+	}
+	rootFS, err := os.Open(filepath.Join(p.procDir, strconv.Itoa(pid), "root"))
+	if err != nil {
+		log.Warnf("Failed to open the root filesystem of container %s: %v", containerID, err)
+		return
+	}
+	p.rootFS[containerID] = rootFS
+}
+
+// getPid returns the pid of the first process of a container, or 0 if it has none
+func (p *PNSExecutor) getPid(containerID string) (int, error) {
+	entries, err := ioutil.ReadDir(p.procDir)
+	if err != nil {
+		return 0, errors.InternalWrapError(err)
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		if p.isContainerProcess(pid, containerID) {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return 0, nil
+	}
+	sort.Ints(pids)
+	return pids[0], nil
+}
+
+// isContainerProcess returns whether a process is running in a container. A process which exited,
+// or whose pid was reused by another container, is not.
+func (p *PNSExecutor) isContainerProcess(pid int, containerID string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(p.procDir, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), containerID)
+}
@@ -0,0 +1,243 @@
+package pns
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jbrette/kubext/managed/executor/k8sapi"
+	"github.com/jbrette/kubext/managed/executor/k8sapi/k8sapitest"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+const fakePid = 42
+
+// fakeProcFS is a /proc with the processes of a container
+type fakeProcFS struct {
+	t   *testing.T
+	dir string
+}
+
+func newFakeProcFS(t *testing.T) *fakeProcFS {
+	dir, err := ioutil.TempDir("", "pns")
+	assert.NoError(t, err)
+	return &fakeProcFS{t: t, dir: dir}
+}
+
+// addProcess adds a process of a container, with files in its root filesystem
+func (f *fakeProcFS) addProcess(pid int, containerID string, files map[string]string) {
+	procDir := filepath.Join(f.dir, strconv.Itoa(pid))
+	err := os.MkdirAll(filepath.Join(procDir, "root"), 0755)
+	assert.NoError(f.t, err)
+	cgroup := "12:pids:/kubepods/besteffort/pod1234/" + containerID + "\n1:name=systemd:/kubepods/besteffort/pod1234/" + containerID + "\n"
+	err = ioutil.WriteFile(filepath.Join(procDir, "cgroup"), []byte(cgroup), 0644)
+	assert.NoError(f.t, err)
+	for path, contents := range files {
+		path = filepath.Join(procDir, "root", path)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(f.t, err)
+		err = ioutil.WriteFile(path, []byte(contents), 0644)
+		assert.NoError(f.t, err)
+	}
+}
+
+// exitProcess removes a process. Its root filesystem is moved out of /proc rather than deleted,
+// like the root filesystem of an exited container held open.
+func (f *fakeProcFS) exitProcess(pid int) {
+	err := os.Rename(filepath.Join(f.dir, strconv.Itoa(pid)), filepath.Join(f.dir, "exited-"+strconv.Itoa(pid)))
+	assert.NoError(f.t, err)
+}
+
+func (f *fakeProcFS) cleanup() {
+	_ = os.RemoveAll(f.dir)
+}
+
+func newFakeExecutor(procFS *fakeProcFS, pod *apiv1.Pod) *PNSExecutor {
+	clientset := fake.NewSimpleClientset(pod)
+	return &PNSExecutor{
+		k8sAPI:  k8sapi.NewK8sAPIExecutor(clientset, &rest.Config{}, k8sapitest.PodName, k8sapitest.Namespace),
+		procDir: procFS.dir,
+		kill: func(pid int, sig syscall.Signal) error {
+			return syscall.ESRCH
+		},
+		rootFS: make(map[string]*os.File),
+	}
+}
+
+func init() {
+	pidPollInterval = time.Millisecond
+	statusPollInterval = 10 * time.Millisecond
+	pidTimeout = 50 * time.Millisecond
+}
+
+// TestGetPid verifies the first process of a container is found through its cgroup
+func TestGetPid(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procFS.addProcess(7, "fedcba9876543210", nil)
+	procFS.addProcess(fakePid+1, k8sapitest.ContainerID, nil)
+	procFS.addProcess(fakePid, k8sapitest.ContainerID, nil)
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Running))
+
+	pid, err := p.getPid(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+	assert.Equal(t, fakePid, pid)
+
+	pid, err = p.getPid("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pid)
+}
+
+// TestGetFileContents verifies files are read from the root filesystem of the container
+func TestGetFileContents(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procFS.addProcess(fakePid, k8sapitest.ContainerID, map[string]string{"/tmp/message": "hello"})
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Running))
+
+	contents, err := p.GetFileContents(k8sapitest.ContainerID, "/tmp/message")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", contents)
+
+	_, err = p.GetFileContents("unknown", "/tmp/message")
+	assert.Error(t, err)
+}
+
+// TestWait verifies the process of the container is waited for, and that its files remain
+// accessible after it exited
+func TestWait(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procFS.addProcess(fakePid, k8sapitest.ContainerID, map[string]string{"/tmp/output/a.txt": "a", "/tmp/output/b/b.txt": "b"})
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Terminated))
+	go func() {
+		for {
+			p.mu.Lock()
+			_, ok := p.rootFS[k8sapitest.ContainerID]
+			p.mu.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		procFS.exitProcess(fakePid)
+	}()
+	err := p.Wait(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+
+	contents, err := p.GetFileContents(k8sapitest.ContainerID, "/tmp/output/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", contents)
+
+	destPath := filepath.Join(procFS.dir, "output.tgz")
+	err = p.CopyFile(k8sapitest.ContainerID, "/tmp/output", destPath)
+	assert.NoError(t, err)
+	f, err := os.Open(destPath)
+	assert.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	gzr, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"output/", "output/a.txt", "output/b/", "output/b/b.txt"}, names)
+}
+
+// TestWaitTerminatedContainer verifies waiting for a container which terminated before its process
+// was found returns once the pod status reports it terminated
+func TestWaitTerminatedContainer(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Terminated))
+	err := p.Wait(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+}
+
+// TestWaitHiddenCgroup verifies waiting for a running container fails when the cgroup of its
+// process does not show the container ID, as with cgroup v2 and a private cgroup namespace
+func TestWaitHiddenCgroup(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procDir := filepath.Join(procFS.dir, strconv.Itoa(fakePid))
+	err := os.MkdirAll(procDir, 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(procDir, "cgroup"), []byte("0::/\n"), 0644)
+	assert.NoError(t, err)
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Running))
+	err = p.Wait(k8sapitest.ContainerID)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "private cgroup namespace")
+	}
+}
+
+// TestHoldRootFS verifies the outputs of a container exiting right after its root filesystem was
+// held remain accessible, and that the outputs of a container exiting before are reported lost
+func TestHoldRootFS(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procFS.addProcess(fakePid, k8sapitest.ContainerID, map[string]string{"/tmp/message": "hello"})
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Running))
+	err := p.HoldRootFS(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+	procFS.exitProcess(fakePid)
+	contents, err := p.GetFileContents(k8sapitest.ContainerID, "/tmp/message")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", contents)
+
+	p = newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Terminated))
+	err = p.HoldRootFS(k8sapitest.ContainerID)
+	assert.NoError(t, err)
+	_, err = p.GetFileContents(k8sapitest.ContainerID, "/tmp/message")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "its outputs are lost")
+	}
+}
+
+// TestKill verifies processes are sent SIGTERM, then SIGKILL once the grace period expires
+func TestKill(t *testing.T) {
+	procFS := newFakeProcFS(t)
+	defer procFS.cleanup()
+	procFS.addProcess(fakePid, k8sapitest.ContainerID, nil)
+	p := newFakeExecutor(procFS, k8sapitest.NewPod(k8sapitest.Running))
+	var signals []syscall.Signal
+	p.kill = func(pid int, sig syscall.Signal) error {
+		assert.Equal(t, fakePid, pid)
+		signals = append(signals, sig)
+		if sig == syscall.SIGKILL {
+			procFS.exitProcess(pid)
+		}
+		return nil
+	}
+	err := p.Kill([]string{k8sapitest.ContainerID}, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL}, signals)
+
+	// the process exits within the grace period
+	signals = nil
+	procFS.addProcess(fakePid+1, k8sapitest.ContainerID, nil)
+	p.kill = func(pid int, sig syscall.Signal) error {
+		signals = append(signals, sig)
+		procFS.exitProcess(pid)
+		return nil
+	}
+	err = p.Kill([]string{k8sapitest.ContainerID}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []syscall.Signal{syscall.SIGTERM}, signals)
+}
@@ -3,254 +3,715 @@ package executor
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
 	log "github.com/sirupsen/logrus"
-	"github.com/tidwall/gjson"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
 )
 
-// ExecResource will run kubectl action against a manifest
-func (we *ManagedExecutor) ExecResource(action string, manifestPath string) (string, error) {
-	args := []string{
-		action,
-	}
-	if action == "delete" {
-		args = append(args, "--ignore-not-found")
-	}
-	args = append(args, "-f")
-	args = append(args, manifestPath)
-	args = append(args, "-o")
-	args = append(args, "name")
-	cmd := exec.Command("kubectl", args...)
-	log.Info(strings.Join(cmd.Args, " "))
-	out, err := cmd.Output()
+const (
+	// defaultFieldManager is the field manager of the server-side-apply action
+	defaultFieldManager = "kubext"
+	// applyPatchType is the content type of the patches of server-side apply
+	applyPatchType = types.PatchType("application/apply-patch+yaml")
+)
+
+// deletionPollInterval is the interval at which a deleted resource is checked until it is gone
+var deletionPollInterval = 2 * time.Second
+
+// ExecResource performs an action (get, create, apply, server-side-apply, patch, replace or
+// delete) against each resource of a manifest, which may contain several YAML documents, and
+// returns the resulting objects. The references to the resources which may have been created
+// are recorded on the pod, including when a later resource fails.
+func (we *ManagedExecutor) ExecResource(action string, manifestPath string) ([]*unstructured.Unstructured, error) {
+	switch action {
+	case "get", "create", "apply", "server-side-apply", "patch", "replace", "delete":
+	default:
+		return nil, errors.Errorf(errors.CodeBadRequest, "unsupported resource action '%s'", action)
+	}
+	objs, err := readResourceManifests(manifestPath, action == "create")
+	if err != nil {
+		return nil, err
+	}
+	resTmpl := we.Template.Resource
+	if resTmpl == nil {
+		resTmpl = &wfv1.ResourceTemplate{}
+	}
+	var results []*unstructured.Unstructured
+	var refs []wfv1.ResourceRef
+	for _, obj := range objs {
+		var result *unstructured.Unstructured
+		var apiResource *metav1.APIResource
+		result, apiResource, err = we.execResourceAction(action, obj, resTmpl)
+		if err != nil {
+			break
+		}
+		results = append(results, result)
+		switch action {
+		case "create", "apply", "server-side-apply":
+			refs = append(refs, newResourceRef(result, apiResource))
+		}
+	}
+	if len(refs) > 0 {
+		refsErr := we.annotateResourceRefs(refs)
+		if err == nil {
+			err = refsErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// execResourceAction performs an action against one resource and returns the resulting object
+// and its API resource
+func (we *ManagedExecutor) execResourceAction(action string, obj *unstructured.Unstructured, resTmpl *wfv1.ResourceTemplate) (*unstructured.Unstructured, *metav1.APIResource, error) {
+	apiResource, err := we.getAPIResource(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	ri, err := we.getResourceInterface(obj, apiResource)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch action {
+	case "create", "apply", "server-side-apply":
+		err = we.setManagedUID(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if resTmpl.SetOwnerReference && action != "get" && action != "patch" && action != "delete" {
+		err = we.setOwnerReference(obj, apiResource)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	log.Infof("%s %s", action, resourceName(obj))
+	var result *unstructured.Unstructured
+	switch action {
+	case "get":
+		result, err = ri.Get(obj.GetName(), metav1.GetOptions{})
+	case "create":
+		result, err = ri.Create(obj)
+	case "apply":
+		result, err = applyResource(ri, obj)
+	case "server-side-apply":
+		result, err = we.serverSideApplyResource(obj, apiResource, resTmpl.FieldManager)
+	case "patch":
+		result, err = patchResource(ri, obj, resTmpl)
+	case "replace":
+		result, err = replaceResource(ri, obj)
+	case "delete":
+		err = deleteResource(ri, obj, resTmpl)
+		result = obj
+	}
 	if err != nil {
-		exErr := err.(*exec.ExitError)
-		errMsg := strings.TrimSpace(string(exErr.Stderr))
-		return "", errors.New(errors.CodeBadRequest, errMsg)
+		return nil, nil, resourceError(err)
 	}
-	resourceName := strings.TrimSpace(string(out))
-	log.Infof(resourceName)
-	return resourceName, nil
+	log.Info(resourceName(result))
+	return result, apiResource, nil
 }
 
-// gjsonLabels is an implementation of labels.Labels interface
-// which allows us to take advantage of k8s labels library
-// for the purposes of evaluating fail and success conditions
-type gjsonLabels struct {
-	json []byte
+// setOwnerReference adds the managed, which is the controller of the pod, to the owners of the
+// resource. Owner references cannot cross namespaces, so the resource must be namespaced and in
+// the namespace of the pod.
+func (we *ManagedExecutor) setOwnerReference(obj *unstructured.Unstructured, apiResource *metav1.APIResource) error {
+	if !apiResource.Namespaced || obj.GetNamespace() != we.Namespace {
+		return errors.Errorf(errors.CodeBadRequest, "cannot set the owner reference of %s: only resources in namespace %s can be owned by the managed", resourceName(obj), we.Namespace)
+	}
+	owner, err := we.getManagedOwner()
+	if err != nil {
+		return err
+	}
+	ownerRefs := obj.GetOwnerReferences()
+	for _, ref := range ownerRefs {
+		if ref.UID == owner.UID {
+			return nil
+		}
+	}
+	// the managed does not control the resource, which may have a controller of its own
+	ownerRef := *owner
+	ownerRef.Controller = nil
+	obj.SetOwnerReferences(append(ownerRefs, ownerRef))
+	return nil
+}
+
+// setManagedUID annotates a resource which may be created with the UID of the managed, which
+// allows the controller to delete it once the managed completes
+func (we *ManagedExecutor) setManagedUID(obj *unstructured.Unstructured) error {
+	owner, err := we.getManagedOwner()
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.AnnotationKeyManagedUID] = string(owner.UID)
+	obj.SetAnnotations(annotations)
+	return nil
 }
 
-// Has returns whether the provided label exists.
-func (g gjsonLabels) Has(label string) bool {
-	return gjson.GetBytes(g.json, label).Exists()
+// getManagedOwner returns the owner reference to the managed, which is the controller of the pod
+func (we *ManagedExecutor) getManagedOwner() (*metav1.OwnerReference, error) {
+	if we.managedOwner != nil {
+		return we.managedOwner, nil
+	}
+	pod, err := we.getPod()
+	if err != nil {
+		return nil, err
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, errors.InternalErrorf("pod %s has no controller", we.PodName)
+	}
+	we.managedOwner = owner
+	return owner, nil
 }
 
-// Get returns the value for the provided label.
-func (g gjsonLabels) Get(label string) string {
-	return gjson.GetBytes(g.json, label).String()
+// newResourceRef returns the reference to a resource recorded in the status of the node
+func newResourceRef(obj *unstructured.Unstructured, apiResource *metav1.APIResource) wfv1.ResourceRef {
+	return wfv1.ResourceRef{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Resource:   apiResource.Name,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        string(obj.GetUID()),
+	}
 }
 
-// WaitResource waits for a specific resource to satisfy either the success or failure condition
-func (we *ManagedExecutor) WaitResource(resourceName string) error {
-	if we.Template.Resource.SuccessCondition == "" && we.Template.Resource.FailureCondition == "" {
-		return nil
+// annotateResourceRefs annotates the pod with the references to the resources it created
+func (we *ManagedExecutor) annotateResourceRefs(refs []wfv1.ResourceRef) error {
+	refsBytes, err := json.Marshal(refs)
+	if err != nil {
+		return errors.InternalWrapError(err)
 	}
-	var successReqs labels.Requirements
-	if we.Template.Resource.SuccessCondition != "" {
-		successSelector, err := labels.Parse(we.Template.Resource.SuccessCondition)
+	return we.AddAnnotation(common.AnnotationKeyResourceRefs, string(refsBytes))
+}
+
+// readResourceManifests parses the resources of a manifest, which may contain several YAML or
+// JSON documents. Resources must be named, unless they are created with a generated name.
+func readResourceManifests(manifestPath string, allowGenerateName bool) ([]*unstructured.Unstructured, error) {
+	manifest, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	defer func() { _ = manifest.Close() }()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(manifest))
+	var objs []*unstructured.Unstructured
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return errors.Errorf(errors.CodeBadRequest, "success condition '%s' failed to parse: %v", we.Template.Resource.SuccessCondition, err)
+			return nil, errors.Errorf(errors.CodeBadRequest, "failed to read resource manifest: %v", err)
 		}
-		log.Infof("Waiting for conditions: %s", successSelector)
-		successReqs, _ = successSelector.Requirements()
+		jsonBytes, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "failed to parse resource manifest: %v", err)
+		}
+		if string(bytes.TrimSpace(jsonBytes)) == "null" {
+			// empty document, e.g. a leading separator or only comments
+			continue
+		}
+		var obj unstructured.Unstructured
+		err = obj.UnmarshalJSON(jsonBytes)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "failed to parse resource manifest: %v", err)
+		}
+		if obj.GetName() == "" && (!allowGenerateName || obj.GetGenerateName() == "") {
+			return nil, errors.Errorf(errors.CodeBadRequest, "resource manifest of kind %s has no name", obj.GetKind())
+		}
+		objs = append(objs, &obj)
 	}
+	if len(objs) == 0 {
+		return nil, errors.New(errors.CodeBadRequest, "resource manifest is empty")
+	}
+	return objs, nil
+}
 
-	var failReqs labels.Requirements
-	if we.Template.Resource.FailureCondition != "" {
-		failSelector, err := labels.Parse(we.Template.Resource.FailureCondition)
+// applyResource creates the resource, or merges the manifest into the existing resource
+func applyResource(ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	_, err := ri.Get(obj.GetName(), metav1.GetOptions{})
+	if apierr.IsNotFound(err) {
+		return ri.Create(obj)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return ri.Patch(obj.GetName(), types.MergePatchType, data)
+}
+
+// serverSideApplyResource applies the manifest on the server, which tracks the ownership of its
+// fields by the field manager. The dynamic client does not support apply patches, which are
+// sent with the REST client instead.
+func (we *ManagedExecutor) serverSideApplyResource(obj *unstructured.Unstructured, apiResource *metav1.APIResource, fieldManager string) (*unstructured.Unstructured, error) {
+	if fieldManager == "" {
+		fieldManager = defaultFieldManager
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	gv := obj.GroupVersionKind().GroupVersion()
+	path := []string{"/apis", gv.Group, gv.Version}
+	if gv.Group == "" {
+		path = []string{"/api", gv.Version}
+	}
+	if apiResource.Namespaced {
+		path = append(path, "namespaces", obj.GetNamespace())
+	}
+	path = append(path, apiResource.Name, obj.GetName())
+	body, err := we.RESTClient.Patch(applyPatchType).AbsPath(path...).Param("fieldManager", fieldManager).Body(data).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var result unstructured.Unstructured
+	err = result.UnmarshalJSON(body)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	return &result, nil
+}
+
+// patchResource patches the resource with the patch of the template, or with the manifest
+// itself, according to the merge strategy of the template
+func patchResource(ri dynamic.ResourceInterface, obj *unstructured.Unstructured, resTmpl *wfv1.ResourceTemplate) (*unstructured.Unstructured, error) {
+	var patchType types.PatchType
+	switch resTmpl.MergeStrategy {
+	case "", "strategic":
+		patchType = types.StrategicMergePatchType
+	case "merge":
+		patchType = types.MergePatchType
+	case "json":
+		patchType = types.JSONPatchType
+	default:
+		return nil, errors.Errorf(errors.CodeBadRequest, "unsupported merge strategy '%s'", resTmpl.MergeStrategy)
+	}
+	var data []byte
+	var err error
+	if resTmpl.Patch != "" {
+		data, err = yaml.YAMLToJSON([]byte(resTmpl.Patch))
 		if err != nil {
-			return errors.Errorf(errors.CodeBadRequest, "fail condition '%s' failed to parse: %v", we.Template.Resource.FailureCondition, err)
+			return nil, errors.Errorf(errors.CodeBadRequest, "failed to parse resource patch: %v", err)
+		}
+	} else if patchType == types.JSONPatchType {
+		return nil, errors.New(errors.CodeBadRequest, "json merge strategy requires a patch")
+	} else {
+		data, err = obj.MarshalJSON()
+		if err != nil {
+			return nil, err
 		}
-		log.Infof("Failing for conditions: %s", failSelector)
-		failReqs, _ = failSelector.Requirements()
 	}
+	return ri.Patch(obj.GetName(), patchType, data)
+}
 
-	// Start the condition result reader using ExponentialBackoff
-	// Exponential backoff is for steps of 0, 5, 20, 80, 320 seconds since the first step is without
-	// delay in the ExponentialBackoff
-	err := wait.ExponentialBackoff(wait.Backoff{Duration: (time.Second * 5), Factor: 4.0, Steps: 5},
-		func() (bool, error) {
-			isErrRetry, err := checkResourceState(resourceName, successReqs, failReqs)
-
-			if err == nil {
-				log.Infof("Returning from successful wait for resource %s", resourceName)
-				return true, nil
-			}
+// replaceResource replaces the existing resource with the manifest. The resource version of the
+// existing resource is used unless the manifest specifies one.
+func replaceResource(ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if obj.GetResourceVersion() == "" {
+		current, err := ri.Get(obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		obj.SetResourceVersion(current.GetResourceVersion())
+	}
+	return ri.Update(obj)
+}
 
-			if isErrRetry {
-				log.Infof("Waiting for resource %s resulted in retryable error %v", resourceName, err)
-				return false, nil
-			}
+// deleteResource deletes the resource with the propagation policy of the template and, if
+// requested, waits until it is gone or the timeout of the template, if any, expires. A resource
+// which does not exist is ignored.
+func deleteResource(ri dynamic.ResourceInterface, obj *unstructured.Unstructured, resTmpl *wfv1.ResourceTemplate) error {
+	opts := &metav1.DeleteOptions{}
+	if resTmpl.PropagationPolicy != "" {
+		policy := metav1.DeletionPropagation(resTmpl.PropagationPolicy)
+		opts.PropagationPolicy = &policy
+	}
+	err := ri.Delete(obj.GetName(), opts)
+	if apierr.IsNotFound(err) {
+		return nil
+	}
+	if err != nil || !resTmpl.WaitForDeletion {
+		return err
+	}
+	log.Infof("Waiting for deletion of %s", resourceName(obj))
+	deleted := func() (bool, error) {
+		_, err := ri.Get(obj.GetName(), metav1.GetOptions{})
+		if apierr.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if resTmpl.Timeout == "" {
+		return wait.PollImmediateInfinite(deletionPollInterval, deleted)
+	}
+	timeout, err := time.ParseDuration(resTmpl.Timeout)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "timeout '%s' failed to parse: %v", resTmpl.Timeout, err)
+	}
+	err = wait.PollImmediate(deletionPollInterval, timeout, deleted)
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf(errors.CodeTimeout, "timed out after %s waiting for deletion of %s", resTmpl.Timeout, resourceName(obj))
+	}
+	return err
+}
 
-			log.Warnf("Waiting for resource %s resulted in non-retryable error %v", resourceName, err)
-			return false, err
-		})
+// getAPIResource discovers the API resource of the kind of an object
+func (we *ManagedExecutor) getAPIResource(obj *unstructured.Unstructured) (*metav1.APIResource, error) {
+	gvk := obj.GroupVersionKind()
+	resourceList, err := we.ClientSet.Discovery().ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "failed to discover the resources of %s: %v", gvk.GroupVersion(), err)
+	}
+	for i, res := range resourceList.APIResources {
+		// skip subresources, e.g. deployments/status
+		if res.Kind == gvk.Kind && !strings.Contains(res.Name, "/") {
+			return &resourceList.APIResources[i], nil
+		}
+	}
+	return nil, errors.Errorf(errors.CodeBadRequest, "resource kind %s not found in %s", gvk.Kind, gvk.GroupVersion())
+}
 
+// getResourceInterface returns the dynamic client of the API resource of an object. The
+// namespace of a namespaced resource defaults to the namespace of the pod.
+func (we *ManagedExecutor) getResourceInterface(obj *unstructured.Unstructured, apiResource *metav1.APIResource) (dynamic.ResourceInterface, error) {
+	client, err := we.DynamicClientPool.ClientForGroupVersionKind(obj.GroupVersionKind())
 	if err != nil {
-		if err == wait.ErrWaitTimeout {
-			log.Warnf("Waiting for resource %s resulted in timeout due to repeated errors", resourceName)
-		} else {
-			log.Warnf("Waiting for resource %s resulted in error %v", err)
+		return nil, errors.InternalWrapError(err)
+	}
+	namespace := ""
+	if apiResource.Namespaced {
+		namespace = obj.GetNamespace()
+		if namespace == "" {
+			namespace = we.Namespace
+			obj.SetNamespace(namespace)
 		}
 	}
+	return client.Resource(apiResource, namespace), nil
+}
 
-	return err
+// discoverResourceInterface discovers the API resource of an object and returns its dynamic client
+func (we *ManagedExecutor) discoverResourceInterface(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	apiResource, err := we.getAPIResource(obj)
+	if err != nil {
+		return nil, err
+	}
+	return we.getResourceInterface(obj, apiResource)
 }
 
-// Function to do the kubectl get -w command and then waiting on json reading.
-func checkResourceState(resourceName string, successReqs labels.Requirements, failReqs labels.Requirements) (bool, error) {
+// resourceName returns the name of a resource in the form of kind.group/name
+func resourceName(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind = kind + "." + gvk.Group
+	}
+	return kind + "/" + obj.GetName()
+}
 
-	cmd, reader, err := startKubectlWaitCmd(resourceName)
-	if err != nil {
-		return false, err
+// resourceError classifies an error from the API server with the codes of the errors package
+func resourceError(err error) error {
+	if _, ok := err.(errors.KubextError); ok {
+		return err
 	}
-	defer func() {
-		_ = cmd.Process.Kill()
-	}()
+	code := errors.CodeInternal
+	switch {
+	case apierr.IsNotFound(err):
+		code = errors.CodeNotFound
+	case apierr.IsUnauthorized(err):
+		code = errors.CodeUnauthorized
+	case apierr.IsForbidden(err):
+		code = errors.CodeForbidden
+	case apierr.IsTimeout(err), apierr.IsServerTimeout(err):
+		code = errors.CodeTimeout
+	case apierr.IsBadRequest(err), apierr.IsInvalid(err), apierr.IsAlreadyExists(err), apierr.IsConflict(err), apierr.IsMethodNotSupported(err):
+		code = errors.CodeBadRequest
+	}
+	return errors.New(code, err.Error())
+}
 
-	for {
-		jsonBytes, err := readJSON(reader)
+// WaitResource waits for the resources to satisfy either the success or failure condition. The
+// failure condition of any resource fails the wait. The success condition must be satisfied by
+// every resource, or by any of them with the any condition aggregation. The wait fails once the
+// timeout of the template, if any, expires.
+func (we *ManagedExecutor) WaitResource(objs []*unstructured.Unstructured) error {
+	resTmpl := we.Template.Resource
+	if resTmpl.SuccessCondition == "" && resTmpl.FailureCondition == "" {
+		return nil
+	}
+	var successReqs labels.Requirements
+	if resTmpl.SuccessCondition != "" {
+		successSelector, err := labels.Parse(resTmpl.SuccessCondition)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "success condition '%s' failed to parse: %v", resTmpl.SuccessCondition, err)
+		}
+		log.Infof("Waiting for conditions: %s", successSelector)
+		successReqs, _ = successSelector.Requirements()
+	}
 
+	var failReqs labels.Requirements
+	if resTmpl.FailureCondition != "" {
+		failSelector, err := labels.Parse(resTmpl.FailureCondition)
 		if err != nil {
-			resultErr := err
-			log.Warnf("Json reader returned error %v. Calling kill (usually superfluous)", err)
-			// We don't want to write OS specific code so we don't want to call syscall package code. But that means
-			// there is no way to figure out if a process is running or not in an asynchronous manner. exec.Wait will
-			// always block and we need to call that to get the exit code of the process. So we will unconditionally
-			// call exec.Process.Kill and then assume that wait will not block after that. Two things may happen:
-			// 1. Process already exited and kill does nothing (returns error which we ignore) and then we call
-			//    Wait and get the proper return value
-			// 2. Process is running gets, killed with exec.Process.Kill call and Wait returns an error code and we give up
-			//    and don't retry
-			_ = cmd.Process.Kill()
+			return errors.Errorf(errors.CodeBadRequest, "fail condition '%s' failed to parse: %v", resTmpl.FailureCondition, err)
+		}
+		log.Infof("Failing for conditions: %s", failSelector)
+		failReqs, _ = failSelector.Requirements()
+	}
 
-			log.Warnf("Command for kubectl get -w for %s exited. Getting return value using Wait", resourceName)
-			err = cmd.Wait()
-			if err != nil {
-				log.Warnf("cmd.Wait for kubectl get -w command for resource %s returned error %v",
-					resourceName, err)
-				resultErr = err
-			} else {
-				log.Infof("readJSon failed for resource %s but cmd.Wait for kubectl get -w command did not error", resourceName)
-			}
-			return true, resultErr
+	var timeout <-chan time.Time
+	if resTmpl.Timeout != "" {
+		duration, err := time.ParseDuration(resTmpl.Timeout)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "timeout '%s' failed to parse: %v", resTmpl.Timeout, err)
 		}
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
-		log.Info(string(jsonBytes))
-		ls := gjsonLabels{json: jsonBytes}
-		for _, req := range failReqs {
-			failed := req.Matches(ls)
-			msg := fmt.Sprintf("failure condition '%s' evaluated %v", req, failed)
-			log.Infof(msg)
-			if failed {
-				// TODO: need a better error code instead of BadRequest
-				return false, errors.Errorf(errors.CodeBadRequest, msg)
-			}
+	type waitResult struct {
+		name string
+		err  error
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	results := make(chan waitResult, len(objs))
+	pending := make(map[string]bool)
+	for _, obj := range objs {
+		ri, err := we.discoverResourceInterface(obj)
+		if err != nil {
+			return err
 		}
-		numMatched := 0
-		for _, req := range successReqs {
-			matched := req.Matches(ls)
-			log.Infof("success condition '%s' evaluated %v", req, matched)
-			if matched {
-				numMatched++
+		name := resourceName(obj)
+		pending[name] = true
+		go func(objName string) {
+			results <- waitResult{name: name, err: waitResourceState(ri, objName, name, successReqs, failReqs, stop)}
+		}(obj.GetName())
+	}
+	for len(pending) > 0 {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				log.Warnf("Waiting for resource %s resulted in error %v", result.name, result.err)
+				return result.err
 			}
-		}
-		log.Infof("%d/%d success conditions matched", numMatched, len(successReqs))
-		if numMatched >= len(successReqs) {
-			return false, nil
+			log.Infof("Resource %s met the success condition", result.name)
+			delete(pending, result.name)
+			if resTmpl.ConditionAggregation == "any" {
+				return nil
+			}
+		case <-timeout:
+			var names []string
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return errors.Errorf(errors.CodeTimeout, "timed out after %s waiting for %s: success condition '%s' not met", resTmpl.Timeout, strings.Join(names, ", "), resTmpl.SuccessCondition)
 		}
 	}
+	log.Infof("Returning from successful wait for resources")
+	return nil
 }
 
-// Start Kubectl command Get with -w return error if unable to start command
-func startKubectlWaitCmd(resourceName string) (*exec.Cmd, *bufio.Reader, error) {
-	cmd := exec.Command("kubectl", "get", resourceName, "-w", "-o", "json")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, nil, errors.InternalWrapError(err)
-	}
-	reader := bufio.NewReader(stdout)
-	log.Info(strings.Join(cmd.Args, " "))
-	if err := cmd.Start(); err != nil {
-		return nil, nil, errors.InternalWrapError(err)
+// waitResourceState waits for a resource to satisfy either the success or failure condition, or
+// until stop is closed
+func waitResourceState(ri dynamic.ResourceInterface, objName string, name string, successReqs labels.Requirements, failReqs labels.Requirements, stop <-chan struct{}) error {
+	// Watch the resource, retrying errors using ExponentialBackoff. Exponential backoff is for
+	// steps of 0, 5, 20, 80, 320 seconds since the first step is without delay in the
+	// ExponentialBackoff. A watch closed by the API server is re-established immediately.
+	err := wait.ExponentialBackoff(wait.Backoff{Duration: (time.Second * 5), Factor: 4.0, Steps: 5},
+		func() (bool, error) {
+			for {
+				done, err := checkResourceState(ri, objName, name, successReqs, failReqs, stop)
+				if done {
+					return true, err
+				}
+				if err != nil {
+					log.Infof("Waiting for resource %s resulted in retryable error %v", name, err)
+					return false, nil
+				}
+				log.Infof("Watch of resource %s closed. Watching again", name)
+			}
+		})
+	if err == wait.ErrWaitTimeout {
+		log.Warnf("Waiting for resource %s resulted in timeout due to repeated errors", name)
+		return errors.Errorf(errors.CodeTimeout, "waiting for resource %s timed out due to repeated errors", name)
 	}
-
-	return cmd, reader, nil
+	return err
 }
 
-// readJSON reads from a reader line-by-line until it reaches "}\n" indicating end of json
-func readJSON(reader *bufio.Reader) ([]byte, error) {
-	var buffer bytes.Buffer
+// checkResourceState watches the resource and evaluates the conditions against every version
+// of it. It returns true once a condition is decided or stop is closed, or false with an
+// optional error when the watch was closed before.
+func checkResourceState(ri dynamic.ResourceInterface, objName string, name string, successReqs labels.Requirements, failReqs labels.Requirements, stop <-chan struct{}) (bool, error) {
+	w, err := ri.Watch(metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", objName).String()})
+	if err != nil {
+		return false, resourceError(err)
+	}
+	defer w.Stop()
 	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			return nil, err
+		var event watch.Event
+		var ok bool
+		select {
+		case <-stop:
+			return true, nil
+		case event, ok = <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+		}
+		switch event.Type {
+		case watch.Error:
+			return false, resourceError(apierr.FromObject(event.Object))
+		case watch.Deleted:
+			return true, errors.Errorf(errors.CodeNotFound, "resource %s was deleted", name)
 		}
-		isDelimiter := len(line) == 2 && line[0] == byte('}')
-		line = bytes.TrimSpace(line)
-		_, err = buffer.Write(line)
+		obj, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			return false, errors.InternalErrorf("unexpected object in watch of resource %s: %T", name, event.Object)
+		}
+		jsonBytes, err := obj.MarshalJSON()
 		if err != nil {
-			return nil, err
+			return false, errors.InternalWrapError(err)
 		}
-		if isDelimiter {
-			break
+		done, err := evaluateResourceConditions(name, jsonBytes, successReqs, failReqs)
+		if done {
+			return true, err
 		}
 	}
-	return buffer.Bytes(), nil
 }
 
-// SaveResourceParameters will save any resource output parameters
-func (we *ManagedExecutor) SaveResourceParameters(resourceName string) error {
+// evaluateResourceConditions returns true if any failure condition matches the resource, with
+// an error, or if all the success conditions match it
+func evaluateResourceConditions(name string, jsonBytes []byte, successReqs labels.Requirements, failReqs labels.Requirements) (bool, error) {
+	log.Info(string(jsonBytes))
+	ls := common.GJSONLabels{JSON: jsonBytes}
+	for _, req := range failReqs {
+		failed := req.Matches(ls)
+		msg := fmt.Sprintf("resource %s failure condition '%s' evaluated %v", name, req, failed)
+		log.Info(msg)
+		if failed {
+			// TODO: need a better error code instead of BadRequest
+			return true, errors.New(errors.CodeBadRequest, msg)
+		}
+	}
+	numMatched := 0
+	for _, req := range successReqs {
+		matched := req.Matches(ls)
+		log.Infof("resource %s success condition '%s' evaluated %v", name, req, matched)
+		if matched {
+			numMatched++
+		}
+	}
+	log.Infof("%d/%d success conditions matched", numMatched, len(successReqs))
+	return numMatched >= len(successReqs), nil
+}
+
+// SaveResourceParameters saves the names of the resources, as a JSON list, as the result and
+// any resource output parameters. The parameters of several resources are evaluated against a
+// list of them, as with kubectl.
+func (we *ManagedExecutor) SaveResourceParameters(objs []*unstructured.Unstructured) error {
+	names := make([]string, len(objs))
+	for i, obj := range objs {
+		names[i] = resourceName(obj)
+	}
+	namesBytes, err := json.Marshal(names)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	result := string(namesBytes)
+	we.Template.Outputs.Result = &result
 	if len(we.Template.Outputs.Parameters) == 0 {
 		log.Infof("No output parameters")
-		return nil
+		return we.AnnotateOutputs()
 	}
 	log.Infof("Saving resource output parameters")
+	var items []interface{}
+	for _, obj := range objs {
+		ri, err := we.discoverResourceInterface(obj)
+		if err != nil {
+			return err
+		}
+		current, err := ri.Get(obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return resourceError(err)
+		}
+		items = append(items, current.Object)
+	}
+	input := items[0].(map[string]interface{})
+	if len(items) > 1 {
+		input = map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items}
+	}
 	for i, param := range we.Template.Outputs.Parameters {
 		if param.ValueFrom == nil {
 			continue
 		}
-		var cmd *exec.Cmd
+		var output string
 		if param.ValueFrom.JSONPath != "" {
-			cmd = exec.Command("kubectl", "get", resourceName, "-o", fmt.Sprintf("jsonpath='%s'", param.ValueFrom.JSONPath))
+			output, err = evaluateJSONPath(input, param.ValueFrom.JSONPath)
 		} else if param.ValueFrom.JQFilter != "" {
-			cmdStr := fmt.Sprintf("kubectl get %s -o json | jq -c '%s'", resourceName, param.ValueFrom.JQFilter)
-			cmd = exec.Command("sh", "-c", cmdStr)
+			output, err = evaluateJQFilter(input, param.ValueFrom.JQFilter)
 		} else {
 			continue
 		}
-		log.Info(cmd.Args)
-		out, err := cmd.Output()
 		if err != nil {
-			if exErr, ok := err.(*exec.ExitError); ok {
-				log.Errorf("`%s` stderr:\n%s", cmd.Args, string(exErr.Stderr))
-			}
-			return errors.InternalWrapError(err)
+			return err
 		}
-		output := string(out)
 		we.Template.Outputs.Parameters[i].Value = &output
 		log.Infof("Saved output parameter: %s, value: %s", param.Name, output)
 	}
-	err := we.AnnotateOutputs()
+	return we.AnnotateOutputs()
+}
+
+// evaluateJSONPath evaluates a JSONPath expression, e.g. {.status.phase}, against an object.
+// The braces are optional, as with kubectl.
+func evaluateJSONPath(obj map[string]interface{}, expr string) (string, error) {
+	template := expr
+	if !strings.HasPrefix(template, "{") {
+		if !strings.HasPrefix(template, ".") {
+			template = "." + template
+		}
+		template = "{" + template + "}"
+	}
+	j := jsonpath.New("output")
+	err := j.Parse(template)
 	if err != nil {
-		return err
+		return "", errors.Errorf(errors.CodeBadRequest, "jsonPath '%s' failed to parse: %v", expr, err)
 	}
-	return nil
+	var buf bytes.Buffer
+	err = j.Execute(&buf, obj)
+	if err != nil {
+		return "", errors.Errorf(errors.CodeBadRequest, "jsonPath '%s' failed: %v", expr, err)
+	}
+	return buf.String(), nil
 }
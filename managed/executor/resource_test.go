@@ -0,0 +1,367 @@
+package executor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbrette/kubext/errors"
+	"github.com/jbrette/kubext/managed/common"
+	wfv1 "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	fakerest "k8s.io/client-go/rest/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var widgetManifest = `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+spec:
+  size: 3
+`
+
+func newFakeWidget(phase string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "my-widget", "namespace": fakeNamespace},
+		"spec":       map[string]interface{}{"size": int64(3)},
+		"status":     map[string]interface{}{"phase": phase, "replicas": []interface{}{"a", "b"}},
+	}}
+}
+
+// newResourceExecutor returns an executor whose dynamic client pool serves the widgets of example.com/v1
+func newResourceExecutor(tmpl wfv1.Template) (*ManagedExecutor, *fakedynamic.FakeClientPool) {
+	pod := newFakePod()
+	pod.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(&wfv1.Managed{ObjectMeta: metav1.ObjectMeta{Name: "my-managed", UID: "managed-uid"}}, wfv1.SchemaGroupVersionKind),
+	}
+	clientset := fake.NewSimpleClientset(pod)
+	// the fake discovery does not share the fake of the clientset
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "widgets/status", Kind: "Widget", Namespaced: true},
+			{Name: "widgets", Kind: "Widget", Namespaced: true},
+		},
+	}}
+	pool := &fakedynamic.FakeClientPool{}
+	we := NewExecutor(clientset, fakePodName, fakeNamespace, fakeAnnotations, nil)
+	we.Template = tmpl
+	we.DynamicClientPool = pool
+	return &we, pool
+}
+
+func writeManifest(t *testing.T, manifest string) string {
+	f, err := ioutil.TempFile("", "manifest")
+	assert.NoError(t, err)
+	_, err = f.WriteString(manifest)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	return f.Name()
+}
+
+// TestExecResource verifies resource actions are performed with the dynamic client in the namespace of the pod
+func TestExecResource(t *testing.T) {
+	manifestPath := writeManifest(t, widgetManifest)
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, pool := newResourceExecutor(wfv1.Template{})
+
+	pool.AddReactor("create", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		assert.Equal(t, fakeNamespace, action.GetNamespace())
+		return true, action.(k8stesting.CreateAction).GetObject(), nil
+	})
+	objs, err := we.ExecResource("create", manifestPath)
+	assert.NoError(t, err)
+	if assert.Len(t, objs, 1) {
+		assert.Equal(t, "widget.example.com/my-widget", resourceName(objs[0]))
+		assert.Equal(t, fakeNamespace, objs[0].GetNamespace())
+		assert.Equal(t, "managed-uid", objs[0].GetAnnotations()[common.AnnotationKeyManagedUID])
+	}
+
+	// apply patches the existing resource
+	pool.AddReactor("get", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, newFakeWidget("Running"), nil
+	})
+	pool.AddReactor("patch", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		assert.Contains(t, string(action.(k8stesting.PatchAction).GetPatch()), `"size":3`)
+		return true, newFakeWidget("Running"), nil
+	})
+	_, err = we.ExecResource("apply", manifestPath)
+	assert.NoError(t, err)
+
+	// errors from the API server are classified
+	pool.PrependReactor("delete", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierr.NewForbidden(schema.GroupResource{Group: "example.com", Resource: "widgets"}, "my-widget", nil)
+	})
+	_, err = we.ExecResource("delete", manifestPath)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.CodeForbidden, err.(errors.KubextError).Code())
+	}
+
+	_, err = we.ExecResource("update", manifestPath)
+	assert.Error(t, err)
+}
+
+// TestPatchReplaceDeleteResource verifies the options of the patch, replace and delete actions
+func TestPatchReplaceDeleteResource(t *testing.T) {
+	manifestPath := writeManifest(t, widgetManifest)
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{
+			MergeStrategy:     "json",
+			Patch:             "- op: replace\n  path: /spec/size\n  value: 5\n",
+			PropagationPolicy: "Foreground",
+			WaitForDeletion:   true,
+		},
+	})
+	deletionPollInterval = time.Millisecond
+
+	pool.AddReactor("patch", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(k8stesting.PatchAction)
+		assert.Equal(t, `[{"op":"replace","path":"/spec/size","value":5}]`, string(patchAction.GetPatch()))
+		return true, newFakeWidget("Running"), nil
+	})
+	_, err := we.ExecResource("patch", manifestPath)
+	assert.NoError(t, err)
+
+	// replace uses the resource version of the existing resource
+	gets := 0
+	pool.AddReactor("get", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		if gets > 2 {
+			return true, nil, apierr.NewNotFound(schema.GroupResource{Group: "example.com", Resource: "widgets"}, "my-widget")
+		}
+		widget := newFakeWidget("Running")
+		widget.SetResourceVersion("42")
+		return true, widget, nil
+	})
+	pool.AddReactor("update", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.UpdateAction).GetObject().(*unstructured.Unstructured)
+		assert.Equal(t, "42", obj.GetResourceVersion())
+		return true, obj, nil
+	})
+	_, err = we.ExecResource("replace", manifestPath)
+	assert.NoError(t, err)
+
+	// delete waits until the resource is gone
+	pool.AddReactor("delete", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	_, err = we.ExecResource("delete", manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, gets)
+}
+
+// TestDeleteResourceTimeout verifies waiting for the deletion of a resource fails once the timeout expires
+func TestDeleteResourceTimeout(t *testing.T) {
+	manifestPath := writeManifest(t, widgetManifest)
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{WaitForDeletion: true, Timeout: "50ms"},
+	})
+	deletionPollInterval = time.Millisecond
+
+	pool.AddReactor("delete", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	pool.AddReactor("get", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, newFakeWidget("Terminating"), nil
+	})
+	_, err := we.ExecResource("delete", manifestPath)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.CodeTimeout, err.(errors.KubextError).Code())
+		assert.Equal(t, "timed out after 50ms waiting for deletion of widget.example.com/my-widget", err.Error())
+	}
+}
+
+// TestExecResourceMultipleDocuments verifies every resource of a multi-document manifest is created
+func TestExecResourceMultipleDocuments(t *testing.T) {
+	manifestPath := writeManifest(t, "---\n"+widgetManifest+"---\n# no resource\n---\n"+strings.Replace(widgetManifest, "name: my-widget", "generateName: widget-", 1))
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, pool := newResourceExecutor(wfv1.Template{Resource: &wfv1.ResourceTemplate{}})
+	pool.AddReactor("create", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "x1y2z")
+		}
+		return true, obj, nil
+	})
+	objs, err := we.ExecResource("create", manifestPath)
+	assert.NoError(t, err)
+	err = we.SaveResourceParameters(objs)
+	assert.NoError(t, err)
+	assert.Equal(t, `["widget.example.com/my-widget","widget.example.com/widget-x1y2z"]`, *we.Template.Outputs.Result)
+
+	// resources which are not created must be named
+	_, err = we.ExecResource("apply", manifestPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no name")
+	}
+}
+
+// TestServerSideApplyResource verifies server-side apply sends an apply patch with the field manager
+func TestServerSideApplyResource(t *testing.T) {
+	manifestPath := writeManifest(t, widgetManifest)
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, _ := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{FieldManager: "deployer"},
+	})
+	we.RESTClient = &fakerest.RESTClient{
+		NegotiatedSerializer: dynamic.ContentConfig().NegotiatedSerializer,
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPatch, req.Method)
+			assert.Equal(t, "/apis/example.com/v1/namespaces/default/widgets/my-widget", req.URL.Path)
+			assert.Equal(t, "deployer", req.URL.Query().Get("fieldManager"))
+			assert.Equal(t, "application/apply-patch+yaml", req.Header.Get("Content-Type"))
+			body, err := newFakeWidget("Running").MarshalJSON()
+			assert.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+	objs, err := we.ExecResource("server-side-apply", manifestPath)
+	assert.NoError(t, err)
+	status, _, _ := unstructured.NestedString(objs[0].Object, "status", "phase")
+	assert.Equal(t, "Running", status)
+}
+
+// TestWaitResource verifies the conditions are evaluated against the watched resource
+func TestWaitResource(t *testing.T) {
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{
+			SuccessCondition: "status.phase == Succeeded",
+			FailureCondition: "status.phase in (Failed, Error)",
+		},
+	})
+	watcher := watch.NewFake()
+	pool.AddWatchReactor("widgets", k8stesting.DefaultWatchReactor(watcher, nil))
+	go func() {
+		watcher.Add(newFakeWidget("Running"))
+		watcher.Modify(newFakeWidget("Succeeded"))
+	}()
+	err := we.WaitResource([]*unstructured.Unstructured{newFakeWidget("")})
+	assert.NoError(t, err)
+
+	watcher = watch.NewFake()
+	pool.PrependWatchReactor("widgets", k8stesting.DefaultWatchReactor(watcher, nil))
+	go watcher.Add(newFakeWidget("Failed"))
+	err = we.WaitResource([]*unstructured.Unstructured{newFakeWidget("")})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failure condition")
+	}
+}
+
+// TestWaitResourcesTimeout verifies the success condition is aggregated over several resources
+// and the wait fails once the timeout expires, naming the resources which did not meet it
+func TestWaitResourcesTimeout(t *testing.T) {
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{
+			SuccessCondition: "status.phase == Succeeded",
+			Timeout:          "100ms",
+		},
+	})
+	pool.AddWatchReactor("widgets", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		name, _ := action.(k8stesting.WatchAction).GetWatchRestrictions().Fields.RequiresExactMatch("metadata.name")
+		watcher := watch.NewFakeWithChanSize(1, false)
+		widget := newFakeWidget("Running")
+		if name == "done" {
+			widget.Object["status"] = map[string]interface{}{"phase": "Succeeded"}
+		}
+		widget.SetName(name)
+		watcher.Add(widget)
+		return true, watcher, nil
+	})
+	newWidgets := func() []*unstructured.Unstructured {
+		done, running := newFakeWidget(""), newFakeWidget("")
+		done.SetName("done")
+		running.SetName("running")
+		return []*unstructured.Unstructured{done, running}
+	}
+	err := we.WaitResource(newWidgets())
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.CodeTimeout, err.(errors.KubextError).Code())
+		assert.Equal(t, "timed out after 100ms waiting for widget.example.com/running: success condition 'status.phase == Succeeded' not met", err.Error())
+	}
+
+	we.Template.Resource.ConditionAggregation = "any"
+	err = we.WaitResource(newWidgets())
+	assert.NoError(t, err)
+}
+
+// TestSaveResourceParameters verifies output parameters are evaluated natively with JSONPath and jq
+func TestSaveResourceParameters(t *testing.T) {
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{},
+		Outputs: wfv1.Outputs{
+			Parameters: []wfv1.Parameter{
+				{Name: "phase", ValueFrom: &wfv1.ValueFrom{JSONPath: "{.status.phase}"}},
+				{Name: "size", ValueFrom: &wfv1.ValueFrom{JSONPath: ".spec.size"}},
+				{Name: "replicas", ValueFrom: &wfv1.ValueFrom{JQFilter: ".status.replicas"}},
+				{Name: "each", ValueFrom: &wfv1.ValueFrom{JQFilter: ".status.replicas[]"}},
+			},
+		},
+	})
+	pool.AddReactor("get", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, newFakeWidget("Running"), nil
+	})
+	err := we.SaveResourceParameters([]*unstructured.Unstructured{newFakeWidget("")})
+	assert.NoError(t, err)
+	assert.Equal(t, `["widget.example.com/my-widget"]`, *we.Template.Outputs.Result)
+	params := we.Template.Outputs.Parameters
+	assert.Equal(t, "Running", *params[0].Value)
+	assert.Equal(t, "3", *params[1].Value)
+	assert.Equal(t, `["a","b"]`, *params[2].Value)
+	assert.Equal(t, "\"a\"\n\"b\"", *params[3].Value)
+}
+
+// TestSetOwnerReference verifies created resources are owned by the managed and recorded on the pod
+func TestSetOwnerReference(t *testing.T) {
+	manifestPath := writeManifest(t, widgetManifest)
+	defer func() { _ = os.Remove(manifestPath) }()
+	we, pool := newResourceExecutor(wfv1.Template{
+		Resource: &wfv1.ResourceTemplate{SetOwnerReference: true},
+	})
+	pool.AddReactor("create", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		obj.SetUID("widget-uid")
+		return true, obj, nil
+	})
+	objs, err := we.ExecResource("create", manifestPath)
+	assert.NoError(t, err)
+	if assert.Len(t, objs[0].GetOwnerReferences(), 1) {
+		ownerRef := objs[0].GetOwnerReferences()[0]
+		assert.Equal(t, "my-managed", ownerRef.Name)
+		assert.Nil(t, ownerRef.Controller)
+	}
+	// the fake clientset does not apply patches, so the annotation is found in the patch of the pod
+	var podPatch string
+	for _, action := range we.ClientSet.(*fake.Clientset).Actions() {
+		if patchAction, ok := action.(k8stesting.PatchAction); ok {
+			podPatch = string(patchAction.GetPatch())
+		}
+	}
+	assert.Contains(t, podPatch, common.AnnotationKeyResourceRefs)
+	assert.Contains(t, podPatch, `\"resource\":\"widgets\",\"namespace\":\"default\",\"name\":\"my-widget\",\"uid\":\"widget-uid\"`)
+
+	// resources in other namespaces cannot be owned by the managed
+	otherNamespacePath := writeManifest(t, strings.Replace(widgetManifest, "name: my-widget", "name: my-widget\n  namespace: other", 1))
+	defer func() { _ = os.Remove(otherNamespacePath) }()
+	_, err = we.ExecResource("create", otherNamespacePath)
+	assert.Error(t, err)
+}
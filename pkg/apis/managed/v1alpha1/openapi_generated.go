@@ -11,6 +11,35 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArchiveStrategy": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "ArchiveStrategy describes how to archive the file or directory of an artifact",
+					Properties: map[string]spec.Schema{
+						"tar": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Tar archives the file or directory in a gzipped tarball",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.TarStrategy"),
+							},
+						},
+						"none": {
+							SchemaProps: spec.SchemaProps{
+								Description: "None stores a single file as is, so that it can be read by other tools",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.NoneStrategy"),
+							},
+						},
+						"zstd": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Zstd archives the file or directory in a tarball compressed with zstd",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ZstdStrategy"),
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.NoneStrategy", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.TarStrategy", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ZstdStrategy"},
+		},
 		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
@@ -111,6 +140,18 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact"),
 							},
 						},
+						"gcs": {
+							SchemaProps: spec.SchemaProps{
+								Description: "GCS contains GCS artifact location details",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSArtifact"),
+							},
+						},
+						"azure": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Azure contains Azure Blob Storage artifact location details",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureArtifact"),
+							},
+						},
 						"globalName": {
 							SchemaProps: spec.SchemaProps{
 								Description: "GlobalName exports an output artifact to the global scope, making it available as '{{managed.outputs.artifacts.XXXX}} and in managed.status.outputs.artifacts",
@@ -118,12 +159,18 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Format:      "",
 							},
 						},
+						"archive": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Archive controls how the file or directory of an output artifact is archived when it is saved, and how an input artifact is extracted when it is loaded. Output artifacts record the archive they were saved with. Defaults to a gzipped tarball.",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArchiveStrategy"),
+							},
+						},
 					},
 					Required: []string{"name"},
 				},
 			},
 			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactoryArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.S3Artifact"},
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArchiveStrategy", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactoryArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.S3Artifact"},
 		},
 		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactLocation": {
 			Schema: spec.Schema{
@@ -160,11 +207,23 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact"),
 							},
 						},
+						"gcs": {
+							SchemaProps: spec.SchemaProps{
+								Description: "GCS contains GCS artifact location details",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSArtifact"),
+							},
+						},
+						"azure": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Azure contains Azure Blob Storage artifact location details",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureArtifact"),
+							},
+						},
 					},
 				},
 			},
 			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactoryArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.S3Artifact"},
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactoryArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RawArtifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.S3Artifact"},
 		},
 		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactoryArtifact": {
 			Schema: spec.Schema{
@@ -220,266 +279,819 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 			Dependencies: []string{
 				"k8s.io/api/core/v1.SecretKeySelector"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask": {
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureArtifact": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
-					Description: "DAGTask represents a node in the graph during DAG execution",
+					Description: "AzureArtifact is the location of an Azure Blob Storage artifact",
 					Properties: map[string]spec.Schema{
-						"name": {
+						"endpoint": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Name is the name of the target",
+								Description: "Endpoint is the URL of the blob service of the account. Defaults to https://<account>.blob.core.windows.net. Set it to the URL of an emulator such as Azurite, e.g. http://127.0.0.1:10000/devstoreaccount1.",
 								Type:        []string{"string"},
 								Format:      "",
 							},
 						},
-						"template": {
+						"account": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Name of template to execute",
+								Description: "Account is the name of the storage account",
 								Type:        []string{"string"},
 								Format:      "",
 							},
 						},
-						"arguments": {
+						"container": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Arguments are the parameter and artifact arguments to the template",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments"),
+								Description: "Container is the name of the container",
+								Type:        []string{"string"},
+								Format:      "",
 							},
 						},
-						"dependencies": {
+						"accountKeySecret": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Dependencies are name of other targets which this depends on",
-								Type:        []string{"array"},
-								Items: &spec.SchemaOrArray{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Type:   []string{"string"},
-											Format: "",
-										},
-									},
-								},
+								Description: "AccountKeySecret is the secret selector to the access key of the storage account",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 							},
 						},
-					},
-					Required: []string{"name", "template"},
-				},
-			},
-			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments"},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "DAGTemplate is a template subtype for directed acyclic graph templates",
-					Properties: map[string]spec.Schema{
-						"target": {
+						"sasTokenSecret": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Target are one or more names of targets to execute in a DAG",
-								Type:        []string{"string"},
-								Format:      "",
+								Description: "SASTokenSecret is the secret selector to a shared access signature token granting access to the container",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 							},
 						},
-						"tasks": {
+						"blob": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Tasks are a list of DAG tasks",
-								Type:        []string{"array"},
-								Items: &spec.SchemaOrArray{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask"),
-										},
-									},
-								},
+								Description: "Blob is the name of the blob in the container where the artifact resides",
+								Type:        []string{"string"},
+								Format:      "",
 							},
 						},
 					},
-					Required: []string{"tasks"},
+					Required: []string{"account", "container", "blob"},
 				},
 			},
 			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask"},
+				"k8s.io/api/core/v1.SecretKeySelector"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact": {
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.AzureContainer": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
-					Description: "GitArtifact is the location of an git artifact",
+					Description: "AzureContainer contains the access information required for interfacing with an Azure Blob Storage container",
 					Properties: map[string]spec.Schema{
-						"repo": {
+						"endpoint": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Repo is the git repository",
+								Description: "Endpoint is the URL of the blob service of the account. Defaults to https://<account>.blob.core.windows.net. Set it to the URL of an emulator such as Azurite, e.g. http://127.0.0.1:10000/devstoreaccount1.",
 								Type:        []string{"string"},
 								Format:      "",
 							},
 						},
-						"revision": {
+						"account": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Revision is the git commit, tag, branch to checkout",
+								Description: "Account is the name of the storage account",
 								Type:        []string{"string"},
 								Format:      "",
 							},
 						},
-						"usernameSecret": {
+						"container": {
 							SchemaProps: spec.SchemaProps{
-								Description: "UsernameSecret is the secret selector to the repository username",
+								Description: "Container is the name of the container",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"accountKeySecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "AccountKeySecret is the secret selector to the access key of the storage account",
 								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 							},
 						},
-						"passwordSecret": {
+						"sasTokenSecret": {
 							SchemaProps: spec.SchemaProps{
-								Description: "PasswordSecret is the secret selector to the repository password",
+								Description: "SASTokenSecret is the secret selector to a shared access signature token granting access to the container",
 								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 							},
 						},
 					},
-					Required: []string{"repo"},
+					Required: []string{"account", "container"},
 				},
 			},
 			Dependencies: []string{
 				"k8s.io/api/core/v1.SecretKeySelector"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact": {
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerNode": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
-					Description: "HTTPArtifact allows an file served on HTTP to be placed as an input artifact in a container",
+					Description: "ContainerNode is a container of a container set",
 					Properties: map[string]spec.Schema{
-						"url": {
+						"name": {
 							SchemaProps: spec.SchemaProps{
-								Description: "URL of the artifact",
+								Description: "Name of the container specified as a DNS_LABEL. Each container in a pod must have a unique name (DNS_LABEL). Cannot be updated.",
 								Type:        []string{"string"},
 								Format:      "",
 							},
 						},
-					},
-					Required: []string{"url"},
-				},
-			},
-			Dependencies: []string{},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "Inputs are the mechanism for passing parameters, artifacts, volumes from one template to another",
-					Properties: map[string]spec.Schema{
-						"parameters": {
+						"image": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Parameters are a list of parameters passed as inputs",
+								Description: "Docker image name. More info: https://kubernetes.io/docs/concepts/containers/images This field is optional to allow higher level config management to default or override container images in workload controllers like Deployments and StatefulSets.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"command": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Entrypoint array. Not executed within a shell. The docker image's ENTRYPOINT is used if this is not provided. Variable references $(VAR_NAME) are expanded using the container's environment. If a variable cannot be resolved, the reference in the input string will be unchanged. The $(VAR_NAME) syntax can be escaped with a double $$, ie: $$(VAR_NAME). Escaped references will never be expanded, regardless of whether the variable exists or not. Cannot be updated. More info: https://kubernetes.io/docs/tasks/inject-data-application/define-command-argument-container/#running-a-command-in-a-shell",
 								Type:        []string{"array"},
 								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"),
+											Type:   []string{"string"},
+											Format: "",
 										},
 									},
 								},
 							},
 						},
-						"artifacts": {
+						"args": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Artifact are a list of artifacts passed as inputs",
+								Description: "Arguments to the entrypoint. The docker image's CMD is used if this is not provided. Variable references $(VAR_NAME) are expanded using the container's environment. If a variable cannot be resolved, the reference in the input string will be unchanged. The $(VAR_NAME) syntax can be escaped with a double $$, ie: $$(VAR_NAME). Escaped references will never be expanded, regardless of whether the variable exists or not. Cannot be updated. More info: https://kubernetes.io/docs/tasks/inject-data-application/define-command-argument-container/#running-a-command-in-a-shell",
 								Type:        []string{"array"},
 								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact"),
+											Type:   []string{"string"},
+											Format: "",
 										},
 									},
 								},
 							},
 						},
-					},
-				},
-			},
-			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Item": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Type:   Item{}.OpenAPISchemaType(),
-					Format: Item{}.OpenAPISchemaFormat(),
-				},
-			},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "Pod metdata",
-					Properties: map[string]spec.Schema{
-						"annotations": {
+						"workingDir": {
 							SchemaProps: spec.SchemaProps{
-								Type: []string{"object"},
-								AdditionalProperties: &spec.SchemaOrBool{
+								Description: "Container's working directory. If not specified, the container runtime's default will be used, which might be configured in the container image. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"ports": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "containerPort",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "List of ports to expose from the container. Exposing a port here gives the system additional information about the network connections a container uses, but is primarily informational. Not specifying a port here DOES NOT prevent that port from being exposed. Any port which is listening on the default \"0.0.0.0\" address inside a container will be accessible from the network. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Type:   []string{"string"},
-											Format: "",
+											Ref: ref("k8s.io/api/core/v1.ContainerPort"),
 										},
 									},
 								},
 							},
 						},
-						"labels": {
+						"envFrom": {
 							SchemaProps: spec.SchemaProps{
-								Type: []string{"object"},
-								AdditionalProperties: &spec.SchemaOrBool{
+								Description: "List of sources to populate environment variables in the container. The keys defined within a source must be a C_IDENTIFIER. All invalid keys will be reported as an event when the container is starting. When a key exists in multiple sources, the value associated with the last source will take precedence. Values defined by an Env with a duplicate key will take precedence. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Type:   []string{"string"},
-											Format: "",
+											Ref: ref("k8s.io/api/core/v1.EnvFromSource"),
 										},
 									},
 								},
 							},
 						},
-					},
-				},
-			},
-			Dependencies: []string{},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "Outputs hold parameters, artifacts, and results from a step",
-					Properties: map[string]spec.Schema{
-						"parameters": {
+						"env": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "name",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
 							SchemaProps: spec.SchemaProps{
-								Description: "Parameters holds the list of output parameters produced by a step",
+								Description: "List of environment variables to set in the container. Cannot be updated.",
 								Type:        []string{"array"},
 								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"),
+											Ref: ref("k8s.io/api/core/v1.EnvVar"),
 										},
 									},
 								},
 							},
 						},
-						"artifacts": {
+						"resources": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Artifacts holds the list of output artifacts produced by a step",
+								Description: "Compute Resources required by this container. Cannot be updated. More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes#resources",
+								Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+							},
+						},
+						"volumeMounts": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "mountPath",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "Pod volumes to mount into the container's filesystem. Cannot be updated.",
 								Type:        []string{"array"},
 								Items: &spec.SchemaOrArray{
 									Schema: &spec.Schema{
 										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact"),
+											Ref: ref("k8s.io/api/core/v1.VolumeMount"),
 										},
 									},
 								},
 							},
 						},
-						"result": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Result holds the result (stdout) of a script template",
-								Type:        []string{"string"},
-								Format:      "",
+						"volumeDevices": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "devicePath",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
 							},
-						},
-					},
-				},
-			},
-			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter": {
+							SchemaProps: spec.SchemaProps{
+								Description: "volumeDevices is the list of block devices to be used by the container. This is an alpha feature and may change in the future.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.VolumeDevice"),
+										},
+									},
+								},
+							},
+						},
+						"livenessProbe": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Periodic probe of container liveness. Container will be restarted if the probe fails. Cannot be updated. More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes",
+								Ref:         ref("k8s.io/api/core/v1.Probe"),
+							},
+						},
+						"readinessProbe": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Periodic probe of container service readiness. Container will be removed from service endpoints if the probe fails. Cannot be updated. More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes",
+								Ref:         ref("k8s.io/api/core/v1.Probe"),
+							},
+						},
+						"lifecycle": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Actions that the management system should take in response to container lifecycle events. Cannot be updated.",
+								Ref:         ref("k8s.io/api/core/v1.Lifecycle"),
+							},
+						},
+						"terminationMessagePath": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Optional: Path at which the file to which the container's termination message will be written is mounted into the container's filesystem. Message written is intended to be brief final status, such as an assertion failure message. Will be truncated by the node if greater than 4096 bytes. The total message length across all containers will be limited to 12kb. Defaults to /dev/termination-log. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"terminationMessagePolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Indicate how the termination message should be populated. File will use the contents of terminationMessagePath to populate the container status message on both success and failure. FallbackToLogsOnError will use the last chunk of container log output if the termination message file is empty and the container exited with an error. The log output is limited to 2048 bytes or 80 lines, whichever is smaller. Defaults to File. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"imagePullPolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"securityContext": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Security options the pod should run with. More info: https://kubernetes.io/docs/concepts/policy/security-context/ More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/",
+								Ref:         ref("k8s.io/api/core/v1.SecurityContext"),
+							},
+						},
+						"stdin": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether this container should allocate a buffer for stdin in the container runtime. If this is not set, reads from stdin in the container will always result in EOF. Default is false.",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"stdinOnce": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether the container runtime should close the stdin channel after it has been opened by a single attach. When stdin is true the stdin stream will remain open across multiple attach sessions. If stdinOnce is set to true, stdin is opened on container start, is empty until the first client attaches to stdin, and then remains open and accepts data until the client disconnects, at which time stdin is closed and remains closed until the container is restarted. If this flag is false, a container processes that reads from stdin will never receive an EOF. Default is false",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"tty": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether this container should allocate a TTY for itself, also requires 'stdin' to be true. Default is false.",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"dependencies": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Dependencies are the names of the containers of the set which must complete successfully before this container runs",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+					},
+					Required: []string{"name"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.ContainerPort", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Lifecycle", "k8s.io/api/core/v1.Probe", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.SecurityContext", "k8s.io/api/core/v1.VolumeDevice", "k8s.io/api/core/v1.VolumeMount"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerSetTemplate": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "ContainerSetTemplate is a template subtype which runs several containers in the same pod, in the order defined by their dependencies. One of the containers must be named main: the outputs of the template are collected from it.",
+					Properties: map[string]spec.Schema{
+						"containers": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Containers is the list of containers to run in the pod",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerNode"),
+										},
+									},
+								},
+							},
+						},
+						"volumeMounts": {
+							SchemaProps: spec.SchemaProps{
+								Description: "VolumeMounts are the volume mounts added to all the containers of the set",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.VolumeMount"),
+										},
+									},
+								},
+							},
+						},
+					},
+					Required: []string{"containers"},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerNode", "k8s.io/api/core/v1.VolumeMount"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "DAGTask represents a node in the graph during DAG execution",
+					Properties: map[string]spec.Schema{
+						"name": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Name is the name of the target",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"template": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Name of template to execute",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"arguments": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Arguments are the parameter and artifact arguments to the template",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments"),
+							},
+						},
+						"dependencies": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Dependencies are name of other targets which this depends on",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+					},
+					Required: []string{"name", "template"},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "DAGTemplate is a template subtype for directed acyclic graph templates",
+					Properties: map[string]spec.Schema{
+						"target": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Target are one or more names of targets to execute in a DAG",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"tasks": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Tasks are a list of DAG tasks",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask"),
+										},
+									},
+								},
+							},
+						},
+					},
+					Required: []string{"tasks"},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTask"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSArtifact": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "GCSArtifact is the location of a GCS artifact",
+					Properties: map[string]spec.Schema{
+						"bucket": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Bucket is the name of the bucket",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"serviceAccountKeySecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ServiceAccountKeySecret is the secret selector to the JSON key of the service account accessing the bucket. Application default credentials are used if omitted.",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+						"key": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Key is the path of the object in the bucket where the artifact resides",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+					},
+					Required: []string{"bucket", "key"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.SecretKeySelector"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GCSBucket": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "GCSBucket contains the access information required for interfacing with a GCS bucket",
+					Properties: map[string]spec.Schema{
+						"bucket": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Bucket is the name of the bucket",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"serviceAccountKeySecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ServiceAccountKeySecret is the secret selector to the JSON key of the service account accessing the bucket. Application default credentials are used if omitted.",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+					},
+					Required: []string{"bucket"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.SecretKeySelector"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.GitArtifact": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "GitArtifact is the location of an git artifact",
+					Properties: map[string]spec.Schema{
+						"repo": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Repo is the git repository",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"revision": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Revision is the git commit, tag, branch to checkout",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"usernameSecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "UsernameSecret is the secret selector to the repository username",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+						"passwordSecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "PasswordSecret is the secret selector to the repository password",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+					},
+					Required: []string{"repo"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.SecretKeySelector"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPArtifact": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "HTTPArtifact allows an file served on HTTP to be placed as an input artifact in a container",
+					Properties: map[string]spec.Schema{
+						"url": {
+							SchemaProps: spec.SchemaProps{
+								Description: "URL of the artifact",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+					},
+					Required: []string{"url"},
+				},
+			},
+			Dependencies: []string{},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeader": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "HTTPHeader is a header of an HTTP request",
+					Properties: map[string]spec.Schema{
+						"name": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Name is the name of the header",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"value": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Value is the literal value of the header",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"valueFrom": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ValueFrom is the source of the value of the header",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeaderSource"),
+							},
+						},
+					},
+					Required: []string{"name"},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeaderSource"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeaderSource": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "HTTPHeaderSource is the source of the value of an HTTP header",
+					Properties: map[string]spec.Schema{
+						"secretKeyRef": {
+							SchemaProps: spec.SchemaProps{
+								Description: "SecretKeyRef selects a key of a secret in the namespace of the managed",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.SecretKeySelector"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPTemplate": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "HTTPTemplate is a template subtype which sends an HTTP request directly from the controller, without creating a pod. The response body is captured as the result output of the template.",
+					Properties: map[string]spec.Schema{
+						"method": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Method is the method of the request (default GET)",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"url": {
+							SchemaProps: spec.SchemaProps{
+								Description: "URL is the URL of the request",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"headers": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Headers are the headers of the request",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeader"),
+										},
+									},
+								},
+							},
+						},
+						"body": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Body is the body of the request",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"successCondition": {
+							SchemaProps: spec.SchemaProps{
+								Description: "SuccessCondition is a label selector expression evaluated against a JSON document with the fields statusCode and body (parsed when the response is JSON), e.g. body.status == ok. If omitted, the request succeeds when the status code is 2xx.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"timeoutSeconds": {
+							SchemaProps: spec.SchemaProps{
+								Description: "TimeoutSeconds is the timeout of the request (default 30)",
+								Type:        []string{"integer"},
+								Format:      "int64",
+							},
+						},
+						"insecureSkipVerify": {
+							SchemaProps: spec.SchemaProps{
+								Description: "InsecureSkipVerify disables the verification of the TLS certificate of the server",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+					},
+					Required: []string{"url"},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPHeader"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "Inputs are the mechanism for passing parameters, artifacts, volumes from one template to another",
+					Properties: map[string]spec.Schema{
+						"parameters": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Parameters are a list of parameters passed as inputs",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"),
+										},
+									},
+								},
+							},
+						},
+						"artifacts": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Artifact are a list of artifacts passed as inputs",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Item": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type:   Item{}.OpenAPISchemaType(),
+					Format: Item{}.OpenAPISchemaFormat(),
+				},
+			},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "Pod metdata",
+					Properties: map[string]spec.Schema{
+						"annotations": {
+							SchemaProps: spec.SchemaProps{
+								Type: []string{"object"},
+								AdditionalProperties: &spec.SchemaOrBool{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+						"labels": {
+							SchemaProps: spec.SchemaProps{
+								Type: []string{"object"},
+								AdditionalProperties: &spec.SchemaOrBool{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.NoneStrategy": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "NoneStrategy stores the file of an artifact without archiving it. Directories are not supported.",
+					Properties:  map[string]spec.Schema{},
+				},
+			},
+			Dependencies: []string{},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "Outputs hold parameters, artifacts, and results from a step",
+					Properties: map[string]spec.Schema{
+						"parameters": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Parameters holds the list of output parameters produced by a step",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"),
+										},
+									},
+								},
+							},
+						},
+						"artifacts": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Artifacts holds the list of output artifacts produced by a step",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact"),
+										},
+									},
+								},
+							},
+						},
+						"result": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Result holds the result (stdout) of a script template",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Artifact", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Parameter": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
 					Description: "Parameter indicate a passed string parameter to a service template with an optional default value",
@@ -550,15 +1162,57 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 					Properties: map[string]spec.Schema{
 						"action": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Action is the action to perform to the resource. Must be one of: get, create, apply, delete, replace",
-								Type:        []string{"string"},
+								Description: "Action is the action to perform to the resource. Must be one of: get, create, apply, server-side-apply, patch, replace, delete",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"manifest": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Manifest contains the kubernetes manifest. It may contain several resources as YAML documents separated by ---",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"mergeStrategy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "MergeStrategy is the strategy of the patch action. One of: strategic|merge|json (default strategic)",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"patch": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Patch is the patch applied by the patch action to the resource identified by the manifest. Defaults to the manifest itself. Required by the json merge strategy, as a list of JSON patch operations.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"fieldManager": {
+							SchemaProps: spec.SchemaProps{
+								Description: "FieldManager is the name of the manager of the fields set by the server-side-apply action (default kubext)",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"propagationPolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "PropagationPolicy is the policy of the deletion of the dependents of the resource by the delete action. One of: Orphan|Background|Foreground (default depends on the resource)",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"waitForDeletion": {
+							SchemaProps: spec.SchemaProps{
+								Description: "WaitForDeletion makes the delete action wait until the resource is gone, e.g. once its finalizers have run, or until the timeout expires",
+								Type:        []string{"boolean"},
 								Format:      "",
 							},
 						},
-						"manifest": {
+						"setOwnerReference": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Manifest contains the kubernetes manifest",
-								Type:        []string{"string"},
+								Description: "SetOwnerReference makes the managed the owner of the resource, which is then garbage collected along with the managed. Only valid for resources in the namespace of the managed.",
+								Type:        []string{"boolean"},
 								Format:      "",
 							},
 						},
@@ -576,6 +1230,20 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Format:      "",
 							},
 						},
+						"conditionAggregation": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ConditionAggregation is how the success condition is evaluated when the manifest contains several resources. One of: all|any (default all). The failure condition of any resource fails the step.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"timeout": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Timeout is the duration, e.g. 5m, after which the step fails if the success condition is not met, or the resource deleted with waitForDeletion is not gone. Defaults to waiting until the activeDeadlineSeconds of the template, if any.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
 					},
 					Required: []string{"action", "manifest"},
 				},
@@ -594,6 +1262,20 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Format:      "int32",
 							},
 						},
+						"retryOn": {
+							SchemaProps: spec.SchemaProps{
+								Description: "RetryOn restricts retries to nodes which failed for one of the listed reasons (Evicted, OOMKilled, Preempted, DeadlineExceeded, Deleted, NodeLost, InitContainerFailed). Nodes are retried regardless of the failure reason when empty.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -672,48 +1354,277 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 						},
 						"bucket": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Bucket is the name of the bucket",
-								Type:        []string{"string"},
-								Format:      "",
+								Description: "Bucket is the name of the bucket",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"region": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Region contains the optional bucket region",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"insecure": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Insecure will connect to the service with TLS",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"accessKeySecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "AccessKeySecret is the secret selector to the bucket's access key",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+						"secretKeySecret": {
+							SchemaProps: spec.SchemaProps{
+								Description: "SecretKeySecret is the secret selector to the bucket's secret key",
+								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+							},
+						},
+					},
+					Required: []string{"endpoint", "bucket", "accessKeySecret", "secretKeySecret"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.SecretKeySelector"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "ScriptTemplate is a template subtype to enable scripting through code steps",
+					Properties: map[string]spec.Schema{
+						"name": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Name of the container specified as a DNS_LABEL. Each container in a pod must have a unique name (DNS_LABEL). Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"image": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Docker image name. More info: https://kubernetes.io/docs/concepts/containers/images This field is optional to allow higher level config management to default or override container images in workload controllers like Deployments and StatefulSets.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"command": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Entrypoint array. Not executed within a shell. The docker image's ENTRYPOINT is used if this is not provided. Variable references $(VAR_NAME) are expanded using the container's environment. If a variable cannot be resolved, the reference in the input string will be unchanged. The $(VAR_NAME) syntax can be escaped with a double $$, ie: $$(VAR_NAME). Escaped references will never be expanded, regardless of whether the variable exists or not. Cannot be updated. More info: https://kubernetes.io/docs/tasks/inject-data-application/define-command-argument-container/#running-a-command-in-a-shell",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+						"args": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Arguments to the entrypoint. The docker image's CMD is used if this is not provided. Variable references $(VAR_NAME) are expanded using the container's environment. If a variable cannot be resolved, the reference in the input string will be unchanged. The $(VAR_NAME) syntax can be escaped with a double $$, ie: $$(VAR_NAME). Escaped references will never be expanded, regardless of whether the variable exists or not. Cannot be updated. More info: https://kubernetes.io/docs/tasks/inject-data-application/define-command-argument-container/#running-a-command-in-a-shell",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+						"workingDir": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Container's working directory. If not specified, the container runtime's default will be used, which might be configured in the container image. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"ports": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "containerPort",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "List of ports to expose from the container. Exposing a port here gives the system additional information about the network connections a container uses, but is primarily informational. Not specifying a port here DOES NOT prevent that port from being exposed. Any port which is listening on the default \"0.0.0.0\" address inside a container will be accessible from the network. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.ContainerPort"),
+										},
+									},
+								},
+							},
+						},
+						"envFrom": {
+							SchemaProps: spec.SchemaProps{
+								Description: "List of sources to populate environment variables in the container. The keys defined within a source must be a C_IDENTIFIER. All invalid keys will be reported as an event when the container is starting. When a key exists in multiple sources, the value associated with the last source will take precedence. Values defined by an Env with a duplicate key will take precedence. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.EnvFromSource"),
+										},
+									},
+								},
+							},
+						},
+						"env": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "name",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "List of environment variables to set in the container. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.EnvVar"),
+										},
+									},
+								},
+							},
+						},
+						"resources": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Compute Resources required by this container. Cannot be updated. More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes#resources",
+								Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+							},
+						},
+						"volumeMounts": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "mountPath",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "Pod volumes to mount into the container's filesystem. Cannot be updated.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.VolumeMount"),
+										},
+									},
+								},
+							},
+						},
+						"volumeDevices": {
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{
+									"x-kubernetes-patch-merge-key": "devicePath",
+									"x-kubernetes-patch-strategy":  "merge",
+								},
+							},
+							SchemaProps: spec.SchemaProps{
+								Description: "volumeDevices is the list of block devices to be used by the container. This is an alpha feature and may change in the future.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.VolumeDevice"),
+										},
+									},
+								},
+							},
+						},
+						"livenessProbe": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Periodic probe of container liveness. Container will be restarted if the probe fails. Cannot be updated. More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes",
+								Ref:         ref("k8s.io/api/core/v1.Probe"),
+							},
+						},
+						"readinessProbe": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Periodic probe of container service readiness. Container will be removed from service endpoints if the probe fails. Cannot be updated. More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes",
+								Ref:         ref("k8s.io/api/core/v1.Probe"),
+							},
+						},
+						"lifecycle": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Actions that the management system should take in response to container lifecycle events. Cannot be updated.",
+								Ref:         ref("k8s.io/api/core/v1.Lifecycle"),
+							},
+						},
+						"terminationMessagePath": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Optional: Path at which the file to which the container's termination message will be written is mounted into the container's filesystem. Message written is intended to be brief final status, such as an assertion failure message. Will be truncated by the node if greater than 4096 bytes. The total message length across all containers will be limited to 12kb. Defaults to /dev/termination-log. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"terminationMessagePolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Indicate how the termination message should be populated. File will use the contents of terminationMessagePath to populate the container status message on both success and failure. FallbackToLogsOnError will use the last chunk of container log output if the termination message file is empty and the container exited with an error. The log output is limited to 2048 bytes or 80 lines, whichever is smaller. Defaults to File. Cannot be updated.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"imagePullPolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"securityContext": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Security options the pod should run with. More info: https://kubernetes.io/docs/concepts/policy/security-context/ More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/",
+								Ref:         ref("k8s.io/api/core/v1.SecurityContext"),
 							},
 						},
-						"region": {
+						"stdin": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Region contains the optional bucket region",
-								Type:        []string{"string"},
+								Description: "Whether this container should allocate a buffer for stdin in the container runtime. If this is not set, reads from stdin in the container will always result in EOF. Default is false.",
+								Type:        []string{"boolean"},
 								Format:      "",
 							},
 						},
-						"insecure": {
+						"stdinOnce": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Insecure will connect to the service with TLS",
+								Description: "Whether the container runtime should close the stdin channel after it has been opened by a single attach. When stdin is true the stdin stream will remain open across multiple attach sessions. If stdinOnce is set to true, stdin is opened on container start, is empty until the first client attaches to stdin, and then remains open and accepts data until the client disconnects, at which time stdin is closed and remains closed until the container is restarted. If this flag is false, a container processes that reads from stdin will never receive an EOF. Default is false",
 								Type:        []string{"boolean"},
 								Format:      "",
 							},
 						},
-						"accessKeySecret": {
+						"tty": {
 							SchemaProps: spec.SchemaProps{
-								Description: "AccessKeySecret is the secret selector to the bucket's access key",
-								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+								Description: "Whether this container should allocate a TTY for itself, also requires 'stdin' to be true. Default is false.",
+								Type:        []string{"boolean"},
+								Format:      "",
 							},
 						},
-						"secretKeySecret": {
+						"source": {
 							SchemaProps: spec.SchemaProps{
-								Description: "SecretKeySecret is the secret selector to the bucket's secret key",
-								Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+								Description: "Source contains the source code of the script to execute",
+								Type:        []string{"string"},
+								Format:      "",
 							},
 						},
 					},
-					Required: []string{"endpoint", "bucket", "accessKeySecret", "secretKeySecret"},
+					Required: []string{"name", "source"},
 				},
 			},
 			Dependencies: []string{
-				"k8s.io/api/core/v1.SecretKeySelector"},
+				"k8s.io/api/core/v1.ContainerPort", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Lifecycle", "k8s.io/api/core/v1.Probe", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.SecurityContext", "k8s.io/api/core/v1.VolumeDevice", "k8s.io/api/core/v1.VolumeMount"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate": {
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
-					Description: "ScriptTemplate is a template subtype to enable scripting through code steps",
+					Description: "Sidecar is a container which runs alongside the main container. If a sidecar has a readiness probe, the main container is only started once the sidecar is ready.",
 					Properties: map[string]spec.Schema{
 						"name": {
 							SchemaProps: spec.SchemaProps{
@@ -891,58 +1802,310 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Format:      "",
 							},
 						},
-						"imagePullPolicy": {
+						"imagePullPolicy": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"securityContext": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Security options the pod should run with. More info: https://kubernetes.io/docs/concepts/policy/security-context/ More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/",
+								Ref:         ref("k8s.io/api/core/v1.SecurityContext"),
+							},
+						},
+						"stdin": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether this container should allocate a buffer for stdin in the container runtime. If this is not set, reads from stdin in the container will always result in EOF. Default is false.",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"stdinOnce": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether the container runtime should close the stdin channel after it has been opened by a single attach. When stdin is true the stdin stream will remain open across multiple attach sessions. If stdinOnce is set to true, stdin is opened on container start, is empty until the first client attaches to stdin, and then remains open and accepts data until the client disconnects, at which time stdin is closed and remains closed until the container is restarted. If this flag is false, a container processes that reads from stdin will never receive an EOF. Default is false",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"tty": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Whether this container should allocate a TTY for itself, also requires 'stdin' to be true. Default is false.",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"mirrorVolumeMounts": {
+							SchemaProps: spec.SchemaProps{
+								Description: "MirrorVolumeMounts will mount the same volumes specified in the main container to the sidecar (including artifacts), at the same mountPaths. This enables dind daemon to partially see the same filesystem as the main container in order to use features such as docker volume binding",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"readinessTimeoutSeconds": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ReadinessTimeoutSeconds is the duration in seconds the main container waits for the sidecar to be ready when it has a readiness probe, before the pod fails. Defaults to 10 minutes.",
+								Type:        []string{"integer"},
+								Format:      "int64",
+							},
+						},
+						"terminationGracePeriodSeconds": {
+							SchemaProps: spec.SchemaProps{
+								Description: "TerminationGracePeriodSeconds is the duration in seconds the sidecar is given to terminate after being sent SIGTERM once the main container completes, before it is killed with SIGKILL. Defaults to 30 seconds.",
+								Type:        []string{"integer"},
+								Format:      "int64",
+							},
+						},
+						"archiveLogs": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ArchiveLogs saves the logs of the sidecar as an output artifact named <sidecar name>-logs, in the archive location of the template",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+					},
+					Required: []string{"name"},
+				},
+			},
+			Dependencies: []string{
+				"k8s.io/api/core/v1.ContainerPort", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Lifecycle", "k8s.io/api/core/v1.Probe", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.SecurityContext", "k8s.io/api/core/v1.VolumeDevice", "k8s.io/api/core/v1.VolumeMount"},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "SuspendTemplate is a template subtype to suspend a managed at a predetermined point in time",
+					Properties:  map[string]spec.Schema{},
+				},
+			},
+			Dependencies: []string{},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.TarStrategy": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "TarStrategy archives the file or directory of an artifact in a gzipped tarball",
+					Properties: map[string]spec.Schema{
+						"compressionLevel": {
+							SchemaProps: spec.SchemaProps{
+								Description: "CompressionLevel of the gzip compression, from 0 (no compression) to 9 (best compression). Defaults to 6.",
+								Type:        []string{"integer"},
+								Format:      "int32",
+							},
+						},
+					},
+				},
+			},
+			Dependencies: []string{},
+		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Template": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "Template is a reusable and composable unit of execution in a managed",
+					Properties: map[string]spec.Schema{
+						"name": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Name is the name of the template",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"inputs": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Inputs describe what inputs parameters and artifacts are supplied to this template",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs"),
+							},
+						},
+						"outputs": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Outputs describe the parameters and artifacts that this template produces",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs"),
+							},
+						},
+						"nodeSelector": {
+							SchemaProps: spec.SchemaProps{
+								Description: "NodeSelector is a selector to schedule this step of the managed to be run on the selected node(s). Overrides the selector set at the managed level.",
+								Type:        []string{"object"},
+								AdditionalProperties: &spec.SchemaOrBool{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type:   []string{"string"},
+											Format: "",
+										},
+									},
+								},
+							},
+						},
+						"affinity": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Affinity sets the pod's scheduling constraints Overrides the affinity set at the managed level (if any)",
+								Ref:         ref("k8s.io/api/core/v1.Affinity"),
+							},
+						},
+						"metadata": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Metdata sets the pods's metadata, i.e. annotations and labels",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata"),
+							},
+						},
+						"podSpecPatch": {
+							SchemaProps: spec.SchemaProps{
+								Description: "PodSpecPatch holds a strategic merge patch, in JSON or YAML, applied to the spec of the pod after the patch of the managed (if any). Supports input and global parameters.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
+						"daemon": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Deamon will allow a managed to proceed to the next step so long as the container reaches readiness",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
+						"steps": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Steps define a series of sequential/parallel managed steps",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Type: []string{"array"},
+											Items: &spec.SchemaOrArray{
+												Schema: &spec.Schema{
+													SchemaProps: spec.SchemaProps{
+														Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ManagedStep"),
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						"container": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Container is the main container image to run in the pod",
+								Ref:         ref("k8s.io/api/core/v1.Container"),
+							},
+						},
+						"script": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Script runs a portion of code against an interpreter",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate"),
+							},
+						},
+						"resource": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Resource template subtype which can run k8s resources",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ResourceTemplate"),
+							},
+						},
+						"dag": {
+							SchemaProps: spec.SchemaProps{
+								Description: "DAG template subtype which runs a DAG",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate"),
+							},
+						},
+						"suspend": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Suspend template subtype which can suspend a managed when reaching the step",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate"),
+							},
+						},
+						"containerSet": {
+							SchemaProps: spec.SchemaProps{
+								Description: "ContainerSet template subtype which runs several containers in the same pod",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerSetTemplate"),
+							},
+						},
+						"http": {
+							SchemaProps: spec.SchemaProps{
+								Description: "HTTP template subtype which sends an HTTP request from the controller, without a pod",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPTemplate"),
+							},
+						},
+						"initContainers": {
+							SchemaProps: spec.SchemaProps{
+								Description: "InitContainers is a list of containers which run to completion, in order, before the main container starts. They run after the input artifacts are loaded.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.UserContainer"),
+										},
+									},
+								},
+							},
+						},
+						"sidecars": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Sidecars is a list of containers which run alongside the main container Sidecars are automatically killed when the main container completes",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar"),
+										},
+									},
+								},
+							},
+						},
+						"archiveLocation": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
-								Type:        []string{"string"},
-								Format:      "",
+								Description: "Location in which all files related to the step will be stored (logs, artifacts, etc...). Can be overridden by individual items in Outputs. If omitted, will use the default artifact repository location configured in the controller, appended with the <managedname>/<nodename> in the key.",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactLocation"),
 							},
 						},
-						"securityContext": {
+						"activeDeadlineSeconds": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Security options the pod should run with. More info: https://kubernetes.io/docs/concepts/policy/security-context/ More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context/",
-								Ref:         ref("k8s.io/api/core/v1.SecurityContext"),
+								Description: "Optional duration in seconds relative to the StartTime that the pod may be active on a node before the system actively tries to terminate the pod; value must be positive integer This field is only applicable to container and script templates.",
+								Type:        []string{"integer"},
+								Format:      "int64",
 							},
 						},
-						"stdin": {
+						"podPendingTimeout": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Whether this container should allocate a buffer for stdin in the container runtime. If this is not set, reads from stdin in the container will always result in EOF. Default is false.",
-								Type:        []string{"boolean"},
-								Format:      "",
+								Description: "Optional duration in seconds that the pod may stay pending (e.g. unschedulable or unable to pull its image) before the node is marked as errored, which allows the retry strategy to apply. This field is only applicable to container and script templates.",
+								Type:        []string{"integer"},
+								Format:      "int64",
 							},
 						},
-						"stdinOnce": {
+						"retryStrategy": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Whether the container runtime should close the stdin channel after it has been opened by a single attach. When stdin is true the stdin stream will remain open across multiple attach sessions. If stdinOnce is set to true, stdin is opened on container start, is empty until the first client attaches to stdin, and then remains open and accepts data until the client disconnects, at which time stdin is closed and remains closed until the container is restarted. If this flag is false, a container processes that reads from stdin will never receive an EOF. Default is false",
-								Type:        []string{"boolean"},
-								Format:      "",
+								Description: "RetryStrategy describes how to retry a template when it fails",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RetryStrategy"),
 							},
 						},
-						"tty": {
+						"parallelism": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Whether this container should allocate a TTY for itself, also requires 'stdin' to be true. Default is false.",
-								Type:        []string{"boolean"},
-								Format:      "",
+								Description: "Parallelism limits the max total parallel pods that can execute at the same time within the boundaries of this template invocation. If additional steps/dag templates are invoked, the pods created by those templates will not be counted towards this total.",
+								Type:        []string{"integer"},
+								Format:      "int64",
 							},
 						},
-						"source": {
+						"tolerations": {
 							SchemaProps: spec.SchemaProps{
-								Description: "Source contains the source code of the script to execute",
-								Type:        []string{"string"},
-								Format:      "",
+								Description: "Tolerations to apply to managed pods.",
+								Type:        []string{"array"},
+								Items: &spec.SchemaOrArray{
+									Schema: &spec.Schema{
+										SchemaProps: spec.SchemaProps{
+											Ref: ref("k8s.io/api/core/v1.Toleration"),
+										},
+									},
+								},
 							},
 						},
 					},
-					Required: []string{"name", "source"},
+					Required: []string{"name"},
 				},
 			},
 			Dependencies: []string{
-				"k8s.io/api/core/v1.ContainerPort", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Lifecycle", "k8s.io/api/core/v1.Probe", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.SecurityContext", "k8s.io/api/core/v1.VolumeDevice", "k8s.io/api/core/v1.VolumeMount"},
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactLocation", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ContainerSetTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.HTTPTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ResourceTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RetryStrategy", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.UserContainer", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ManagedStep", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Container", "k8s.io/api/core/v1.Toleration"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar": {
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.UserContainer": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
-					Description: "Sidecar is a container which runs alongside the main container",
+					Description: "UserContainer is a user specified container which runs in the pod of a template",
 					Properties: map[string]spec.Schema{
 						"name": {
 							SchemaProps: spec.SchemaProps{
@@ -1156,7 +2319,7 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 						},
 						"mirrorVolumeMounts": {
 							SchemaProps: spec.SchemaProps{
-								Description: "MirrorVolumeMounts will mount the same volumes specified in the main container to the sidecar (including artifacts), at the same mountPaths. This enables dind daemon to partially see the same filesystem as the main container in order to use features such as docker volume binding",
+								Description: "MirrorVolumeMounts will mount the same volumes specified in the main container to the container (including artifacts), at the same mountPaths",
 								Type:        []string{"boolean"},
 								Format:      "",
 							},
@@ -1168,181 +2331,6 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 			Dependencies: []string{
 				"k8s.io/api/core/v1.ContainerPort", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Lifecycle", "k8s.io/api/core/v1.Probe", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.SecurityContext", "k8s.io/api/core/v1.VolumeDevice", "k8s.io/api/core/v1.VolumeMount"},
 		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "SuspendTemplate is a template subtype to suspend a managed at a predetermined point in time",
-					Properties:  map[string]spec.Schema{},
-				},
-			},
-			Dependencies: []string{},
-		},
-		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Template": {
-			Schema: spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Description: "Template is a reusable and composable unit of execution in a managed",
-					Properties: map[string]spec.Schema{
-						"name": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Name is the name of the template",
-								Type:        []string{"string"},
-								Format:      "",
-							},
-						},
-						"inputs": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Inputs describe what inputs parameters and artifacts are supplied to this template",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs"),
-							},
-						},
-						"outputs": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Outputs describe the parameters and artifacts that this template produces",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs"),
-							},
-						},
-						"nodeSelector": {
-							SchemaProps: spec.SchemaProps{
-								Description: "NodeSelector is a selector to schedule this step of the managed to be run on the selected node(s). Overrides the selector set at the managed level.",
-								Type:        []string{"object"},
-								AdditionalProperties: &spec.SchemaOrBool{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Type:   []string{"string"},
-											Format: "",
-										},
-									},
-								},
-							},
-						},
-						"affinity": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Affinity sets the pod's scheduling constraints Overrides the affinity set at the managed level (if any)",
-								Ref:         ref("k8s.io/api/core/v1.Affinity"),
-							},
-						},
-						"metadata": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Metdata sets the pods's metadata, i.e. annotations and labels",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata"),
-							},
-						},
-						"daemon": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Deamon will allow a managed to proceed to the next step so long as the container reaches readiness",
-								Type:        []string{"boolean"},
-								Format:      "",
-							},
-						},
-						"steps": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Steps define a series of sequential/parallel managed steps",
-								Type:        []string{"array"},
-								Items: &spec.SchemaOrArray{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Type: []string{"array"},
-											Items: &spec.SchemaOrArray{
-												Schema: &spec.Schema{
-													SchemaProps: spec.SchemaProps{
-														Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ManagedStep"),
-													},
-												},
-											},
-										},
-									},
-								},
-							},
-						},
-						"container": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Container is the main container image to run in the pod",
-								Ref:         ref("k8s.io/api/core/v1.Container"),
-							},
-						},
-						"script": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Script runs a portion of code against an interpreter",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate"),
-							},
-						},
-						"resource": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Resource template subtype which can run k8s resources",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ResourceTemplate"),
-							},
-						},
-						"dag": {
-							SchemaProps: spec.SchemaProps{
-								Description: "DAG template subtype which runs a DAG",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate"),
-							},
-						},
-						"suspend": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Suspend template subtype which can suspend a managed when reaching the step",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate"),
-							},
-						},
-						"sidecars": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Sidecars is a list of containers which run alongside the main container Sidecars are automatically killed when the main container completes",
-								Type:        []string{"array"},
-								Items: &spec.SchemaOrArray{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Ref: ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar"),
-										},
-									},
-								},
-							},
-						},
-						"archiveLocation": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Location in which all files related to the step will be stored (logs, artifacts, etc...). Can be overridden by individual items in Outputs. If omitted, will use the default artifact repository location configured in the controller, appended with the <managedname>/<nodename> in the key.",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactLocation"),
-							},
-						},
-						"activeDeadlineSeconds": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Optional duration in seconds relative to the StartTime that the pod may be active on a node before the system actively tries to terminate the pod; value must be positive integer This field is only applicable to container and script templates.",
-								Type:        []string{"integer"},
-								Format:      "int64",
-							},
-						},
-						"retryStrategy": {
-							SchemaProps: spec.SchemaProps{
-								Description: "RetryStrategy describes how to retry a template when it fails",
-								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RetryStrategy"),
-							},
-						},
-						"parallelism": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Parallelism limits the max total parallel pods that can execute at the same time within the boundaries of this template invocation. If additional steps/dag templates are invoked, the pods created by those templates will not be counted towards this total.",
-								Type:        []string{"integer"},
-								Format:      "int64",
-							},
-						},
-						"tolerations": {
-							SchemaProps: spec.SchemaProps{
-								Description: "Tolerations to apply to managed pods.",
-								Type:        []string{"array"},
-								Items: &spec.SchemaOrArray{
-									Schema: &spec.Schema{
-										SchemaProps: spec.SchemaProps{
-											Ref: ref("k8s.io/api/core/v1.Toleration"),
-										},
-									},
-								},
-							},
-						},
-					},
-					Required: []string{"name"},
-				},
-			},
-			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ArtifactLocation", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.DAGTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Inputs", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Outputs", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ResourceTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.RetryStrategy", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ScriptTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Sidecar", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.SuspendTemplate", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ManagedStep", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Container", "k8s.io/api/core/v1.Toleration"},
-		},
 		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ValueFrom": {
 			Schema: spec.Schema{
 				SchemaProps: spec.SchemaProps{
@@ -1589,6 +2577,19 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								},
 							},
 						},
+						"podMetadata": {
+							SchemaProps: spec.SchemaProps{
+								Description: "PodMetadata sets the annotations and labels of all pods of the managed. Can be overridden by the metadata specified in the template. The keys prefixed with jbrette.io, or one of its subdomains, are reserved by the controller and rejected.",
+								Ref:         ref("github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata"),
+							},
+						},
+						"podSpecPatch": {
+							SchemaProps: spec.SchemaProps{
+								Description: "PodSpecPatch holds a strategic merge patch, in JSON or YAML, applied to the spec of all pods of the managed. It allows setting pod fields which are not otherwise exposed, such as hostAliases, dnsConfig, securityContext or priorityClassName. Supports global parameters.",
+								Type:        []string{"string"},
+								Format:      "",
+							},
+						},
 						"onExit": {
 							SchemaProps: spec.SchemaProps{
 								Description: "OnExit is a template reference which is invoked at the end of the managed, irrespective of the success, failure, or error of the primary managed.",
@@ -1596,12 +2597,19 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 								Format:      "",
 							},
 						},
+						"deleteResourcesOnCompletion": {
+							SchemaProps: spec.SchemaProps{
+								Description: "DeleteResourcesOnCompletion deletes the resources created by the resource templates of the managed, as recorded in the resourceRefs of their nodes, once the managed completes. Only the resources in the namespace of the managed which carry its owner reference, or its UID in the manageds.jbrette.io/managed-uid annotation, are deleted. The service account of the controller must be allowed to get and delete their kinds, which the kubext cluster role only allows for pods, so other kinds need an additional role. Failed deletions are logged and do not prevent the completion of the managed.",
+								Type:        []string{"boolean"},
+								Format:      "",
+							},
+						},
 					},
 					Required: []string{"templates", "entrypoint"},
 				},
 			},
 			Dependencies: []string{
-				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Template", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.PersistentVolumeClaim", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.Volume"},
+				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Metadata", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Template", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.PersistentVolumeClaim", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.Volume"},
 		},
 		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ManagedStep": {
 			Schema: spec.Schema{
@@ -1661,5 +2669,14 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 			Dependencies: []string{
 				"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Arguments", "github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.Item"},
 		},
+		"github.com/jbrette/kubext/pkg/apis/managed/v1alpha1.ZstdStrategy": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "ZstdStrategy archives the file or directory of an artifact in a tarball compressed with zstd, which is faster than gzip for large datasets",
+					Properties:  map[string]spec.Schema{},
+				},
+			},
+			Dependencies: []string{},
+		},
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"strings"
 
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,12 +15,14 @@ type TemplateType string
 
 // Possible template types
 const (
-	TemplateTypeContainer TemplateType = "Container"
-	TemplateTypeSteps     TemplateType = "Steps"
-	TemplateTypeScript    TemplateType = "Script"
-	TemplateTypeResource  TemplateType = "Resource"
-	TemplateTypeDAG       TemplateType = "DAG"
-	TemplateTypeSuspend   TemplateType = "Suspend"
+	TemplateTypeContainer    TemplateType = "Container"
+	TemplateTypeSteps        TemplateType = "Steps"
+	TemplateTypeScript       TemplateType = "Script"
+	TemplateTypeResource     TemplateType = "Resource"
+	TemplateTypeDAG          TemplateType = "DAG"
+	TemplateTypeSuspend      TemplateType = "Suspend"
+	TemplateTypeContainerSet TemplateType = "ContainerSet"
+	TemplateTypeHTTP         TemplateType = "HTTP"
 )
 
 // NodePhase is a label for the condition of a node at the current time.
@@ -46,6 +49,29 @@ const (
 	NodeTypeRetry     NodeType = "Retry"
 	NodeTypeSkipped   NodeType = "Skipped"
 	NodeTypeSuspend   NodeType = "Suspend"
+	NodeTypeContainer NodeType = "Container"
+	NodeTypeHTTP      NodeType = "HTTP"
+)
+
+// NodeFailureReason is a machine-readable reason why a pod node failed
+type NodeFailureReason string
+
+// Node failure reasons
+const (
+	// NodeEvicted indicates the pod was evicted from its node (e.g. due to node pressure)
+	NodeEvicted NodeFailureReason = "Evicted"
+	// NodeOOMKilled indicates the main container was killed for exceeding its memory limit
+	NodeOOMKilled NodeFailureReason = "OOMKilled"
+	// NodePreempted indicates the pod was preempted by a pod of higher priority
+	NodePreempted NodeFailureReason = "Preempted"
+	// NodeDeadlineExceeded indicates the pod exceeded its active deadline
+	NodeDeadlineExceeded NodeFailureReason = "DeadlineExceeded"
+	// NodeDeleted indicates the pod was deleted before it completed
+	NodeDeleted NodeFailureReason = "Deleted"
+	// NodeLost indicates the node running the pod became unreachable
+	NodeLost NodeFailureReason = "NodeLost"
+	// NodeInitContainerFailed indicates a user specified init container failed
+	NodeInitContainerFailed NodeFailureReason = "InitContainerFailed"
 )
 
 // Managed is the definition of a managed resource
@@ -115,10 +141,29 @@ type ManagedSpec struct {
 	// More info: https://kubernetes.io/docs/concepts/containers/images/#specifying-imagepullsecrets-on-a-pod
 	ImagePullSecrets []apiv1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
 
+	// PodMetadata sets the annotations and labels of all pods of the managed.
+	// Can be overridden by the metadata specified in the template. The keys prefixed with jbrette.io,
+	// or one of its subdomains, are reserved by the controller and rejected.
+	PodMetadata *Metadata `json:"podMetadata,omitempty"`
+
+	// PodSpecPatch holds a strategic merge patch, in JSON or YAML, applied to the spec of all pods
+	// of the managed. It allows setting pod fields which are not otherwise exposed, such as
+	// hostAliases, dnsConfig, securityContext or priorityClassName. Supports global parameters.
+	PodSpecPatch string `json:"podSpecPatch,omitempty"`
+
 	// OnExit is a template reference which is invoked at the end of the
 	// managed, irrespective of the success, failure, or error of the
 	// primary managed.
 	OnExit string `json:"onExit,omitempty"`
+
+	// DeleteResourcesOnCompletion deletes the resources created by the resource templates of the
+	// managed, as recorded in the resourceRefs of their nodes, once the managed completes. Only
+	// the resources in the namespace of the managed which carry its owner reference, or its UID
+	// in the manageds.jbrette.io/managed-uid annotation, are deleted. The service account of the controller
+	// must be allowed to get and delete their kinds, which the kubext cluster role only allows for
+	// pods, so other kinds need an additional role. Failed deletions are logged and do not prevent
+	// the completion of the managed.
+	DeleteResourcesOnCompletion bool `json:"deleteResourcesOnCompletion,omitempty"`
 }
 
 // Template is a reusable and composable unit of execution in a managed
@@ -143,6 +188,10 @@ type Template struct {
 	// Metdata sets the pods's metadata, i.e. annotations and labels
 	Metadata Metadata `json:"metadata,omitempty"`
 
+	// PodSpecPatch holds a strategic merge patch, in JSON or YAML, applied to the spec of the pod
+	// after the patch of the managed (if any). Supports input and global parameters.
+	PodSpecPatch string `json:"podSpecPatch,omitempty"`
+
 	// Deamon will allow a managed to proceed to the next step so long as the container reaches readiness
 	Daemon *bool `json:"daemon,omitempty"`
 
@@ -164,6 +213,16 @@ type Template struct {
 	// Suspend template subtype which can suspend a managed when reaching the step
 	Suspend *SuspendTemplate `json:"suspend,omitempty"`
 
+	// ContainerSet template subtype which runs several containers in the same pod
+	ContainerSet *ContainerSetTemplate `json:"containerSet,omitempty"`
+
+	// HTTP template subtype which sends an HTTP request from the controller, without a pod
+	HTTP *HTTPTemplate `json:"http,omitempty"`
+
+	// InitContainers is a list of containers which run to completion, in order, before the main
+	// container starts. They run after the input artifacts are loaded.
+	InitContainers []UserContainer `json:"initContainers,omitempty"`
+
 	// Sidecars is a list of containers which run alongside the main container
 	// Sidecars are automatically killed when the main container completes
 	Sidecars []Sidecar `json:"sidecars,omitempty"`
@@ -179,6 +238,11 @@ type Template struct {
 	// This field is only applicable to container and script templates.
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 
+	// Optional duration in seconds that the pod may stay pending (e.g. unschedulable or unable to
+	// pull its image) before the node is marked as errored, which allows the retry strategy to apply.
+	// This field is only applicable to container and script templates.
+	PodPendingTimeout *int64 `json:"podPendingTimeout,omitempty"`
+
 	// RetryStrategy describes how to retry a template when it fails
 	RetryStrategy *RetryStrategy `json:"retryStrategy,omitempty"`
 
@@ -263,8 +327,39 @@ type Artifact struct {
 	// GlobalName exports an output artifact to the global scope, making it available as
 	// '{{managed.outputs.artifacts.XXXX}} and in managed.status.outputs.artifacts
 	GlobalName string `json:"globalName,omitempty"`
+
+	// Archive controls how the file or directory of an output artifact is archived when it is
+	// saved, and how an input artifact is extracted when it is loaded. Output artifacts record
+	// the archive they were saved with. Defaults to a gzipped tarball.
+	Archive *ArchiveStrategy `json:"archive,omitempty"`
+}
+
+// ArchiveStrategy describes how to archive the file or directory of an artifact
+type ArchiveStrategy struct {
+	// Tar archives the file or directory in a gzipped tarball
+	Tar *TarStrategy `json:"tar,omitempty"`
+
+	// None stores a single file as is, so that it can be read by other tools
+	None *NoneStrategy `json:"none,omitempty"`
+
+	// Zstd archives the file or directory in a tarball compressed with zstd
+	Zstd *ZstdStrategy `json:"zstd,omitempty"`
+}
+
+// TarStrategy archives the file or directory of an artifact in a gzipped tarball
+type TarStrategy struct {
+	// CompressionLevel of the gzip compression, from 0 (no compression) to 9 (best compression).
+	// Defaults to 6.
+	CompressionLevel *int32 `json:"compressionLevel,omitempty"`
 }
 
+// NoneStrategy stores the file of an artifact without archiving it. Directories are not supported.
+type NoneStrategy struct{}
+
+// ZstdStrategy archives the file or directory of an artifact in a tarball compressed with zstd,
+// which is faster than gzip for large datasets
+type ZstdStrategy struct{}
+
 // ArtifactLocation describes a location for a single or multiple artifacts.
 // It is used as single artifact in the context of inputs/outputs (e.g. outputs.artifacts.artname).
 // It is also used to describe the location of multiple artifacts such as the archive location
@@ -284,6 +379,12 @@ type ArtifactLocation struct {
 
 	// Raw contains raw artifact location details
 	Raw *RawArtifact `json:"raw,omitempty"`
+
+	// GCS contains GCS artifact location details
+	GCS *GCSArtifact `json:"gcs,omitempty"`
+
+	// Azure contains Azure Blob Storage artifact location details
+	Azure *AzureArtifact `json:"azure,omitempty"`
 }
 
 // Outputs hold parameters, artifacts, and results from a step
@@ -365,7 +466,8 @@ type Arguments struct {
 	Artifacts []Artifact `json:"artifacts,omitempty"`
 }
 
-// Sidecar is a container which runs alongside the main container
+// Sidecar is a container which runs alongside the main container. If a sidecar has a readiness
+// probe, the main container is only started once the sidecar is ready.
 type Sidecar struct {
 	apiv1.Container `json:",inline"`
 
@@ -374,6 +476,28 @@ type Sidecar struct {
 	// dind daemon to partially see the same filesystem as the main container in
 	// order to use features such as docker volume binding
 	MirrorVolumeMounts *bool `json:"mirrorVolumeMounts,omitempty"`
+
+	// ReadinessTimeoutSeconds is the duration in seconds the main container waits for the sidecar
+	// to be ready when it has a readiness probe, before the pod fails. Defaults to 10 minutes.
+	ReadinessTimeoutSeconds *int64 `json:"readinessTimeoutSeconds,omitempty"`
+
+	// TerminationGracePeriodSeconds is the duration in seconds the sidecar is given to terminate
+	// after being sent SIGTERM once the main container completes, before it is killed with SIGKILL.
+	// Defaults to 30 seconds.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// ArchiveLogs saves the logs of the sidecar as an output artifact named <sidecar name>-logs,
+	// in the archive location of the template
+	ArchiveLogs *bool `json:"archiveLogs,omitempty"`
+}
+
+// UserContainer is a user specified container which runs in the pod of a template
+type UserContainer struct {
+	apiv1.Container `json:",inline"`
+
+	// MirrorVolumeMounts will mount the same volumes specified in the main container
+	// to the container (including artifacts), at the same mountPaths
+	MirrorVolumeMounts *bool `json:"mirrorVolumeMounts,omitempty"`
 }
 
 // ManagedStatus contains overall status information about a managed
@@ -400,12 +524,83 @@ type ManagedStatus struct {
 
 	// Outputs captures output values and artifact locations produced by the managed via global outputs
 	Outputs *Outputs `json:"outputs,omitempty"`
+
+	// ResourcesDuration is the sum of the resources duration of all the pod nodes of the managed
+	ResourcesDuration *ResourcesDuration `json:"resourcesDuration,omitempty"`
+}
+
+// ResourceRef is a reference to a resource created by a resource template
+type ResourceRef struct {
+	// APIVersion is the API version of the resource
+	APIVersion string `json:"apiVersion"`
+
+	// Kind is the kind of the resource
+	Kind string `json:"kind"`
+
+	// Resource is the plural name of the API resource of the kind, e.g. deployments
+	Resource string `json:"resource"`
+
+	// Namespace is the namespace of the resource, empty for cluster scoped resources
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is the name of the resource
+	Name string `json:"name"`
+
+	// UID is the UID of the resource, which prevents deleting a resource recreated with the same name
+	UID string `json:"uid,omitempty"`
+}
+
+// ResourcesDuration is the amount of resources requested by the containers of a pod, multiplied
+// by the time each container ran
+// +k8s:openapi-gen=false
+type ResourcesDuration struct {
+	// CPU is the number of CPU core-seconds
+	CPU int64 `json:"cpu,omitempty"`
+
+	// Memory is the number of MiB-seconds of memory
+	Memory int64 `json:"memory,omitempty"`
+}
+
+// Add adds the resources duration of other to r
+func (r *ResourcesDuration) Add(other *ResourcesDuration) {
+	if other == nil {
+		return
+	}
+	r.CPU += other.CPU
+	r.Memory += other.Memory
+}
+
+// IsZero returns whether or not no resources were used
+func (r *ResourcesDuration) IsZero() bool {
+	return r == nil || (r.CPU == 0 && r.Memory == 0)
+}
+
+func (r ResourcesDuration) String() string {
+	return fmt.Sprintf("%d cpu-s, %d MiB-s", r.CPU, r.Memory)
 }
 
 // RetryStrategy provides controls on how to retry a managed step
 type RetryStrategy struct {
 	// Limit is the maximum number of attempts when retrying a container
 	Limit *int32 `json:"limit,omitempty"`
+
+	// RetryOn restricts retries to nodes which failed for one of the listed reasons
+	// (Evicted, OOMKilled, Preempted, DeadlineExceeded, Deleted, NodeLost, InitContainerFailed).
+	// Nodes are retried regardless of the failure reason when empty.
+	RetryOn []NodeFailureReason `json:"retryOn,omitempty"`
+}
+
+// ShouldRetry returns whether a node which failed for the given reason may be retried
+func (r RetryStrategy) ShouldRetry(reason NodeFailureReason) bool {
+	if len(r.RetryOn) == 0 {
+		return true
+	}
+	for _, retryOn := range r.RetryOn {
+		if retryOn == reason {
+			return true
+		}
+	}
+	return false
 }
 
 // NodeStatus contains status information about an individual node in the managed
@@ -437,6 +632,15 @@ type NodeStatus struct {
 	// A human readable message indicating details about why the node is in this condition.
 	Message string `json:"message,omitempty"`
 
+	// FailureReason is a machine-readable reason why the pod of the node failed, when known
+	FailureReason NodeFailureReason `json:"failureReason,omitempty"`
+
+	// ExitCode is the exit code of the main container of a failed pod
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Signal is the signal which terminated the main container of a failed pod
+	Signal *int32 `json:"signal,omitempty"`
+
 	// Time at which this node started
 	StartedAt metav1.Time `json:"startedAt,omitempty"`
 
@@ -446,6 +650,16 @@ type NodeStatus struct {
 	// PodIP captures the IP of the pod for daemoned steps
 	PodIP string `json:"podIP,omitempty"`
 
+	// HostNodeName is the name of the Kubernetes node on which the pod of the node was scheduled
+	HostNodeName string `json:"hostNodeName,omitempty"`
+
+	// HostIP is the IP of the Kubernetes node on which the pod of the node was scheduled
+	HostIP string `json:"hostIP,omitempty"`
+
+	// ResourcesDuration is the amount of resources requested by the pod of the node, multiplied by its runtime.
+	// It is recorded once the node completes.
+	ResourcesDuration *ResourcesDuration `json:"resourcesDuration,omitempty"`
+
 	// Daemoned tracks whether or not this node was daemoned and need to be terminated
 	Daemoned *bool `json:"daemoned,omitempty"`
 
@@ -455,6 +669,9 @@ type NodeStatus struct {
 	// Outputs captures output parameter values and artifact locations produced by this template invocation
 	Outputs *Outputs `json:"outputs,omitempty"`
 
+	// ResourceRefs references the resources created by a resource template
+	ResourceRefs []ResourceRef `json:"resourceRefs,omitempty"`
+
 	// Children is a list of child node IDs
 	Children []string `json:"children,omitempty"`
 
@@ -541,6 +758,66 @@ func (s *S3Artifact) String() string {
 	return fmt.Sprintf("%s://%s/%s/%s", protocol, s.Endpoint, s.Bucket, s.Key)
 }
 
+// GCSBucket contains the access information required for interfacing with a GCS bucket
+type GCSBucket struct {
+	// Bucket is the name of the bucket
+	Bucket string `json:"bucket"`
+
+	// ServiceAccountKeySecret is the secret selector to the JSON key of the service account
+	// accessing the bucket. Application default credentials are used if omitted.
+	ServiceAccountKeySecret *apiv1.SecretKeySelector `json:"serviceAccountKeySecret,omitempty"`
+}
+
+// GCSArtifact is the location of a GCS artifact
+type GCSArtifact struct {
+	GCSBucket `json:",inline"`
+
+	// Key is the path of the object in the bucket where the artifact resides
+	Key string `json:"key"`
+}
+
+func (g *GCSArtifact) String() string {
+	return fmt.Sprintf("gs://%s/%s", g.Bucket, g.Key)
+}
+
+// AzureContainer contains the access information required for interfacing with an Azure Blob
+// Storage container
+type AzureContainer struct {
+	// Endpoint is the URL of the blob service of the account. Defaults to
+	// https://<account>.blob.core.windows.net. Set it to the URL of an emulator such as Azurite,
+	// e.g. http://127.0.0.1:10000/devstoreaccount1.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Account is the name of the storage account
+	Account string `json:"account"`
+
+	// Container is the name of the container
+	Container string `json:"container"`
+
+	// AccountKeySecret is the secret selector to the access key of the storage account
+	AccountKeySecret *apiv1.SecretKeySelector `json:"accountKeySecret,omitempty"`
+
+	// SASTokenSecret is the secret selector to a shared access signature token granting access
+	// to the container
+	SASTokenSecret *apiv1.SecretKeySelector `json:"sasTokenSecret,omitempty"`
+}
+
+// AzureArtifact is the location of an Azure Blob Storage artifact
+type AzureArtifact struct {
+	AzureContainer `json:",inline"`
+
+	// Blob is the name of the blob in the container where the artifact resides
+	Blob string `json:"blob"`
+}
+
+func (a *AzureArtifact) String() string {
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", a.Account)
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), a.Container, a.Blob)
+}
+
 // GitArtifact is the location of an git artifact
 type GitArtifact struct {
 	// Repo is the git repository
@@ -596,15 +873,79 @@ type ScriptTemplate struct {
 	Source string `json:"source"`
 }
 
+// ContainerSetTemplate is a template subtype which runs several containers in the same pod,
+// in the order defined by their dependencies. One of the containers must be named main: the
+// outputs of the template are collected from it.
+type ContainerSetTemplate struct {
+	// Containers is the list of containers to run in the pod
+	Containers []ContainerNode `json:"containers"`
+
+	// VolumeMounts are the volume mounts added to all the containers of the set
+	VolumeMounts []apiv1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// ContainerNode is a container of a container set
+type ContainerNode struct {
+	apiv1.Container `json:",inline"`
+
+	// Dependencies are the names of the containers of the set which must complete successfully
+	// before this container runs
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// GetContainer returns the container of the set with the given name, or nil if there is none
+func (cs *ContainerSetTemplate) GetContainer(name string) *ContainerNode {
+	for i := range cs.Containers {
+		if cs.Containers[i].Name == name {
+			return &cs.Containers[i]
+		}
+	}
+	return nil
+}
+
+// GetContainerNames returns the names of the containers of the set
+func (cs *ContainerSetTemplate) GetContainerNames() []string {
+	names := make([]string, len(cs.Containers))
+	for i, ctr := range cs.Containers {
+		names[i] = ctr.Name
+	}
+	return names
+}
+
 // ResourceTemplate is a template subtype to manipulate kubernetes resources
 type ResourceTemplate struct {
 	// Action is the action to perform to the resource.
-	// Must be one of: get, create, apply, delete, replace
+	// Must be one of: get, create, apply, server-side-apply, patch, replace, delete
 	Action string `json:"action"`
 
-	// Manifest contains the kubernetes manifest
+	// Manifest contains the kubernetes manifest. It may contain several resources as YAML
+	// documents separated by ---
 	Manifest string `json:"manifest"`
 
+	// MergeStrategy is the strategy of the patch action. One of: strategic|merge|json (default strategic)
+	MergeStrategy string `json:"mergeStrategy,omitempty"`
+
+	// Patch is the patch applied by the patch action to the resource identified by the manifest.
+	// Defaults to the manifest itself. Required by the json merge strategy, as a list of JSON
+	// patch operations.
+	Patch string `json:"patch,omitempty"`
+
+	// FieldManager is the name of the manager of the fields set by the server-side-apply action
+	// (default kubext)
+	FieldManager string `json:"fieldManager,omitempty"`
+
+	// PropagationPolicy is the policy of the deletion of the dependents of the resource by the
+	// delete action. One of: Orphan|Background|Foreground (default depends on the resource)
+	PropagationPolicy string `json:"propagationPolicy,omitempty"`
+
+	// WaitForDeletion makes the delete action wait until the resource is gone, e.g. once its
+	// finalizers have run, or until the timeout expires
+	WaitForDeletion bool `json:"waitForDeletion,omitempty"`
+
+	// SetOwnerReference makes the managed the owner of the resource, which is then garbage
+	// collected along with the managed. Only valid for resources in the namespace of the managed.
+	SetOwnerReference bool `json:"setOwnerReference,omitempty"`
+
 	// SuccessCondition is a label selector expression which describes the conditions
 	// of the k8s resource in which it is acceptable to proceed to the following step
 	SuccessCondition string `json:"successCondition,omitempty"`
@@ -612,6 +953,16 @@ type ResourceTemplate struct {
 	// FailureCondition is a label selector expression which describes the conditions
 	// of the k8s resource in which the step was considered failed
 	FailureCondition string `json:"failureCondition,omitempty"`
+
+	// ConditionAggregation is how the success condition is evaluated when the manifest contains
+	// several resources. One of: all|any (default all). The failure condition of any resource
+	// fails the step.
+	ConditionAggregation string `json:"conditionAggregation,omitempty"`
+
+	// Timeout is the duration, e.g. 5m, after which the step fails if the success condition is not
+	// met, or the resource deleted with waitForDeletion is not gone. Defaults to waiting until the
+	// activeDeadlineSeconds of the template, if any.
+	Timeout string `json:"timeout,omitempty"`
 }
 
 // GetType returns the type of this template
@@ -634,13 +985,19 @@ func (tmpl *Template) GetType() TemplateType {
 	if tmpl.Suspend != nil {
 		return TemplateTypeSuspend
 	}
+	if tmpl.ContainerSet != nil {
+		return TemplateTypeContainerSet
+	}
+	if tmpl.HTTP != nil {
+		return TemplateTypeHTTP
+	}
 	return "Unknown"
 }
 
 // IsPodType returns whether or not the template is a pod type
 func (tmpl *Template) IsPodType() bool {
 	switch tmpl.GetType() {
-	case TemplateTypeContainer, TemplateTypeScript, TemplateTypeResource:
+	case TemplateTypeContainer, TemplateTypeScript, TemplateTypeResource, TemplateTypeContainerSet:
 		return true
 	}
 	return false
@@ -670,6 +1027,51 @@ type DAGTask struct {
 	Dependencies []string `json:"dependencies,omitempty"`
 }
 
+// HTTPTemplate is a template subtype which sends an HTTP request directly from the controller,
+// without creating a pod. The response body is captured as the result output of the template.
+type HTTPTemplate struct {
+	// Method is the method of the request (default GET)
+	Method string `json:"method,omitempty"`
+
+	// URL is the URL of the request
+	URL string `json:"url"`
+
+	// Headers are the headers of the request
+	Headers []HTTPHeader `json:"headers,omitempty"`
+
+	// Body is the body of the request
+	Body string `json:"body,omitempty"`
+
+	// SuccessCondition is a label selector expression evaluated against a JSON document with the
+	// fields statusCode and body (parsed when the response is JSON), e.g. body.status == ok.
+	// If omitted, the request succeeds when the status code is 2xx.
+	SuccessCondition string `json:"successCondition,omitempty"`
+
+	// TimeoutSeconds is the timeout of the request (default 30)
+	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the TLS certificate of the server
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// HTTPHeader is a header of an HTTP request
+type HTTPHeader struct {
+	// Name is the name of the header
+	Name string `json:"name"`
+
+	// Value is the literal value of the header
+	Value string `json:"value,omitempty"`
+
+	// ValueFrom is the source of the value of the header
+	ValueFrom *HTTPHeaderSource `json:"valueFrom,omitempty"`
+}
+
+// HTTPHeaderSource is the source of the value of an HTTP header
+type HTTPHeaderSource struct {
+	// SecretKeyRef selects a key of a secret in the namespace of the managed
+	SecretKeyRef *apiv1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
 // SuspendTemplate is a template subtype to suspend a managed at a predetermined point in time
 type SuspendTemplate struct {
 }
@@ -741,7 +1143,7 @@ func (args *Arguments) GetParameterByName(name string) *Parameter {
 
 // HasLocation whether or not an artifact has a location defined
 func (a *Artifact) HasLocation() bool {
-	return a.S3 != nil || a.Git != nil || a.HTTP != nil || a.Artifactory != nil || a.Raw != nil
+	return a.S3 != nil || a.Git != nil || a.HTTP != nil || a.Artifactory != nil || a.Raw != nil || a.GCS != nil || a.Azure != nil
 }
 
 // GetTemplate retrieves a defined template by its name
@@ -9,6 +9,49 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchiveStrategy) DeepCopyInto(out *ArchiveStrategy) {
+	*out = *in
+	if in.Tar != nil {
+		in, out := &in.Tar, &out.Tar
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(TarStrategy)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.None != nil {
+		in, out := &in.None, &out.None
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(NoneStrategy)
+			**out = **in
+		}
+	}
+	if in.Zstd != nil {
+		in, out := &in.Zstd, &out.Zstd
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ZstdStrategy)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveStrategy.
+func (in *ArchiveStrategy) DeepCopy() *ArchiveStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchiveStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Arguments) DeepCopyInto(out *Arguments) {
 	*out = *in
@@ -52,6 +95,15 @@ func (in *Artifact) DeepCopyInto(out *Artifact) {
 		}
 	}
 	in.ArtifactLocation.DeepCopyInto(&out.ArtifactLocation)
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ArchiveStrategy)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	return
 }
 
@@ -113,6 +165,24 @@ func (in *ArtifactLocation) DeepCopyInto(out *ArtifactLocation) {
 			**out = **in
 		}
 	}
+	if in.GCS != nil {
+		in, out := &in.GCS, &out.GCS
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(GCSArtifact)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AzureArtifact)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	return
 }
 
@@ -177,6 +247,107 @@ func (in *ArtifactoryAuth) DeepCopy() *ArtifactoryAuth {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureArtifact) DeepCopyInto(out *AzureArtifact) {
+	*out = *in
+	in.AzureContainer.DeepCopyInto(&out.AzureContainer)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureArtifact.
+func (in *AzureArtifact) DeepCopy() *AzureArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureContainer) DeepCopyInto(out *AzureContainer) {
+	*out = *in
+	if in.AccountKeySecret != nil {
+		in, out := &in.AccountKeySecret, &out.AccountKeySecret
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.SASTokenSecret != nil {
+		in, out := &in.SASTokenSecret, &out.SASTokenSecret
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureContainer.
+func (in *AzureContainer) DeepCopy() *AzureContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerNode) DeepCopyInto(out *ContainerNode) {
+	*out = *in
+	in.Container.DeepCopyInto(&out.Container)
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerNode.
+func (in *ContainerNode) DeepCopy() *ContainerNode {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerSetTemplate) DeepCopyInto(out *ContainerSetTemplate) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerSetTemplate.
+func (in *ContainerSetTemplate) DeepCopy() *ContainerSetTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerSetTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DAGTask) DeepCopyInto(out *DAGTask) {
 	*out = *in
@@ -222,6 +393,48 @@ func (in *DAGTemplate) DeepCopy() *DAGTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSArtifact) DeepCopyInto(out *GCSArtifact) {
+	*out = *in
+	in.GCSBucket.DeepCopyInto(&out.GCSBucket)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSArtifact.
+func (in *GCSArtifact) DeepCopy() *GCSArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSBucket) DeepCopyInto(out *GCSBucket) {
+	*out = *in
+	if in.ServiceAccountKeySecret != nil {
+		in, out := &in.ServiceAccountKeySecret, &out.ServiceAccountKeySecret
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSBucket.
+func (in *GCSBucket) DeepCopy() *GCSBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitArtifact) DeepCopyInto(out *GitArtifact) {
 	*out = *in
@@ -272,6 +485,88 @@ func (in *HTTPArtifact) DeepCopy() *HTTPArtifact {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPHeader) DeepCopyInto(out *HTTPHeader) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(HTTPHeaderSource)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHeader.
+func (in *HTTPHeader) DeepCopy() *HTTPHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPHeaderSource) DeepCopyInto(out *HTTPHeaderSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHeaderSource.
+func (in *HTTPHeaderSource) DeepCopy() *HTTPHeaderSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPHeaderSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPTemplate) DeepCopyInto(out *HTTPTemplate) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]HTTPHeader, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPTemplate.
+func (in *HTTPTemplate) DeepCopy() *HTTPTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Inputs) DeepCopyInto(out *Inputs) {
 	*out = *in
@@ -347,6 +642,33 @@ func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
 	*out = *in
 	in.StartedAt.DeepCopyInto(&out.StartedAt)
 	in.FinishedAt.DeepCopyInto(&out.FinishedAt)
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int32)
+			**out = **in
+		}
+	}
+	if in.Signal != nil {
+		in, out := &in.Signal, &out.Signal
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int32)
+			**out = **in
+		}
+	}
+	if in.ResourcesDuration != nil {
+		in, out := &in.ResourcesDuration, &out.ResourcesDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ResourcesDuration)
+			**out = **in
+		}
+	}
 	if in.Daemoned != nil {
 		in, out := &in.Daemoned, &out.Daemoned
 		if *in == nil {
@@ -374,6 +696,11 @@ func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.ResourceRefs != nil {
+		in, out := &in.ResourceRefs, &out.ResourceRefs
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
 	if in.Children != nil {
 		in, out := &in.Children, &out.Children
 		*out = make([]string, len(*in))
@@ -397,6 +724,22 @@ func (in *NodeStatus) DeepCopy() *NodeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NoneStrategy) DeepCopyInto(out *NoneStrategy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NoneStrategy.
+func (in *NoneStrategy) DeepCopy() *NoneStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(NoneStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Outputs) DeepCopyInto(out *Outputs) {
 	*out = *in
@@ -495,6 +838,22 @@ func (in *RawArtifact) DeepCopy() *RawArtifact {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRef) DeepCopyInto(out *ResourceRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRef.
+func (in *ResourceRef) DeepCopy() *ResourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceTemplate) DeepCopyInto(out *ResourceTemplate) {
 	*out = *in
@@ -511,6 +870,22 @@ func (in *ResourceTemplate) DeepCopy() *ResourceTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcesDuration) DeepCopyInto(out *ResourcesDuration) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcesDuration.
+func (in *ResourcesDuration) DeepCopy() *ResourcesDuration {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcesDuration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetryStrategy) DeepCopyInto(out *RetryStrategy) {
 	*out = *in
@@ -523,6 +898,11 @@ func (in *RetryStrategy) DeepCopyInto(out *RetryStrategy) {
 			**out = **in
 		}
 	}
+	if in.RetryOn != nil {
+		in, out := &in.RetryOn, &out.RetryOn
+		*out = make([]NodeFailureReason, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -610,6 +990,33 @@ func (in *Sidecar) DeepCopyInto(out *Sidecar) {
 			**out = **in
 		}
 	}
+	if in.ReadinessTimeoutSeconds != nil {
+		in, out := &in.ReadinessTimeoutSeconds, &out.ReadinessTimeoutSeconds
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	if in.ArchiveLogs != nil {
+		in, out := &in.ArchiveLogs, &out.ArchiveLogs
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(bool)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -639,6 +1046,31 @@ func (in *SuspendTemplate) DeepCopy() *SuspendTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TarStrategy) DeepCopyInto(out *TarStrategy) {
+	*out = *in
+	if in.CompressionLevel != nil {
+		in, out := &in.CompressionLevel, &out.CompressionLevel
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int32)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TarStrategy.
+func (in *TarStrategy) DeepCopy() *TarStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(TarStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Template) DeepCopyInto(out *Template) {
 	*out = *in
@@ -728,6 +1160,31 @@ func (in *Template) DeepCopyInto(out *Template) {
 			**out = **in
 		}
 	}
+	if in.ContainerSet != nil {
+		in, out := &in.ContainerSet, &out.ContainerSet
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ContainerSetTemplate)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(HTTPTemplate)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]UserContainer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Sidecars != nil {
 		in, out := &in.Sidecars, &out.Sidecars
 		*out = make([]Sidecar, len(*in))
@@ -753,6 +1210,15 @@ func (in *Template) DeepCopyInto(out *Template) {
 			**out = **in
 		}
 	}
+	if in.PodPendingTimeout != nil {
+		in, out := &in.PodPendingTimeout, &out.PodPendingTimeout
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
 	if in.RetryStrategy != nil {
 		in, out := &in.RetryStrategy, &out.RetryStrategy
 		if *in == nil {
@@ -791,6 +1257,32 @@ func (in *Template) DeepCopy() *Template {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserContainer) DeepCopyInto(out *UserContainer) {
+	*out = *in
+	in.Container.DeepCopyInto(&out.Container)
+	if in.MirrorVolumeMounts != nil {
+		in, out := &in.MirrorVolumeMounts, &out.MirrorVolumeMounts
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(bool)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserContainer.
+func (in *UserContainer) DeepCopy() *UserContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(UserContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ValueFrom) DeepCopyInto(out *ValueFrom) {
 	*out = *in
@@ -941,6 +1433,15 @@ func (in *ManagedSpec) DeepCopyInto(out *ManagedSpec) {
 		*out = make([]v1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.PodMetadata != nil {
+		in, out := &in.PodMetadata, &out.PodMetadata
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(Metadata)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	return
 }
 
@@ -984,6 +1485,15 @@ func (in *ManagedStatus) DeepCopyInto(out *ManagedStatus) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.ResourcesDuration != nil {
+		in, out := &in.ResourcesDuration, &out.ResourcesDuration
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ResourcesDuration)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -1020,3 +1530,19 @@ func (in *ManagedStep) DeepCopy() *ManagedStep {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZstdStrategy) DeepCopyInto(out *ZstdStrategy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZstdStrategy.
+func (in *ZstdStrategy) DeepCopy() *ZstdStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ZstdStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -88,3 +88,36 @@ func SetLogLevel(level string) {
 		log.Fatalf("Unknown level: %s", level)
 	}
 }
+
+// SetLogFormatter sets the logrus log formatter. One of: text|json
+func SetLogFormatter(format string) {
+	switch strings.ToLower(format) {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.Fatalf("Unknown log format: %s", format)
+	}
+}
+
+// AddLogFields adds the given fields to every subsequent log entry of the standard logger
+func AddLogFields(fields log.Fields) {
+	log.AddHook(logFieldsHook(fields))
+}
+
+// logFieldsHook is a logrus hook which adds a fixed set of fields to log entries
+type logFieldsHook log.Fields
+
+func (h logFieldsHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h logFieldsHook) Fire(entry *log.Entry) error {
+	for k, v := range h {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+	return nil
+}
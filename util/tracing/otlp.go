@@ -0,0 +1,252 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultEndpoint = "localhost:4318"
+
+	// batchSize is the number of ended spans which triggers an export
+	batchSize = 512
+	// batchTimeout is the longest time an ended span waits before being exported
+	batchTimeout = 5 * time.Second
+	// exportTimeout bounds a request to the collector
+	exportTimeout = 10 * time.Second
+
+	// spanKindInternal is the OTLP kind of the spans, which all are internal operations
+	spanKindInternal = 1
+)
+
+// exporter sends OTLP/JSON encoded spans to their destination
+type exporter interface {
+	export(data []byte) error
+	close() error
+}
+
+// fileExporter appends one OTLP/JSON request per line to a file, like the file exporter of the
+// OpenTelemetry collector
+type fileExporter struct {
+	file *os.File
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) export(data []byte) error {
+	_, err := e.file.Write(append(data, '\n'))
+	return err
+}
+
+func (e *fileExporter) close() error {
+	return e.file.Close()
+}
+
+// httpExporter posts OTLP/JSON requests to the traces endpoint of a collector
+type httpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPExporter(endpoint string, insecure bool) *httpExporter {
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &httpExporter{
+		url:    fmt.Sprintf("%s://%s/v1/traces", scheme, endpoint),
+		client: &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (e *httpExporter) export(data []byte) error {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector %s responded with %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+func (e *httpExporter) close() error {
+	return nil
+}
+
+// batcher is the processor exporting ended spans in batches from a background goroutine
+type batcher struct {
+	serviceName string
+	exporter    exporter
+
+	lock  sync.Mutex
+	spans []*Span
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newBatcher(serviceName string, exporter exporter) *batcher {
+	b := &batcher{
+		serviceName: serviceName,
+		exporter:    exporter,
+		flush:       make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// OnEnd queues the span for export
+func (b *batcher) OnEnd(span *Span) {
+	b.lock.Lock()
+	b.spans = append(b.spans, span)
+	full := len(b.spans) >= batchSize
+	b.lock.Unlock()
+	if full {
+		select {
+		case b.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(batchTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.flush:
+		case <-b.stop:
+			b.export()
+			return
+		}
+		b.export()
+	}
+}
+
+func (b *batcher) export() {
+	b.lock.Lock()
+	spans := b.spans
+	b.spans = nil
+	b.lock.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	data, err := json.Marshal(newExportRequest(b.serviceName, spans))
+	if err == nil {
+		err = b.exporter.export(data)
+	}
+	if err != nil {
+		log.Warnf("Failed to export %d spans: %v", len(spans), err)
+	}
+}
+
+// shutdown exports the pending spans and closes the exporter
+func (b *batcher) shutdown() {
+	close(b.stop)
+	<-b.done
+	if err := b.exporter.close(); err != nil {
+		log.Warnf("Failed to close the span exporter: %v", err)
+	}
+}
+
+// The following types are the OTLP/JSON encoding of an ExportTraceServiceRequest
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func newExportRequest(serviceName string, spans []*Span) exportRequest {
+	data := make([]spanData, len(spans))
+	for i, span := range spans {
+		data[i] = spanData{
+			TraceID:           span.context.TraceID.String(),
+			SpanID:            span.context.SpanID.String(),
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        newKeyValues(span.attributes),
+		}
+		if span.parent != (SpanID{}) {
+			data[i].ParentSpanID = span.parent.String()
+		}
+	}
+	return exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource:   resource{Attributes: newKeyValues([]Attribute{String("service.name", serviceName)})},
+			ScopeSpans: []scopeSpans{{Scope: scope{Name: instrumentationName}, Spans: data}},
+		}},
+	}
+}
+
+func newKeyValues(attributes []Attribute) []keyValue {
+	var kvs []keyValue
+	for _, attr := range attributes {
+		kvs = append(kvs, keyValue{Key: attr.Key, Value: anyValue{StringValue: attr.Value}})
+	}
+	return kvs
+}
@@ -0,0 +1,298 @@
+// Package tracing provides the optional OpenTelemetry tracing of the kubext components. Spans are
+// exported with the OTLP/JSON encoding, either to a file or to a collector over HTTP.
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone = "none"
+	// ExporterFile writes spans as JSON to a file
+	ExporterFile = "file"
+	// ExporterOTLP sends spans to a collector using OTLP over HTTP
+	ExporterOTLP = "otlp"
+
+	instrumentationName = "github.com/jbrette/kubext"
+
+	// traceparentHeader is the W3C trace context key under which the span context is propagated
+	traceparentHeader = "traceparent"
+)
+
+// Config configures the export of traces
+type Config struct {
+	// Exporter is the span exporter. One of: none|file|otlp (default none)
+	Exporter string `json:"exporter,omitempty"`
+
+	// Endpoint is the host:port of the OTLP/HTTP collector (default localhost:4318)
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Insecure disables TLS when connecting to the OTLP/HTTP collector
+	Insecure bool `json:"insecure,omitempty"`
+
+	// File is the path of the file to which spans are written by the file exporter
+	File string `json:"file,omitempty"`
+}
+
+// AddFlags adds the command line flags configuring tracing to the flag set
+func AddFlags(flags *pflag.FlagSet, config *Config) {
+	flags.StringVar(&config.Exporter, "trace-exporter", ExporterNone, "Trace exporter. One of: none|file|otlp")
+	flags.StringVar(&config.Endpoint, "trace-endpoint", "", "host:port of the OTLP/HTTP trace collector")
+	flags.BoolVar(&config.Insecure, "trace-insecure", false, "Disable TLS when connecting to the trace collector")
+	flags.StringVar(&config.File, "trace-file", "", "Path of the file to which the file exporter writes spans")
+}
+
+// Args returns the command line flags which reproduce the config
+func (c Config) Args() []string {
+	if c.Exporter == "" || c.Exporter == ExporterNone {
+		return nil
+	}
+	args := []string{"--trace-exporter", c.Exporter}
+	if c.Endpoint != "" {
+		args = append(args, "--trace-endpoint", c.Endpoint)
+	}
+	if c.Insecure {
+		args = append(args, "--trace-insecure")
+	}
+	if c.File != "" {
+		args = append(args, "--trace-file", c.File)
+	}
+	return args
+}
+
+// Validate returns an error if the config is invalid
+func (c Config) Validate() error {
+	switch c.Exporter {
+	case "", ExporterNone, ExporterOTLP:
+	case ExporterFile:
+		if c.File == "" {
+			return fmt.Errorf("trace file exporter requires a file")
+		}
+	default:
+		return fmt.Errorf("unknown trace exporter '%s'. Must be one of: none|file|otlp", c.Exporter)
+	}
+	return nil
+}
+
+// Processor receives the spans as they end
+type Processor interface {
+	OnEnd(span *Span)
+}
+
+var (
+	processorLock sync.RWMutex
+	processor     Processor
+)
+
+// SetProcessor installs the processor receiving the ended spans. A nil processor disables tracing.
+func SetProcessor(p Processor) {
+	processorLock.Lock()
+	defer processorLock.Unlock()
+	processor = p
+}
+
+func getProcessor() Processor {
+	processorLock.RLock()
+	defer processorLock.RUnlock()
+	return processor
+}
+
+// Init installs the span processor of the named service according to the config. The returned
+// function flushes pending spans and must be called before the process exits.
+func Init(serviceName string, config Config) (func(), error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	var exporter exporter
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func() {}, nil
+	case ExporterFile:
+		exporter, err = newFileExporter(config.File)
+	case ExporterOTLP:
+		exporter = newHTTPExporter(config.Endpoint, config.Insecure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	batcher := newBatcher(serviceName, exporter)
+	SetProcessor(batcher)
+	return func() {
+		SetProcessor(nil)
+		batcher.shutdown()
+	}, nil
+}
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the hex encoding of the trace ID
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// String returns the hex encoding of the span ID
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid returns whether the span context identifies a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Attribute is a key/value pair describing a span
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a timed operation of a trace. A nil span is valid and records nothing.
+type Span struct {
+	name       string
+	context    SpanContext
+	parent     SpanID
+	attributes []Attribute
+	start      time.Time
+	end        time.Time
+	processor  Processor
+	endOnce    sync.Once
+}
+
+// Name returns the name of the span
+func (s *Span) Name() string {
+	return s.name
+}
+
+// SpanContext returns the span context of the span
+func (s *Span) SpanContext() SpanContext {
+	return s.context
+}
+
+// Parent returns the ID of the parent span, which is zero for root spans
+func (s *Span) Parent() SpanID {
+	return s.parent
+}
+
+// End ends the span and hands it to the processor
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.endOnce.Do(func() {
+		s.end = time.Now()
+		s.processor.OnEnd(s)
+	})
+}
+
+type spanContextKey struct{}
+
+// SpanContextFromContext returns the span context carried by ctx
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+func contextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// Start starts a span as a child of the span carried by ctx and returns a copy of ctx carrying
+// the new span. When tracing is disabled, ctx is returned unchanged along with a nil span.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	p := getProcessor()
+	if p == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		name:       name,
+		parent:     parent.SpanID,
+		attributes: attributes,
+		start:      time.Now(),
+		processor:  p,
+	}
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+	} else {
+		_, _ = rand.Read(span.context.TraceID[:])
+	}
+	_, _ = rand.Read(span.context.SpanID[:])
+	return contextWithSpanContext(ctx, span.context), span
+}
+
+// Inject returns the trace context of ctx as a carrier map, or nil if ctx is not traced
+func Inject(ctx context.Context) map[string]string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return map[string]string{
+		traceparentHeader: fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID),
+	}
+}
+
+// Extract returns a copy of ctx carrying the remote trace context from the carrier map. ctx is
+// returned unchanged if the carrier holds no valid trace context.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	parts := strings.Split(carrier[traceparentHeader], "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	if !sc.IsValid() {
+		return ctx
+	}
+	return contextWithSpanContext(ctx, sc)
+}
+
+// Recorder is a processor keeping the ended spans in memory
+type Recorder struct {
+	lock  sync.Mutex
+	spans []*Span
+}
+
+// OnEnd records the span
+func (r *Recorder) OnEnd(span *Span) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// Ended returns the recorded spans in the order they ended
+func (r *Recorder) Ended() []*Span {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*Span(nil), r.spans...)
+}